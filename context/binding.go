@@ -0,0 +1,44 @@
+package context
+
+import (
+	gocontext "context"
+
+	us "github.com/zn8nz/units/quantity"
+)
+
+// bindingKey is the key of the bound contexts in a context.Context.
+type bindingKey struct{}
+
+// Bind returns a copy of the standard library context c with the given contexts attached,
+// e.g. the display preferences of the user of an HTTP request, so that FormatCtx formats
+// quantities with them. Contexts bound later, e.g. by a handler after a middleware, take
+// precedence over those bound earlier.
+func Bind(c gocontext.Context, contexts ...*Context) gocontext.Context {
+	bound := append(append([]*Context{}, contexts...), Bound(c)...)
+	return gocontext.WithValue(c, bindingKey{}, bound)
+}
+
+// Bound returns the contexts attached to c by Bind, in order of precedence.
+func Bound(c gocontext.Context) []*Context {
+	bound, _ := c.Value(bindingKey{}).([]*Context)
+	return bound
+}
+
+// ContextFor returns the first Context bound to c whose unit is compatible with q, or nil.
+func ContextFor(c gocontext.Context, q us.Quantity) *Context {
+	for _, ctx := range Bound(c) {
+		if q.HasCompatibleUnit(ctx.Symbol()) {
+			return ctx
+		}
+	}
+	return nil
+}
+
+// FormatCtx formats q with the Context bound to c that is compatible with it, see
+// ContextFor, or with q.String if there is none.
+func FormatCtx(c gocontext.Context, q us.Quantity) string {
+	if ctx := ContextFor(c, q); ctx != nil {
+		return ctx.String(q)
+	}
+	return q.String()
+}
@@ -4,20 +4,46 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
 	us "github.com/zn8nz/units/quantity"
 )
 
 // Context is a usage domain for us.Quantity values, it qualifies a unit,
 // allowing it to be formatted differenty.
 type Context struct {
-	Name      string                  // unique context name, except "" => do not register
+	Name      string                     // unique context name, except "" => do not register
 	*us.Unit                             // preferred unit for values
-	format    string                  // output format
+	format    string                     // output format
 	formatter func(q us.Quantity) string // nil or conversion to be applied for String() and Format()
+	locale    string                     // locale tag for number formatting, "" for the Go fmt defaults
+	location  *time.Location             // nil or time zone for times calculated from durations
+	zeroBelow float64                    // absolute values below it are shown as 0
+	plainZero bool                       // show values formatted as zero as "0"
+	parent    *Context                   // nil or the context this one is derived from
+	set       setting                    // the settings that are not inherited from the parent
 }
 
+// numberRx finds the number in the output of the format string of a Context.
+var numberRx = regexp.MustCompile(`-?\d+(?:\.\d+)?`)
+
+// exponentRx matches the exponent that may follow the number in the output of a format string.
+var exponentRx = regexp.MustCompile(`^[eE][-+]?\d+`)
+
 var contexts = make(map[string]*Context)
 
+// Errors that can be checked with errors.Is.
+var (
+	ErrDuplicateContext = errors.New("duplicate context")
+	ErrUnknownCurrency  = errors.New("unknown currency")
+	ErrUnknownLocale    = errors.New("unknown locale")
+)
+
 // DefineContext registers a new usage context for a unit. It narrows down the domain in
 // which the unit is used and defines what the default symbol is and how to format output.
 // The name should be unique and is passed to Ctx(string) for lookup. An empty string is also
@@ -28,14 +54,18 @@ var contexts = make(map[string]*Context)
 // symbol, e.g. "%[2]s %.2[1]f" to put the unit in front of the value. If both value and unit are
 // referenced in that order in the format string, then the indexes are not necessary, e.g. "%e%s".
 func DefineContext(name, unit string, format string) (*Context, error) {
-	if name == "" {
-		return &Context{"", us.UnitFor(unit), format, nil}, nil
+	return register(&Context{Name: name, Unit: us.UnitFor(unit), format: format})
+}
+
+// register registers ctx for lookup by its name, unless the name is "".
+func register(ctx *Context) (*Context, error) {
+	if ctx.Name == "" {
+		return ctx, nil
 	}
-	if _, exists := contexts[name]; exists {
-		return nil, errors.New("duplicate context: " + name)
+	if _, exists := contexts[ctx.Name]; exists {
+		return nil, fmt.Errorf("%w: %s", ErrDuplicateContext, ctx.Name)
 	}
-	ctx := &Context{name, us.UnitFor(unit), format, nil}
-	contexts[name] = ctx
+	contexts[ctx.Name] = ctx
 	return ctx, nil
 }
 
@@ -57,25 +87,170 @@ func (ctx Context) Q(value float64, symbol string) us.Quantity {
 	return ctx.Convert(q)
 }
 
+// Parse parses text input with us.Parse, checks that the unit is compatible with the
+// Context's unit and returns the quantity converted to that unit. If a locale is set, its
+// decimal separator and, if it is "." or ",", its group separator are expected, see
+// us.ParseNumberFormat.
+func (ctx Context) Parse(s string) (us.Quantity, error) {
+	nf := us.PointDecimal
+	if l, found := LookupLocale(ctx.Locale()); found {
+		nf = us.NumberFormat{Group: l.Group, Decimal: l.Decimal}
+		if nf.Group != "." && nf.Group != "," {
+			nf.Group = ""
+		}
+	}
+	q, err := us.ParseNumberFormat(s, nf)
+	if err != nil {
+		return q, err
+	}
+	if err = us.ExpectDimension(q, ctx.Symbol()); err != nil {
+		return us.Quantity{}, err
+	}
+	return ctx.Convert(q), nil
+}
+
+// ParseValue parses a number without unit, e.g. "1,250.5", and returns it as a quantity in
+// the Context's unit. Use it where the unit is implied, e.g. by a form field. An error is
+// returned if the number is not finite, e.g. "NaN", or the Context has no valid unit.
+func (ctx Context) ParseValue(s string) (us.Quantity, error) {
+	if ctx.Unit == nil || ctx.Unit == &us.UndefinedUnit {
+		return us.Quantity{}, &us.UnknownUnitError{Symbol: "?"}
+	}
+	v, err := strconv.ParseFloat(strings.Replace(strings.TrimSpace(s), ",", "", -1), 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return us.Quantity{}, &us.SyntaxError{Input: s, Reason: "invalid number"}
+	}
+	return ctx.Q(v, ctx.Symbol()), nil
+}
+
 // Convert converts a given quantity to the Context's default.
 func (ctx Context) Convert(q us.Quantity) us.Quantity {
 	return q.Convert(ctx.Unit)
 }
 
-// Format writes a formatted version of the us.Quantity to the Writer.
+// SetFormatter sets a function that formats quantities, already converted to the
+// Context's unit, for String and Format instead of the format string. Pass nil to use
+// the format string again.
+func (ctx *Context) SetFormatter(formatter func(q us.Quantity) string) {
+	ctx.formatter = formatter
+	ctx.set |= formatterSetting
+}
+
+// Format writes a formatted version of the us.Quantity to the Writer. The output is the
+// same as that of String.
 func (ctx Context) Format(wr io.Writer, q us.Quantity) {
-	q1 := ctx.Convert(q)
-	if ctx.formatter != nil {
-		fmt.Fprint(wr, ctx.formatter(q1))
-	}
-	fmt.Fprintf(wr, ctx.format, q1.Value(), q1.Symbol())
+	fmt.Fprint(wr, ctx.String(q))
 }
 
-// String returns a us.Quantity as string, formatted with the Context format string.
+// String returns a us.Quantity as string, formatted with the Context format string. If a
+// locale is set, the number in the output uses its separators, e.g. "1.234,5 km" for "de".
 func (ctx Context) String(q us.Quantity) string {
 	q1 := ctx.Convert(q)
-	if ctx.formatter != nil {
-		return ctx.formatter(q1)
+	if math.Abs(q1.Value()) < ctx.from(zeroSetting).zeroBelow {
+		if q1 = us.MultFac(q1, 0); math.Signbit(q1.Value()) {
+			q1 = us.Neg(q1)
+		}
+	}
+	if formatter := ctx.from(formatterSetting).formatter; formatter != nil {
+		return formatter(q1)
+	}
+	format := ctx.from(formatSetting).format
+	// the symbol is left out while the number is changed, so its digits, e.g. in "m3/h",
+	// are not taken for the number
+	symbol := q1.Symbol()
+	mark := strings.Repeat(symbolMark, utf8.RuneCountInString(symbol))
+	s := fmt.Sprintf(format, q1.Value(), mark)
+	if ctx.from(plainZeroSetting).plainZero {
+		s = plain(s, format, mark)
+	}
+	if locale := ctx.Locale(); locale != "" {
+		l, _ := LookupLocale(locale)
+		if loc := numberRx.FindStringIndex(s); loc != nil {
+			s = s[:loc[0]] + localizeNumber(s[loc[0]:loc[1]], l) + s[loc[1]:]
+		}
+	}
+	if mark == "" {
+		return s
+	}
+	return strings.Replace(s, mark, symbol, -1)
+}
+
+// symbolMark stands in for each character of the unit symbol in the output of the format
+// string while the number is changed. It has the width of one character, so padding such
+// as "%-6s" is the same, and is printed as is by "%q".
+const symbolMark = "\uFFFC"
+
+// plain returns s, the output of the format string, with the number replaced by "0" if it is
+// formatted as zero, e.g. "0 m" for "-0.0000 m". The symbol must not contain digits, see
+// symbolMark.
+func plain(s, format, symbol string) string {
+	if s != fmt.Sprintf(format, 0.0, symbol) && s != fmt.Sprintf(format, math.Copysign(0, -1), symbol) {
+		return s
+	}
+	loc := numberRx.FindStringIndex(s)
+	if loc == nil {
+		return s
+	}
+	if e := exponentRx.FindStringIndex(s[loc[1]:]); e != nil {
+		loc[1] += e[1]
+	}
+	return s[:loc[0]] + "0" + s[loc[1]:]
+}
+
+// SetZeroThreshold sets the threshold below which String and Format show the absolute value,
+// in the Context's unit, as exactly 0, e.g. 1e-9 to hide the rounding errors of
+// calculations. Pass 0 to show all values as they are.
+func (ctx *Context) SetZeroThreshold(epsilon float64) {
+	ctx.zeroBelow = epsilon
+	ctx.set |= zeroSetting
+}
+
+// SetPlainZero sets whether String and Format show a value that the format string formats
+// as zero, e.g. "-0.0000 m" or "0.0000 m", as "0 m", for UI output. It does not apply to a
+// formatter set with SetFormatter.
+func (ctx *Context) SetPlainZero(plain bool) {
+	ctx.plainZero = plain
+	ctx.set |= plainZeroSetting
+}
+
+// SetLocale sets the locale tag, e.g. "de-DE", whose separators String and Format use for
+// the number. Pass "" to use the Go fmt defaults again. An error is returned if the locale
+// is not registered, see LookupLocale.
+func (ctx *Context) SetLocale(tag string) error {
+	if _, found := LookupLocale(tag); !found && tag != "" {
+		return fmt.Errorf("%w: %s", ErrUnknownLocale, tag)
+	}
+	ctx.locale = tag
+	ctx.set |= localeSetting
+	return nil
+}
+
+// Locale returns the locale tag set with SetLocale.
+func (ctx Context) Locale() string {
+	return ctx.from(localeSetting).locale
+}
+
+// SetLocation sets the time zone for times calculated by Time. Pass nil for UTC.
+func (ctx *Context) SetLocation(loc *time.Location) {
+	ctx.location = loc
+	ctx.set |= locationSetting
+}
+
+// Location returns the time zone set with SetLocation, UTC if none is set.
+func (ctx Context) Location() *time.Location {
+	if loc := ctx.from(locationSetting).location; loc != nil {
+		return loc
+	}
+	return time.UTC
+}
+
+// Time returns the time a duration q after start, in the time zone of the Context, e.g.
+// for the end time of a job in a "job duration" context. An error is returned if q is
+// not a duration.
+func (ctx Context) Time(start time.Time, q us.Quantity) (time.Time, error) {
+	d, err := us.Duration(q)
+	if err != nil {
+		return time.Time{}, err
 	}
-	return fmt.Sprintf(ctx.format, q1.Value(), q1.Symbol())
+	return start.Add(d).In(ctx.Location()), nil
 }
@@ -2,12 +2,17 @@ package context
 
 import (
 	"bytes"
+	gocontext "context"
+	"errors"
+	"fmt"
+	"math"
 	"testing"
+	"time"
 	. "github.com/zn8nz/units/quantity"
 )
 
 const (
-	personHeight  = "person height"
+	childHeight   = "child height"
 	landArea      = "land area"
 	money         = "money"
 	rainIntensity = "rain intensity"
@@ -15,7 +20,7 @@ const (
 )
 
 func init() {
-	DefineContext(personHeight, "cm", "%.0[1]fcm")
+	DefineContext(childHeight, "cm", "%.0[1]fcm")
 	DefineContext(landArea, "acre", "%0.[1]f acres")
 	DefineContext(money, "¤", "%[2]s%.2[1]f")
 	DefineContext(rainIntensity, "mm/h", "%.1f %s")
@@ -23,7 +28,7 @@ func init() {
 }
 
 func TestContextDefinition(t *testing.T) {
-	c := Ctx(personHeight)
+	c := Ctx(childHeight)
 	if c == nil {
 		t.Error("not found: person height")
 	}
@@ -53,7 +58,7 @@ func TestContextDefinition(t *testing.T) {
 }
 
 func TestContextConversion(t *testing.T) {
-	height := Ctx(personHeight)
+	height := Ctx(childHeight)
 	q := height.Q(1.75, "m")
 	s := height.String(q)
 	if s != "175cm" {
@@ -101,3 +106,358 @@ func TestUnregisteredContext(t *testing.T) {
 		t.Error("expected 110 hPa/km, actual:", s)
 	}
 }
+
+func TestContextParse(t *testing.T) {
+	height := Ctx(childHeight)
+	q, err := height.Parse("5.9 ft")
+	if err != nil {
+		t.Error(err)
+	} else if q.Symbol() != "cm" || height.String(q) != "180cm" {
+		t.Error("expected 180cm, actual:", q)
+	}
+	if _, err = height.Parse("70 kg"); err == nil {
+		t.Error("incompatible unit accepted")
+	}
+	if _, err = height.Parse("tall"); err == nil {
+		t.Error("invalid input accepted")
+	}
+}
+
+func TestFormatter(t *testing.T) {
+	ctx, _ := DefineContext("", "ft", "%.1f %s")
+	ctx.SetFormatter(func(q Quantity) string {
+		ft := int(q.Value())
+		return fmt.Sprintf("%d'%d\"", ft, int((q.Value()-float64(ft))*12+0.5))
+	})
+	q := Q(1.8, "m")
+	var b bytes.Buffer
+	ctx.Format(&b, q)
+	if s := ctx.String(q); s != "5'11\"" || b.String() != s {
+		t.Error("expected: 5'11\", actual:", s, b.String())
+	}
+	ctx.SetFormatter(nil)
+	if s := ctx.String(q); s != "5.9 ft" {
+		t.Error("expected: 5.9 ft, actual:", s)
+	}
+}
+
+func TestFormatMoney(t *testing.T) {
+	data := []struct {
+		q        Quantity
+		code     string
+		locale   string
+		expected string
+	}{
+		{Q(1234.56, "$"), "USD", "en-US", "$1,234.56"},
+		{Q(1234.56, "NZD"), "NZD", "en-NZ", "NZ$1,234.56"},
+		{Q(-1234.564, "¤"), "EUR", "fr-FR", "-1 234,56 €"},
+		{Q(1234.5, "¤"), "EUR", "de", "1.234,50 €"},
+		{Q(1234567.8, "¤"), "JPY", "en", "¥1,234,568"},
+		{Q(1234.5, "¤"), "CHF", "de-CH", "CHF 1'234.50"},
+		{Q(1234.5, "¤"), "CHF", "fr_CH", "CHF 1'234.50"},
+		{Q(1234.5, "¤"), "EUR", "nl-NL", "€ 1.234,50"},
+	}
+	for _, d := range data {
+		s, err := FormatMoney(d.q, d.code, d.locale)
+		if err != nil || s != d.expected {
+			t.Error("expected:", d.expected, "actual:", s, err)
+		}
+	}
+	if _, err := FormatMoney(Q(1, "m"), "USD", "en"); err == nil {
+		t.Error("length formatted as money")
+	}
+	ctx, err := DefineMoneyContext("", "NZD", "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := ctx.String(Q(12, "NZD")); s != "NZ$12.00" {
+		t.Error("expected: NZ$12.00, actual:", s)
+	}
+}
+
+func TestContextZero(t *testing.T) {
+	ctx, _ := DefineContext("", "m", "%.4f %s")
+	q := Subtract(Q(0.3, "m"), Add(Q(0.1, "m"), Q(0.2, "m")))
+	if s := ctx.String(q); s != "-0.0000 m" {
+		t.Error("expected: -0.0000 m, actual:", s)
+	}
+	ctx.SetPlainZero(true)
+	data := []struct {
+		q        Quantity
+		expected string
+	}{
+		{q, "0 m"},
+		{Q(0, "m"), "0 m"},
+		{Q(0.00004, "m"), "0 m"},
+		{Q(0.0002, "m"), "0.0002 m"},
+		{Q(-3, "mm"), "-0.0030 m"},
+	}
+	for _, d := range data {
+		if s := ctx.String(d.q); s != d.expected {
+			t.Error("expected:", d.expected, "actual:", s)
+		}
+	}
+	sci, _ := DefineContext("", "m", "%.2e %s")
+	sci.SetZeroThreshold(1e-9)
+	if s := sci.String(q); s != "0.00e+00 m" {
+		t.Error("expected: 0.00e+00 m, actual:", s)
+	}
+	if s := sci.String(Q(2, "um")); s != "2.00e-06 m" {
+		t.Error("expected: 2.00e-06 m, actual:", s)
+	}
+	sci.SetPlainZero(true)
+	if s := sci.String(q); s != "0 m" {
+		t.Error("expected: 0 m, actual:", s)
+	}
+	sci.SetFormatter(func(q Quantity) string { return fmt.Sprint(q.Value()) })
+	if s := sci.String(q); s != "0" {
+		t.Error("expected: 0, actual:", s)
+	}
+}
+
+func TestContextDerive(t *testing.T) {
+	length, _ := DefineContext("", "m", "%.2f %s")
+	short, err := length.Derive("short length", Overrides{Unit: "mm"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer DeleteContext(short)
+	if Ctx("short length") != short || short.Parent() != length {
+		t.Error("derived context not registered")
+	}
+	if s := short.String(Q(1.5, "cm")); s != "15.00 mm" {
+		t.Error("expected: 15.00 mm, actual:", s)
+	}
+	rough, _ := length.Derive("", Overrides{Format: "%.0f %s"})
+	if s := rough.String(Q(1234.5, "m")); s != "1234 m" {
+		t.Error("expected: 1234 m, actual:", s)
+	}
+	length.SetLocale("de")
+	if s := short.String(Q(1234.5, "mm")); s != "1.234,50 mm" {
+		t.Error("expected: 1.234,50 mm, actual:", s)
+	}
+	if s := rough.String(Q(1234.5, "m")); s != "1.234 m" {
+		t.Error("expected: 1.234 m, actual:", s)
+	}
+	rough.SetLocale("")
+	if s := rough.String(Q(1234.5, "m")); s != "1234 m" {
+		t.Error("expected: 1234 m, actual:", s)
+	}
+	length.SetFormatter(func(q Quantity) string { return "fmt" })
+	if s, r := short.String(Q(1, "m")), rough.String(Q(1, "m")); s != "fmt" || r != "1 m" {
+		t.Error("expected: fmt, 1 m, actual:", s, r)
+	}
+
+	if _, err := length.Derive("", Overrides{Unit: "kg"}); !errors.Is(err, ErrIncompatibleUnits) {
+		t.Error("expected:", ErrIncompatibleUnits, "actual:", err)
+	}
+	if _, err := length.Derive("", Overrides{Unit: "zorp"}); !errors.Is(err, ErrUnknownUnit) {
+		t.Error("expected:", ErrUnknownUnit, "actual:", err)
+	}
+	if _, err := length.Derive("short length", Overrides{}); !errors.Is(err, ErrDuplicateContext) {
+		t.Error("expected:", ErrDuplicateContext, "actual:", err)
+	}
+}
+
+func TestFormatCtx(t *testing.T) {
+	metric, _ := DefineContext("", "km", "%.1f %s")
+	imperial, _ := DefineContext("", "mi", "%.1f %s")
+	pressure, _ := DefineContext("", "bar", "%.2f %s")
+	c := gocontext.Background()
+	if s := FormatCtx(c, Q(5, "km")); s != Q(5, "km").String() {
+		t.Error("expected:", Q(5, "km"), "actual:", s)
+	}
+	c = Bind(c, metric)
+	if s := FormatCtx(c, Q(5000, "m")); s != "5.0 km" {
+		t.Error("expected: 5.0 km, actual:", s)
+	}
+	c = Bind(c, imperial, pressure)
+	tests := []struct {
+		q        Quantity
+		expected string
+	}{
+		{Q(16.09344, "km"), "10.0 mi"},
+		{Q(150, "kPa"), "1.50 bar"},
+		{Q(2, "kg"), Q(2, "kg").String()},
+	}
+	for _, test := range tests {
+		if s := FormatCtx(c, test.q); s != test.expected {
+			t.Error("expected:", test.expected, "actual:", s)
+		}
+	}
+	if bound := Bound(c); len(bound) != 3 || bound[0] != imperial || bound[2] != metric {
+		t.Error("unexpected bound contexts:", bound)
+	}
+}
+
+func TestContextLocale(t *testing.T) {
+	ctx, _ := DefineContext("", "km", "%.1f %s")
+	if err := ctx.SetLocale("de-DE"); err != nil {
+		t.Fatal(err)
+	}
+	data := []struct {
+		q        Quantity
+		expected string
+	}{
+		{Q(1234.5, "km"), "1.234,5 km"},
+		{Q(-1234500, "m"), "-1.234,5 km"},
+		{Q(12, "m"), "0,0 km"},
+	}
+	for _, d := range data {
+		if s := ctx.String(d.q); s != d.expected {
+			t.Error("expected:", d.expected, "actual:", s)
+		}
+	}
+	if q, err := ctx.Parse("1.234,5 km"); err != nil || q.Value() != 1234.5 {
+		t.Error("expected: 1234.5 km, actual:", q, err)
+	}
+	if err := ctx.SetLocale("xx"); !errors.Is(err, ErrUnknownLocale) || ctx.Locale() != "de-DE" {
+		t.Error("expected: unknown locale error, actual:", err, ctx.Locale())
+	}
+	ctx.SetLocale("")
+	if s := ctx.String(Q(1234.5, "km")); s != "1234.5 km" {
+		t.Error("expected: 1234.5 km, actual:", s)
+	}
+	for _, d := range []struct {
+		symbol, format string
+		value          float64
+		expected       string
+	}{
+		{"m2", "%[2]s %.2[1]f", 1234.5, "m2 1.234,50"},
+		{"m3/h", "%[2]s: %.1[1]f", -12, "m3/h: -12,0"},
+		{"m3/h", "%-6[2]s|%.1[1]f", 2.5, "m3/h  |2,5"},
+		{"m2", "%[2]s %.2[1]f", 0.001, "m2 0"},
+	} {
+		ctx, _ := DefineContext("", d.symbol, d.format)
+		ctx.SetLocale("de")
+		ctx.SetPlainZero(true)
+		if s := ctx.String(Q(d.value, d.symbol)); s != d.expected {
+			t.Error("expected:", d.expected, "actual:", s)
+		}
+	}
+}
+
+func TestContextTime(t *testing.T) {
+	ctx, _ := DefineContext("", "h", "%.1f %s")
+	loc := time.FixedZone("NZST", 12*3600)
+	ctx.SetLocation(loc)
+	start := time.Date(2020, 1, 1, 20, 0, 0, 0, time.UTC)
+	end, err := ctx.Time(start, Q(90, "min"))
+	if err != nil || end.Location() != loc || end.Format("15:04") != "09:30" {
+		t.Error("expected: 09:30 NZST, actual:", end, err)
+	}
+	if _, err := ctx.Time(start, Q(1, "m")); err == nil {
+		t.Error("length accepted as duration")
+	}
+}
+
+func TestFlowContexts(t *testing.T) {
+	if err := DefineFlowContexts(); err != nil {
+		t.Fatal(err)
+	}
+	data := []struct {
+		ctx      string
+		q        Quantity
+		expected string
+	}{
+		{VolumeFlow, Q(100, "GPM"), "22.71 m3/h"},
+		{VolumeFlow, Q(10, "L/min"), "0.60 m3/h"},
+		{VolumeFlow, Q(1000, "SCFM"), "1699.01 m3/h"},
+		{MassFlow, Q(24, "t/d"), "1000.0 kg/h"},
+		{MassFlow, Q(2, "tph"), "2000.0 kg/h"},
+	}
+	for _, d := range data {
+		if s := Ctx(d.ctx).String(d.q); s != d.expected {
+			t.Error("expected:", d.expected, "actual:", s)
+		}
+	}
+	if err := DefineFlowContexts(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPixelDensityContexts(t *testing.T) {
+	if err := DefinePixelDensityContexts(); err != nil {
+		t.Fatal(err)
+	}
+	if s := Ctx(PixelDensityMetric).String(Q(300, "dpi")); s != "11.81 dots/mm" {
+		t.Error("expected: 11.81 dots/mm, actual:", s)
+	}
+	if s := Ctx(PixelDensity).String(Q(4, "mm-1")); s != "102 dpi" {
+		t.Error("expected: 102 dpi, actual:", s)
+	}
+}
+
+func TestBodyContexts(t *testing.T) {
+	if err := DefineBodyContexts(); err != nil {
+		t.Fatal(err)
+	}
+	if s := Ctx(BodyMass).String(Q(154, "lb")); s != "69.9 kg" {
+		t.Error("expected: 69.9 kg, actual:", s)
+	}
+	height, _ := Ctx(PersonHeight).Parse("5.75 ft")
+	if s := Ctx(PersonHeight).String(height); s != "175 cm" {
+		t.Error("expected: 175 cm, actual:", s)
+	}
+	bmi, err := BMI(Ctx(BodyMass).Q(154, "lb"), height)
+	if err != nil || math.Abs(bmi-22.7) > 0.05 {
+		t.Error("expected: 22.7, actual:", bmi, err)
+	}
+	if bmi, err = BMI(Q(70, "kg"), Q(175, "cm")); err != nil || math.Abs(bmi-22.86) > 0.01 {
+		t.Error("expected: 22.86, actual:", bmi, err)
+	}
+	for _, q := range [][2]Quantity{{Q(70, "m"), Q(175, "cm")}, {Q(70, "kg"), Q(175, "kg")}, {Q(70, "kg"), Q(0, "m")}} {
+		if _, err := BMI(q[0], q[1]); err == nil {
+			t.Error("expected an error for", q)
+		}
+	}
+	if err := DefineBodyContexts(); err != nil {
+		t.Error(err)
+	}
+	mass := Ctx(BodyMass)
+	DeleteContext(mass)
+	DefineContext(BodyMass, "lb", "%.0f %s")
+	if err := DefineBodyContexts(); !errors.Is(err, ErrDuplicateContext) {
+		t.Error("expected ErrDuplicateContext, actual:", err)
+	}
+	DeleteContext(Ctx(BodyMass))
+	contexts[BodyMass] = mass
+}
+
+func TestContextParseValue(t *testing.T) {
+	height := Ctx(childHeight)
+	q, err := height.ParseValue(" 182 ")
+	if err != nil || height.String(q) != "182cm" {
+		t.Error("expected: 182cm, actual:", q, err)
+	}
+	area := Ctx(landArea)
+	if q, err = area.ParseValue("1,250.5"); err != nil || q.Value() != 1250.5 || q.Symbol() != "acre" {
+		t.Error("expected: 1250.5 acre, actual:", q, err)
+	}
+	if _, err = height.ParseValue("182 cm"); err == nil {
+		t.Error("unit accepted")
+	}
+}
+
+func TestContextErrors(t *testing.T) {
+	if _, err := DefineContext(childHeight, "m", "%f"); !errors.Is(err, ErrDuplicateContext) {
+		t.Error("expected ErrDuplicateContext, actual:", err)
+	}
+	if _, err := Ctx(childHeight).Parse("3 kg"); !errors.Is(err, ErrIncompatibleUnits) {
+		t.Error("expected ErrIncompatibleUnits, actual:", err)
+	}
+	for _, s := range []string{"tall", "NaN", "Inf", "-infinity"} {
+		if _, err := Ctx(childHeight).ParseValue(s); !errors.Is(err, ErrSyntax) {
+			t.Error(s, "expected ErrSyntax, actual:", err)
+		}
+	}
+	undefined, _ := DefineContext("", "zorp", "%.1f %s")
+	for _, ctx := range []*Context{undefined, {}} {
+		if _, err := ctx.ParseValue("12"); !errors.Is(err, ErrUnknownUnit) {
+			t.Error("expected ErrUnknownUnit, actual:", err)
+		}
+	}
+	if _, err := FormatMoney(Q(1, "$"), "XYZ", "en"); !errors.Is(err, ErrUnknownCurrency) {
+		t.Error("expected ErrUnknownCurrency, actual:", err)
+	}
+}
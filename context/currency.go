@@ -0,0 +1,164 @@
+package context
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	us "github.com/zn8nz/units/quantity"
+)
+
+// Currency describes how amounts in a currency are written.
+type Currency struct {
+	Code     string // ISO 4217 code, e.g. "NZD"
+	Symbol   string // symbol, e.g. "NZ$"
+	Decimals int    // number of minor unit digits, e.g. 2 for cents
+}
+
+// Locale describes number and currency conventions.
+type Locale struct {
+	Group       string // digit group separator, "" for none
+	Decimal     string // decimal separator
+	SymbolAfter bool   // currency symbol after the amount, e.g. "12,50 €"
+	SymbolSpace bool   // space after a currency symbol before the amount, e.g. "€ 12,50"
+}
+
+var currencies = map[string]Currency{
+	"AUD": {"AUD", "A$", 2},
+	"CAD": {"CAD", "CA$", 2},
+	"CHF": {"CHF", "CHF", 2},
+	"CNY": {"CNY", "CN¥", 2},
+	"EUR": {"EUR", "€", 2},
+	"GBP": {"GBP", "£", 2},
+	"INR": {"INR", "₹", 2},
+	"JPY": {"JPY", "¥", 0},
+	"NZD": {"NZD", "NZ$", 2},
+	"USD": {"USD", "$", 2},
+}
+
+var locales = map[string]Locale{
+	"en":    {",", ".", false, false},
+	"de":    {".", ",", true, false},
+	"es":    {".", ",", true, false},
+	"fr":    {" ", ",", true, false},
+	"it":    {".", ",", true, false},
+	"nl":    {".", ",", false, true},
+	"de-CH": {"'", ".", false, true},
+	"fr-CH": {"'", ".", false, true},
+}
+
+// DefineCurrency registers or replaces the formatting rules for a currency code.
+func DefineCurrency(c Currency) {
+	currencies[c.Code] = c
+}
+
+// DefineLocale registers or replaces the conventions for a locale tag, e.g. "en-IN".
+func DefineLocale(tag string, l Locale) {
+	locales[tag] = l
+}
+
+// LookupLocale returns the conventions for a locale tag such as "de-DE" or "de_CH". If the
+// full tag is not registered the language part is used, e.g. "de". The result is false if
+// neither is known.
+func LookupLocale(tag string) (Locale, bool) {
+	if l, found := locales[strings.Replace(tag, "_", "-", 1)]; found {
+		return l, true
+	}
+	if i := strings.IndexAny(tag, "-_"); i != -1 {
+		l, found := locales[tag[:i]]
+		return l, found
+	}
+	return Locale{}, false
+}
+
+// FormatMoney formats an amount of money with the currency symbol for the code, placed
+// and separated according to the locale, e.g. "$1,234.56" for "USD" and "en", or
+// "1.234,56 €" for "EUR" and "de". If the code is a registered unit the amount is
+// converted to it first, otherwise the value is used as is.
+func FormatMoney(q us.Quantity, code, locale string) (string, error) {
+	if err := us.ExpectDimension(q, "¤"); err != nil {
+		return "", err
+	}
+	c, found := currencies[code]
+	if !found {
+		return "", fmt.Errorf("%w: %s", ErrUnknownCurrency, code)
+	}
+	l, found := LookupLocale(locale)
+	if !found {
+		return "", fmt.Errorf("%w: %s", ErrUnknownLocale, locale)
+	}
+	if u, ok := q.ConvertTo(code); ok {
+		q = u
+	}
+	v := q.Value()
+	s := FormatNumber(math.Abs(v), c.Decimals, l)
+	switch {
+	case l.SymbolAfter:
+		s += " " + c.Symbol
+	case l.SymbolSpace:
+		s = c.Symbol + " " + s
+	default:
+		s = c.Symbol + s
+	}
+	if v < 0 {
+		s = "-" + s
+	}
+	return s, nil
+}
+
+// FormatNumber formats a value with a fixed number of decimals and the separators of
+// the locale, e.g. "-1 234,50" for -1234.5, 2 decimals and the "fr" locale.
+func FormatNumber(v float64, decimals int, l Locale) string {
+	s := localizeNumber(strconv.FormatFloat(math.Abs(v), 'f', decimals, 64), l)
+	if v < 0 {
+		s = "-" + s
+	}
+	return s
+}
+
+// localizeNumber adds group separators to and replaces the decimal point of a number
+// formatted by strconv or fmt, e.g. "1234.5" becomes "1.234,5" for the "de" locale.
+func localizeNumber(s string, l Locale) string {
+	integer, fraction := s, ""
+	if i := strings.IndexByte(s, '.'); i != -1 {
+		integer, fraction = s[:i], s[i+1:]
+	}
+	var b strings.Builder
+	if strings.HasPrefix(integer, "-") {
+		b.WriteByte('-')
+		integer = integer[1:]
+	}
+	for i, r := range integer {
+		if i > 0 && (len(integer)-i)%3 == 0 {
+			b.WriteString(l.Group)
+		}
+		b.WriteRune(r)
+	}
+	if fraction != "" {
+		b.WriteString(l.Decimal)
+		b.WriteString(fraction)
+	}
+	return b.String()
+}
+
+// DefineMoneyContext registers a Context for amounts in the currency code, formatted with
+// FormatMoney for the locale. The code must be a registered unit. As with DefineContext
+// the name "" creates the Context without registering it.
+func DefineMoneyContext(name, code, locale string) (*Context, error) {
+	if _, found := currencies[code]; !found {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownCurrency, code)
+	}
+	if _, found := LookupLocale(locale); !found {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownLocale, locale)
+	}
+	ctx, err := DefineContext(name, code, us.DefaultFormat)
+	if err != nil {
+		return nil, err
+	}
+	ctx.SetFormatter(func(q us.Quantity) string {
+		s, _ := FormatMoney(q, code, locale)
+		return s
+	})
+	return ctx, nil
+}
@@ -0,0 +1,71 @@
+package context
+
+import (
+	us "github.com/zn8nz/units/quantity"
+)
+
+// setting is a set of the settings of a Context that a derived Context inherits from its
+// parent until they are set on it.
+type setting uint8
+
+const (
+	formatSetting setting = 1 << iota
+	formatterSetting
+	localeSetting
+	locationSetting
+	zeroSetting
+	plainZeroSetting
+)
+
+// Overrides are the settings of a derived Context that differ from its parent, see Derive.
+// Empty fields are inherited.
+type Overrides struct {
+	Unit   string // preferred unit, compatible with the unit of the parent
+	Format string // format string, see DefineContext
+	Locale string // locale tag, see SetLocale
+}
+
+// Derive creates a Context that inherits from ctx, e.g. a "rainfall" context with the unit of
+// a "precipitation" context but its own format, or the same format with another unit. The
+// unit is the parent's unless overridden; the format, formatter, locale, time zone and zero
+// settings are looked up in the parent as long as they are not set on the derived Context,
+// so that changing e.g. the locale of a parent changes it for all contexts derived from it.
+// Setting the format in the overrides replaces an inherited formatter. As with
+// DefineContext, the name "" creates the Context without registering it. An error is
+// returned if the name is registered, or the unit is unknown or not compatible.
+func (ctx *Context) Derive(name string, overrides Overrides) (*Context, error) {
+	child := &Context{Name: name, Unit: ctx.Unit, parent: ctx}
+	if overrides.Unit != "" {
+		if us.UnitFor(overrides.Unit) == &us.UndefinedUnit {
+			return nil, &us.UnknownUnitError{Symbol: overrides.Unit}
+		}
+		if err := us.ExpectDimension(us.Q(1, overrides.Unit), ctx.Symbol()); err != nil {
+			return nil, err
+		}
+		child.Unit = us.UnitFor(overrides.Unit)
+	}
+	if overrides.Format != "" {
+		child.format = overrides.Format
+		child.set |= formatSetting | formatterSetting
+	}
+	if overrides.Locale != "" {
+		if err := child.SetLocale(overrides.Locale); err != nil {
+			return nil, err
+		}
+	}
+	return register(child)
+}
+
+// Parent returns the Context this one is derived from, or nil.
+func (ctx Context) Parent() *Context {
+	return ctx.parent
+}
+
+// from returns the nearest of ctx and its ancestors that has the setting s.
+func (ctx *Context) from(s setting) *Context {
+	c := ctx
+	for c.parent != nil && c.set&s == 0 {
+		c = c.parent
+	}
+	return c
+}
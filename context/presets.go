@@ -0,0 +1,85 @@
+package context
+
+import (
+	"errors"
+
+	us "github.com/zn8nz/units/quantity"
+)
+
+// Names of the preset contexts.
+const (
+	MassFlow           = "mass flow"
+	VolumeFlow         = "volume flow"
+	PixelDensity       = "pixel density"
+	PixelDensityMetric = "pixel density metric"
+	PersonHeight       = "person height"
+	BodyMass           = "body mass"
+)
+
+type preset struct {
+	name, unit, format string
+}
+
+var flowPresets = []preset{
+	{MassFlow, "kg/h", "%.1f %s"},
+	{VolumeFlow, "m3/h", "%.2f %s"},
+}
+
+var pixelDensityPresets = []preset{
+	{PixelDensity, "dpi", "%.0f %s"},
+	{PixelDensityMetric, "mm-1", "%.2[1]f dots/mm"},
+}
+
+var bodyPresets = []preset{
+	{PersonHeight, "cm", "%.0f %s"},
+	{BodyMass, "kg", "%.1f %s"},
+}
+
+// DefineFlowContexts registers the MassFlow (kg/h) and VolumeFlow (m3/h) contexts.
+func DefineFlowContexts() error {
+	return definePresets(flowPresets)
+}
+
+// DefinePixelDensityContexts registers the PixelDensity (dpi) and PixelDensityMetric
+// (dots per mm) contexts.
+func DefinePixelDensityContexts() error {
+	return definePresets(pixelDensityPresets)
+}
+
+// DefineBodyContexts registers the PersonHeight (cm) and BodyMass (kg) contexts.
+func DefineBodyContexts() error {
+	return definePresets(bodyPresets)
+}
+
+// BMI returns the body mass index, the mass in kg divided by the square of the height in
+// m, e.g. 22.9 for 70 kg and 175 cm. The quantities can be in any unit of mass and length,
+// e.g. from the BodyMass and PersonHeight contexts; an error is returned otherwise.
+func BMI(mass, height us.Quantity) (float64, error) {
+	if err := us.ExpectDimension(mass, "kg"); err != nil {
+		return 0, err
+	}
+	if err := us.ExpectDimension(height, "m"); err != nil {
+		return 0, err
+	}
+	kg, _ := mass.ConvertTo("kg")
+	m, _ := height.ConvertTo("m")
+	if m.Value() <= 0 {
+		return 0, errors.New("height must be positive")
+	}
+	return kg.Value() / (m.Value() * m.Value()), nil
+}
+
+// definePresets registers the presets. A preset that is already registered with the same unit
+// and format is skipped, so the presets can be defined more than once, but ErrDuplicateContext
+// is returned if another context has taken its name.
+func definePresets(presets []preset) error {
+	for _, p := range presets {
+		if c := Ctx(p.name); c != nil && c.Unit == us.UnitFor(p.unit) && c.format == p.format {
+			continue
+		}
+		if _, err := DefineContext(p.name, p.unit, p.format); err != nil {
+			return err
+		}
+	}
+	return nil
+}
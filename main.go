@@ -2,38 +2,202 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 
 	us "github.com/imhotep-nb/units/quantity"
 )
 
-// main is just simple conversion program.
+// main is just simple conversion program. It evaluates expressions such as
+// "3 kg * 9.81 m/s2 in lbf", see quantity.Eval, from the arguments or else interactively.
+// With the arguments "matrix" and unit symbols, e.g. "matrix m ft in yd", it prints the
+// conversion matrix of the units instead. "serve" and an optional address, default :8080,
+// serves a JSON HTTP API instead, see newServeMux. Flags control the output, see output.
+// Custom units are loaded from units/definitions.json in the user's configuration
+// directory, e.g. ~/.config/units/definitions.json, see quantity.LoadDefinitions.
 func main() {
-	scanner := bufio.NewScanner(os.Stdin)
-	fmt.Println("Type 'quit' to exit the loop.")
+	out, args, err := parseFlags(os.Args[1:], os.Stderr)
+	if err != nil {
+		os.Exit(2)
+	}
+	if err := loadDefinitions(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	switch {
+	case len(args) > 0 && args[0] == "matrix":
+		prec := out.precision
+		if prec < 0 {
+			prec = 6
+		}
+		lines, err := us.FormatMatrix(args[1:], 12, prec)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		for _, line := range lines {
+			fmt.Println(line)
+		}
+	case len(args) > 0 && args[0] == "serve":
+		addr := ":8080"
+		if len(args) > 1 {
+			addr = args[1]
+		}
+		fmt.Fprintln(os.Stderr, "Serving the conversion API on", addr)
+		if err := http.ListenAndServe(addr, newServeMux()); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	case len(args) > 0:
+		q, err := us.Eval(strings.Join(args, " "))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Println(out.render(q))
+	default:
+		repl(os.Stdin, os.Stdout, out)
+	}
+}
+
+// output holds the flags that control how results are shown.
+type output struct {
+	format    string // Quantity.Format string, overrides precision
+	precision int    // decimals, -1 for the default format
+	si        bool   // convert to SI units
+	humanize  bool   // convert to the unit with the nicest value, see quantity.BestUnit
+}
+
+// parseFlags parses the flags at the start of args, see output, and returns the other
+// arguments. A negative number ends the flags, so "-5 degC in degF" is an expression and not
+// an unknown flag. Errors and the usage are written to w.
+func parseFlags(args []string, w io.Writer) (output, []string, error) {
+	var out output
+	fs := flag.NewFlagSet("units", flag.ContinueOnError)
+	fs.SetOutput(w)
+	fs.StringVar(&out.format, "format", "", "format of results, e.g. \"%.2f %s\", see Quantity.Format")
+	fs.IntVar(&out.precision, "precision", -1, "number of decimals of results")
+	fs.BoolVar(&out.si, "si", false, "show results in SI units")
+	fs.BoolVar(&out.humanize, "humanize", false, "show results in the unit with the nicest value")
+	n := 0
+	for n < len(args) && len(args[n]) > 1 && args[n][0] == '-' && !isNegativeNumber(args[n]) {
+		name := strings.TrimLeft(args[n], "-")
+		n++
+		if name == "" {
+			break // "--" ends the flags
+		}
+		if f := fs.Lookup(name); f != nil && !strings.Contains(name, "=") && !isBoolFlag(f) {
+			n++ // the value, which may be a negative number, e.g. "-precision -1"
+		}
+	}
+	if n > len(args) {
+		n = len(args)
+	}
+	if err := fs.Parse(args[:n]); err != nil {
+		return out, nil, err
+	}
+	return out, append(fs.Args(), args[n:]...), nil
+}
+
+// isNegativeNumber reports whether the argument starts with a minus sign and a digit or
+// decimal point, e.g. "-5" or "-.5".
+func isNegativeNumber(arg string) bool {
+	return len(arg) > 1 && arg[0] == '-' && (arg[1] >= '0' && arg[1] <= '9' || arg[1] == '.')
+}
+
+// isBoolFlag reports whether the flag needs no value, e.g. "-si".
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// render formats the result according to the flags.
+func (o output) render(q us.Quantity) string {
+	if o.si {
+		q = q.ToSI()
+	}
+	if o.humanize {
+		q = us.BestUnit(q)
+	}
+	switch {
+	case o.format != "":
+		return q.Format(o.format)
+	case o.precision >= 0:
+		return q.Format(fmt.Sprintf("%%.%df %%s", o.precision))
+	}
+	return q.String()
+}
+
+// loadDefinitions loads the user's unit definitions, if there are any.
+func loadDefinitions() error {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return nil
+	}
+	f, err := os.Open(filepath.Join(dir, "units", "definitions.json"))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := us.LoadDefinitions(f); err != nil {
+		return fmt.Errorf("%s: %w", f.Name(), err)
+	}
+	return nil
+}
+
+// repl reads expressions from r and writes their values, rendered by out, to w until "quit"
+// or the end of the input. "history" lists the previous expressions, "!!" repeats the last
+// one and "!n" repeats number n of the history.
+func repl(r io.Reader, w io.Writer, out output) {
+	scanner := bufio.NewScanner(r)
+	var history []string
+	fmt.Fprintln(w, "Type an expression, e.g. 3 kg * 9.81 m/s2 in lbf, or 'quit' to exit the loop.")
 	for {
-		fmt.Println()
-		fmt.Print("Value:    ")
-		scanner.Scan()
-		s := scanner.Text()
-		if s == "quit" {
+		fmt.Fprint(w, "> ")
+		if !scanner.Scan() {
 			break
 		}
-		qu1, err := us.Parse(s)
-		if err != nil {
-			fmt.Println("Cannot parse")
+		s := strings.TrimSpace(scanner.Text())
+		switch {
+		case s == "quit":
+			return
+		case s == "":
+			continue
+		case s == "history":
+			for i, h := range history {
+				fmt.Fprintf(w, "%4d  %s\n", i+1, h)
+			}
 			continue
+		case s == "!!":
+			if len(history) == 0 {
+				fmt.Fprintln(w, "No previous expression")
+				continue
+			}
+			s = history[len(history)-1]
+			fmt.Fprintln(w, s)
+		case strings.HasPrefix(s, "!"):
+			n, err := strconv.Atoi(s[1:])
+			if err != nil || n < 1 || n > len(history) {
+				fmt.Fprintln(w, "No such history entry:", s)
+				continue
+			}
+			s = history[n-1]
+			fmt.Fprintln(w, s)
 		}
-		fmt.Print("New unit: ")
-		var symbol string
-		scanner.Scan()
-		symbol = scanner.Text()
-		qu2, ok := qu1.ConvertTo(symbol)
-		if ok {
-			fmt.Println("New value:", qu2)
-		} else {
-			fmt.Println("Cannot convert")
+		history = append(history, s)
+		q, err := us.Eval(s)
+		if err != nil {
+			fmt.Fprintln(w, "Error:", err)
+			continue
 		}
+		fmt.Fprintln(w, out.render(q))
 	}
 }
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	us "github.com/imhotep-nb/units/quantity"
+)
+
+func TestRepl(t *testing.T) {
+	input := strings.Join([]string{"!!", "1 km in m", "", "2 ft + 6 in", "history", "!!", "!1", "!9", "3 zorp", "quit", "1 m"}, "\n")
+	var b strings.Builder
+	repl(strings.NewReader(input), &b, output{precision: -1})
+	expected := []string{
+		"Type an expression, e.g. 3 kg * 9.81 m/s2 in lbf, or 'quit' to exit the loop.",
+		"> No previous expression",
+		"> 1000.0000 m",
+		"> > 2.5000 ft",
+		">    1  1 km in m",
+		"   2  2 ft + 6 in",
+		"> 2 ft + 6 in",
+		"2.5000 ft",
+		"> 1 km in m",
+		"1000.0000 m",
+		"> No such history entry: !9",
+		"> Error: unknown unit [zorp]",
+		"> ",
+	}
+	if actual := strings.Split(b.String(), "\n"); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected:\n%s\nactual:\n%s", strings.Join(expected, "\n"), b.String())
+	}
+}
+
+func TestParseFlags(t *testing.T) {
+	data := []struct {
+		args     []string
+		out      output
+		expected []string
+	}{
+		{[]string{"-5", "degC", "in", "degF"}, output{precision: -1}, []string{"-5", "degC", "in", "degF"}},
+		{[]string{"-precision", "2", "-5", "degC"}, output{precision: 2}, []string{"-5", "degC"}},
+		{[]string{"-si", "-.5", "ft"}, output{precision: -1, si: true}, []string{"-.5", "ft"}},
+		{[]string{"-format=%.1f %s", "--", "-humanize"}, output{format: "%.1f %s", precision: -1}, []string{"-humanize"}},
+		{[]string{"-humanize", "matrix", "m", "ft"}, output{precision: -1, humanize: true}, []string{"matrix", "m", "ft"}},
+		{nil, output{precision: -1}, []string{}},
+	}
+	for _, d := range data {
+		out, args, err := parseFlags(d.args, io.Discard)
+		if err != nil || out != d.out || strings.Join(args, " ") != strings.Join(d.expected, " ") {
+			t.Error(d.args, "expected:", d.out, d.expected, "actual:", out, args, err)
+		}
+	}
+	for _, args := range [][]string{{"-zorp", "1 m"}, {"-precision"}, {"-precision", "x"}} {
+		if _, _, err := parseFlags(args, io.Discard); err == nil {
+			t.Error("expected an error for", args)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	q := us.Q(1500, "m")
+	data := []struct {
+		out      output
+		expected string
+	}{
+		{output{precision: -1}, "1500.0000 m"},
+		{output{precision: 1}, "1500.0 m"},
+		{output{format: "%.0f %s", precision: 1}, "1500 m"},
+		{output{precision: 2, humanize: true}, "0.93 mi"},
+		{output{precision: 0, si: true}, "1500 m"},
+	}
+	for _, d := range data {
+		if s := d.out.render(q); s != d.expected {
+			t.Error(d.out, "expected:", d.expected, "actual:", s)
+		}
+	}
+	if s := (output{precision: 3, si: true}).render(us.Q(1, "ft")); s != "0.305 m" {
+		t.Error("expected: 0.305 m, actual:", s)
+	}
+}
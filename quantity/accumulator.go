@@ -0,0 +1,79 @@
+package quantity
+
+import (
+	"fmt"
+	"math"
+)
+
+// Accumulator keeps a running sum of compatible quantities in SI units, e.g. for summing
+// millions of line items, without creating a Quantity or looking up a unit per operation.
+// The sum is compensated (Neumaier), so the rounding error does not grow with the number of
+// items. The zero value is an empty Accumulator; it is not safe for concurrent use.
+type Accumulator struct {
+	sum, compensation float64
+	exponents         [nBaseUnits]int8
+	n                 int
+	err               error
+}
+
+// Add adds q to the sum. The first Quantity sets the dimension of the sum; an incompatible
+// or undefined Quantity is not added and its error is returned by Result.
+func (a *Accumulator) Add(q Quantity) {
+	a.add(q, q.value*q.factor)
+}
+
+// Sub subtracts q from the sum, see Add.
+func (a *Accumulator) Sub(q Quantity) {
+	a.add(q, -q.value*q.factor)
+}
+
+func (a *Accumulator) add(q Quantity, v float64) {
+	switch {
+	case a.err != nil:
+		return
+	case !q.defined():
+		a.err = fmt.Errorf("item %d: %w", a.n, &IncompatibleUnitsError{A: q.unitSymbol(), B: makeSymbol(a.exponents)})
+		return
+	case a.n == 0:
+		a.exponents = q.exponents
+	case !haveSameExponents(a.exponents, q.exponents):
+		a.err = fmt.Errorf("item %d: %w", a.n, &IncompatibleUnitsError{A: q.symbol, B: makeSymbol(a.exponents)})
+		return
+	}
+	t := a.sum + v
+	if math.Abs(a.sum) >= math.Abs(v) {
+		a.compensation += (a.sum - t) + v
+	} else {
+		a.compensation += (v - t) + a.sum
+	}
+	a.sum = t
+	a.n++
+}
+
+// Len returns the number of quantities added or subtracted.
+func (a *Accumulator) Len() int {
+	return a.n
+}
+
+// Result returns the sum in the given unit, e.g. a.Result("km"). An error is returned if a
+// Quantity could not be added, the unit is unknown, or it is not compatible with the sum. The
+// sum of no quantities is 0 in any unit.
+func (a *Accumulator) Result(unit string) (Quantity, error) {
+	undef := Quantity{0, &UndefinedUnit}
+	if a.err != nil {
+		return undef, a.err
+	}
+	u := UnitFor(unit)
+	switch {
+	case u == &UndefinedUnit:
+		return undef, &UnknownUnitError{unit}
+	case a.n > 0 && !haveSameExponents(a.exponents, u.exponents):
+		return undef, &IncompatibleUnitsError{A: makeSymbol(a.exponents), B: unit}
+	}
+	return Quantity{a.sum + a.compensation, siUnit(u.exponents)}.Convert(u), nil
+}
+
+// Reset empties the Accumulator, so it can be reused.
+func (a *Accumulator) Reset() {
+	*a = Accumulator{}
+}
@@ -0,0 +1,194 @@
+// Package alert evaluates streams of quantities against threshold rules and calls back when
+// an alert fires or clears, e.g. for monitoring pressures, temperatures or speeds in whatever
+// units the sensors report.
+//
+// A rule fires when its condition becomes true and clears when the value is back on the other
+// side of the threshold by more than the hysteresis, so a value that hovers around the
+// threshold does not fire again and again.
+package alert
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	us "github.com/imhotep-nb/units/quantity"
+)
+
+// Comparator is the comparison of a rule: the value compared with the threshold.
+type Comparator string
+
+// Comparators of a Rule.
+const (
+	Above   Comparator = ">"
+	AtLeast Comparator = ">="
+	Below   Comparator = "<"
+	AtMost  Comparator = "<="
+)
+
+// Rule is a condition on the quantities of a dimension, e.g. pressure above 10 bar.
+type Rule struct {
+	Name       string
+	Dimension  string // a unit symbol of the dimension, e.g. "Pa"; "" for that of Threshold
+	Comparator Comparator
+	Threshold  us.Quantity
+	Hysteresis us.Quantity // the margin past the threshold to clear; zero value for none
+}
+
+// ParseRule returns a Rule with the condition in the syntax of us.ParseFilter, e.g.
+// ParseRule("overpressure", "> 10 bar", "0.5 bar"). The hysteresis may be "" for none.
+func ParseRule(name, condition, hysteresis string) (Rule, error) {
+	if _, err := us.ParseFilter(condition); err != nil {
+		return Rule{}, err
+	}
+	c := strings.TrimSpace(condition)
+	r := Rule{Name: name}
+	for _, op := range []Comparator{AtLeast, AtMost, Above, Below} {
+		if strings.HasPrefix(c, string(op)) {
+			r.Comparator = op
+			break
+		}
+	}
+	if r.Comparator == "" {
+		return Rule{}, fmt.Errorf("alert: condition %q of rule %q is not a comparison with a threshold", condition, name)
+	}
+	var err error
+	if r.Threshold, err = us.Parse(c[len(r.Comparator):]); err != nil {
+		return Rule{}, err
+	}
+	if hysteresis != "" {
+		if r.Hysteresis, err = us.Parse(hysteresis); err != nil {
+			return Rule{}, err
+		}
+	}
+	return r, nil
+}
+
+// Event is passed to the callback of an Engine when a rule fires or clears.
+type Event struct {
+	Rule   string
+	Value  us.Quantity
+	Firing bool // true if the rule fires, false if it clears
+}
+
+// ErrNoRule is returned by Evaluate if no rule has the dimension of the Quantity.
+var ErrNoRule = errors.New("no rule for the dimension")
+
+// Engine holds the rules and their state. It is safe for concurrent use; the callback is
+// called synchronously by Evaluate, in the order of the rule names.
+type Engine struct {
+	mu       sync.Mutex
+	rules    map[string]*rule
+	callback func(Event)
+}
+
+type rule struct {
+	Rule
+	firing bool
+}
+
+// New returns an Engine without rules that calls the callback for every Event.
+func New(callback func(Event)) *Engine {
+	return &Engine{rules: make(map[string]*rule), callback: callback}
+}
+
+// Add adds the rule, or replaces the rule with the same name, which resets its state. An error
+// is returned if the threshold is missing, the threshold or hysteresis do not have the
+// dimension of the rule, or the comparator or hysteresis is invalid.
+func (e *Engine) Add(r Rule) error {
+	if r.Threshold.Invalid() {
+		return fmt.Errorf("alert: missing threshold of rule %q", r.Name)
+	}
+	if r.Dimension == "" {
+		_, r.Dimension = r.Threshold.Split()
+	}
+	if err := us.ExpectDimension(r.Threshold, r.Dimension); err != nil {
+		return fmt.Errorf("alert: threshold of rule %q: %w", r.Name, err)
+	}
+	switch r.Comparator {
+	case Above, AtLeast, Below, AtMost:
+	default:
+		return fmt.Errorf("alert: invalid comparator %q of rule %q", r.Comparator, r.Name)
+	}
+	if r.Hysteresis.Invalid() {
+		r.Hysteresis = us.Q(0, r.Dimension)
+	} else if err := us.ExpectDimension(r.Hysteresis, r.Dimension); err != nil {
+		return fmt.Errorf("alert: hysteresis of rule %q: %w", r.Name, err)
+	} else if r.Hysteresis.Value() < 0 {
+		return fmt.Errorf("alert: negative hysteresis of rule %q", r.Name)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rules[r.Name] = &rule{Rule: r}
+	return nil
+}
+
+// Remove removes the rule with the name, if any.
+func (e *Engine) Remove(name string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.rules, name)
+}
+
+// Firing returns the sorted names of the rules that fired and have not cleared.
+func (e *Engine) Firing() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var names []string
+	for name, r := range e.rules {
+		if r.firing {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Evaluate evaluates the next value of a stream against the rules of its dimension and calls
+// the callback for the rules that fire or clear. It returns ErrNoRule if there are no rules
+// for the dimension of the Quantity, or an error if it has no unit.
+func (e *Engine) Evaluate(q us.Quantity) error {
+	if q.Invalid() {
+		return errors.New("alert: quantity without unit")
+	}
+	e.mu.Lock()
+	var events []Event
+	matched := false
+	for _, r := range e.rules {
+		if !us.AreCompatible(q, r.Threshold) {
+			continue
+		}
+		matched = true
+		if firing := r.next(q); firing != r.firing {
+			r.firing = firing
+			events = append(events, Event{r.Name, q, firing})
+		}
+	}
+	e.mu.Unlock()
+	if !matched {
+		return fmt.Errorf("alert: %w of %v", ErrNoRule, q)
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Rule < events[j].Rule })
+	for _, ev := range events {
+		if e.callback != nil {
+			e.callback(ev)
+		}
+	}
+	return nil
+}
+
+// next returns whether the rule is firing after the value q.
+func (r *rule) next(q us.Quantity) bool {
+	v, t, h := q.ToSI().Value(), r.Threshold.ToSI().Value(), r.Hysteresis.ToSI().Value()
+	switch r.Comparator {
+	case Above:
+		return v > t || r.firing && v >= t-h
+	case AtLeast:
+		return v >= t || r.firing && v > t-h
+	case Below:
+		return v < t || r.firing && v <= t+h
+	}
+	return v <= t || r.firing && v < t+h
+}
@@ -0,0 +1,80 @@
+package alert
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	us "github.com/imhotep-nb/units/quantity"
+)
+
+func TestEngine(t *testing.T) {
+	var events []string
+	e := New(func(ev Event) {
+		events = append(events, fmt.Sprintf("%s %v %s", ev.Rule, ev.Firing, ev.Value.Format("%.1f %s")))
+	})
+	pressure, err := ParseRule("overpressure", "> 10 bar", "0.5 bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Add(pressure); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Add(Rule{Name: "frost", Comparator: AtMost, Threshold: us.Q(0, "degC")}); err != nil {
+		t.Fatal(err)
+	}
+	stream := []us.Quantity{
+		us.Q(9, "bar"), us.Q(10.2, "bar"), us.Q(9.8, "bar"), us.Q(150, "psi"), us.Q(9.4, "bar"),
+		us.Q(1, "MPa"), us.Q(1, "degC"), us.Q(0, "degC"), us.Q(0.1, "degC"),
+	}
+	for _, q := range stream {
+		if err := e.Evaluate(q); err != nil {
+			t.Error(err)
+		}
+	}
+	expected := []string{
+		"overpressure true 10.2 bar", "overpressure false 9.4 bar",
+		"frost true 0.0 degC", "frost false 0.1 degC",
+	}
+	if !reflect.DeepEqual(events, expected) {
+		t.Error("expected:", expected, "actual:", events)
+	}
+	e.Evaluate(us.Q(11, "bar"))
+	if f := e.Firing(); !reflect.DeepEqual(f, []string{"overpressure"}) {
+		t.Error("expected overpressure firing, actual:", f)
+	}
+	if err := e.Evaluate(us.Q(3, "m")); !errors.Is(err, ErrNoRule) {
+		t.Error("expected ErrNoRule, actual:", err)
+	}
+	if err := e.Evaluate(us.Quantity{}); err == nil {
+		t.Error("expected an error for a quantity without unit")
+	}
+	e.Remove("overpressure")
+	if err := e.Evaluate(us.Q(11, "bar")); !errors.Is(err, ErrNoRule) {
+		t.Error("expected ErrNoRule after Remove, actual:", err)
+	}
+}
+
+func TestInvalidRules(t *testing.T) {
+	e := New(nil)
+	rules := []Rule{
+		{Name: "a", Dimension: "Pa", Comparator: Above, Threshold: us.Q(3, "m")},
+		{Name: "b", Comparator: "=", Threshold: us.Q(3, "m")},
+		{Name: "c", Comparator: Below, Threshold: us.Q(3, "m"), Hysteresis: us.Q(1, "s")},
+		{Name: "d", Comparator: Below, Threshold: us.Q(3, "m"), Hysteresis: us.Q(-1, "m")},
+		{Name: "e", Comparator: Below},
+	}
+	for _, r := range rules {
+		if err := e.Add(r); err == nil {
+			t.Error("expected an error for rule", r.Name)
+		}
+	}
+	for _, c := range [][2]string{{"= 3 m", ""}, {"1..2 m", ""}, {"> 3 m", "2 s"}, {"> 3 zorp", ""}} {
+		if r, err := ParseRule("x", c[0], c[1]); err == nil {
+			if err = e.Add(r); err == nil {
+				t.Error("expected an error for", c)
+			}
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package quantity
+
+import (
+	"fmt"
+	"math"
+)
+
+// NormalizeAngle wraps the angle into the full turn starting at rangeStart, i.e. into
+// [rangeStart, rangeStart+360°). Use Q(0, "rad") for [0, 2π) and Q(-180, "deg") for
+// [-180°, 180°). The result has the unit of q. An error is returned if q or rangeStart is
+// not an angle.
+func NormalizeAngle(q, rangeStart Quantity) (Quantity, error) {
+	if err := ExpectDimension(q, "rad"); err != nil {
+		return q, err
+	}
+	if err := ExpectDimension(rangeStart, "rad"); err != nil {
+		return q, err
+	}
+	start := rangeStart.ToSI().value
+	r := math.Mod(q.ToSI().value-start, 2*math.Pi)
+	if r < 0 {
+		r += 2 * math.Pi
+	}
+	if r >= 2*math.Pi { // rounding of a tiny negative remainder
+		r = 0
+	}
+	return Quantity{(r + start) / q.factor, q.Unit}, nil
+}
+
+// DMS is an angle in degrees, minutes and seconds of arc, e.g. 52°22′26.4″.
+type DMS struct {
+	Negative bool
+	Deg, Min int
+	Sec      float64
+}
+
+// String formats the angle with the seconds to one decimal, e.g. "-4°53′12.0″". The seconds
+// are rounded before they are carried into the minutes and degrees, so 59′59.96″ is shown as
+// "1°0′0.0″" and not as "0°59′60.0″".
+func (a DMS) String() string {
+	sign := ""
+	if a.Negative {
+		sign = "-"
+	}
+	deg, min, sec := a.Deg, a.Min, math.Round(a.Sec*10)/10
+	if sec >= 60 {
+		sec -= 60
+		min++
+	}
+	if min >= 60 {
+		min -= 60
+		deg++
+	}
+	return fmt.Sprintf("%s%d°%d′%.1f″", sign, deg, min, sec)
+}
+
+// DegMinSec decomposes the angle into whole degrees, whole minutes and seconds of arc, e.g.
+// 52°22′26.4″ for 52.374 deg. An error is returned if q is not an angle.
+func DegMinSec(q Quantity) (DMS, error) {
+	if err := ExpectDimension(q, "deg"); err != nil {
+		return DMS{}, err
+	}
+	d, _ := q.ConvertTo("deg")
+	v := math.Abs(d.value)
+	// round to a microsecond of arc first, so 0.5 deg gives 30′0″ and not 29′59.999…″
+	secs := math.Round(v*3600*1e6) / 1e6
+	deg := math.Floor(secs / 3600)
+	min := math.Floor((secs - deg*3600) / 60)
+	return DMS{d.value < 0, int(deg), int(min), secs - deg*3600 - min*60}, nil
+}
@@ -0,0 +1,116 @@
+// Package atmosphere computes the conditions of the International Standard Atmosphere (ISA,
+// ISO 2533) at a given altitude: temperature, pressure, density and speed of sound.
+//
+// Altitudes are geopotential altitudes above mean sea level, from -610 m up to 47 km, the
+// troposphere and stratosphere.
+package atmosphere
+
+import (
+	"fmt"
+	"math"
+
+	us "github.com/imhotep-nb/units/quantity"
+)
+
+// Constants of the standard atmosphere.
+const (
+	g0 = 9.80665   // standard gravity, m/s2
+	r  = 287.05287 // specific gas constant of dry air, J/(kg·K)
+	k  = 1.4       // ratio of specific heats of dry air
+
+	SeaLevelTemperature = 288.15 // K
+	SeaLevelPressure    = 101325 // Pa
+	MinAltitude         = -610   // m
+	MaxAltitude         = 47000  // m
+)
+
+// layer is a layer of the atmosphere with a constant temperature lapse rate.
+type layer struct {
+	base     float64 // altitude, m
+	lapse    float64 // K/m
+	temp     float64 // at the base, K
+	pressure float64 // at the base, Pa
+}
+
+// layers lists the layers by base altitude; the first extends down to MinAltitude.
+var layers = makeLayers([]struct{ base, lapse float64 }{
+	{0, -0.0065},    // troposphere
+	{11000, 0},      // tropopause
+	{20000, 0.001},  // stratosphere
+	{32000, 0.0028}, // stratosphere
+})
+
+func makeLayers(defs []struct{ base, lapse float64 }) []layer {
+	ls := make([]layer, len(defs))
+	l := layer{0, defs[0].lapse, SeaLevelTemperature, SeaLevelPressure}
+	for i, d := range defs {
+		if i > 0 {
+			t, p := l.at(d.base)
+			l = layer{d.base, d.lapse, t, p}
+		}
+		ls[i] = l
+	}
+	return ls
+}
+
+// at returns the temperature and pressure at altitude h in the layer.
+func (l layer) at(h float64) (float64, float64) {
+	t := l.temp + l.lapse*(h-l.base)
+	if l.lapse == 0 {
+		return t, l.pressure * math.Exp(-g0*(h-l.base)/(r*l.temp))
+	}
+	return t, l.pressure * math.Pow(t/l.temp, -g0/(l.lapse*r))
+}
+
+// Conditions are the conditions of the standard atmosphere at an altitude.
+type Conditions struct {
+	Temperature us.Quantity // K
+	Pressure    us.Quantity // Pa
+	Density     us.Quantity // kg/m3
+	SoundSpeed  us.Quantity // m/s
+}
+
+// At returns the conditions at the altitude, e.g. 223.15 K, 26436 Pa, 0.4127 kg/m3 and
+// 299.5 m/s at 10 km. An error is returned if altitude is not a length or is outside
+// MinAltitude to MaxAltitude.
+func At(altitude us.Quantity) (Conditions, error) {
+	if err := us.ExpectDimension(altitude, "m"); err != nil {
+		return Conditions{}, err
+	}
+	h := altitude.In("m").Value()
+	if !(h >= MinAltitude && h <= MaxAltitude) {
+		return Conditions{}, fmt.Errorf("altitude out of range %d..%d m: %v", MinAltitude, MaxAltitude, altitude)
+	}
+	l := layers[0]
+	for _, next := range layers[1:] {
+		if h < next.base {
+			break
+		}
+		l = next
+	}
+	t, p := l.at(h)
+	return Conditions{
+		Temperature: us.Q(t, "K"),
+		Pressure:    us.Q(p, "Pa"),
+		Density:     us.Q(p/(r*t), "kg/m3"),
+		SoundSpeed:  us.Q(math.Sqrt(k*r*t), "m/s"),
+	}, nil
+}
+
+// Temperature returns the temperature at the altitude in K, see At.
+func Temperature(altitude us.Quantity) (us.Quantity, error) {
+	c, err := At(altitude)
+	return c.Temperature, err
+}
+
+// Pressure returns the pressure at the altitude in Pa, see At.
+func Pressure(altitude us.Quantity) (us.Quantity, error) {
+	c, err := At(altitude)
+	return c.Pressure, err
+}
+
+// Density returns the air density at the altitude in kg/m3, see At.
+func Density(altitude us.Quantity) (us.Quantity, error) {
+	c, err := At(altitude)
+	return c.Density, err
+}
@@ -0,0 +1,57 @@
+package atmosphere
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	us "github.com/imhotep-nb/units/quantity"
+)
+
+func TestAt(t *testing.T) {
+	// reference values from the ISA tables
+	data := []struct {
+		altitude                us.Quantity
+		temp, pressure, density float64
+		soundSpeed              float64
+	}{
+		{us.Q(0, "m"), 288.15, 101325, 1.2250, 340.29},
+		{us.Q(-500, "m"), 291.40, 107478, 1.2849, 342.21},
+		{us.Q(10, "km"), 223.15, 26436, 0.41271, 299.46},
+		{us.Q(36089, "ft"), 216.65, 22632, 0.36392, 295.07},
+		{us.Q(20, "km"), 216.65, 5474.9, 0.088035, 295.07},
+		{us.Q(32, "km"), 228.65, 868.02, 0.013225, 303.13},
+		{us.Q(47, "km"), 270.65, 110.91, 0.0014275, 329.80},
+	}
+	for _, d := range data {
+		c, err := At(d.altitude)
+		if err != nil {
+			t.Fatal(d.altitude, err)
+		}
+		for _, v := range []struct {
+			q        us.Quantity
+			symbol   string
+			expected float64
+		}{
+			{c.Temperature, "K", d.temp},
+			{c.Pressure, "Pa", d.pressure},
+			{c.Density, "kg/m3", d.density},
+			{c.SoundSpeed, "m/s", d.soundSpeed},
+		} {
+			if !v.q.HasCompatibleUnit(v.symbol) || math.Abs(v.q.In(v.symbol).Value()/v.expected-1) > 1e-4 {
+				t.Error(d.altitude, "expected:", v.expected, v.symbol, "actual:", v.q)
+			}
+		}
+	}
+	if p, err := Pressure(us.Q(5000, "ft")); err != nil || math.Round(p.In("hPa").Value()) != 843 {
+		t.Error("expected: 843 hPa, actual:", p, err)
+	}
+	for _, q := range []us.Quantity{us.Q(-1, "km"), us.Q(50, "km"), us.Q(math.NaN(), "m")} {
+		if _, err := At(q); err == nil {
+			t.Error("expected an error for", q)
+		}
+	}
+	if _, err := Density(us.Q(1, "s")); !errors.Is(err, us.ErrIncompatibleUnits) {
+		t.Error("expected: incompatible units error, actual:", err)
+	}
+}
@@ -0,0 +1,167 @@
+package quantity
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// isoCurrencies lists the active ISO 4217 currency codes.
+const isoCurrencies = `AED AFN ALL AMD ANG AOA ARS AUD AWG AZN BAM BBD BDT BGN BHD BIF BMD BND BOB BRL
+BSD BTN BWP BYN BZD CAD CDF CHF CLP CNY COP CRC CUP CVE CZK DJF DKK DOP DZD EGP ERN ETB EUR
+FJD FKP GBP GEL GHS GIP GMD GNF GTQ GYD HKD HNL HTG HUF IDR ILS INR IQD IRR ISK JMD JOD JPY
+KES KGS KHR KMF KPW KRW KWD KYD KZT LAK LBP LKR LRD LSL LYD MAD MDL MGA MKD MMK MNT MOP MRU
+MUR MVR MWK MXN MYR MZN NAD NGN NIO NOK NPR NZD OMR PAB PEN PGK PHP PKR PLN PYG QAR RON RSD
+RUB RWF SAR SBD SCR SDG SEK SGD SHP SLE SOS SRD SSP STN SVC SYP SZL THB TJS TMT TND TOP TRY
+TTD TWD TZS UAH UGX USD UYU UZS VES VND VUV WST XAF XCD XOF XPF YER ZAR ZMW ZWL`
+
+// DefineISOCurrencies adds all ISO 4217 currency codes, e.g. "EUR" and "JPY", to the unit
+// table as money units with factor 1, so "250 EUR" can be parsed. Codes that already exist
+// are left as they are. Exchange rates still have to be set for conversions. After
+// FreezeRegistry it returns ErrRegistryFrozen.
+func DefineISOCurrencies() error {
+	if registryFrozen() {
+		return fmt.Errorf("%w: cannot define the ISO currencies", ErrRegistryFrozen)
+	}
+	for _, code := range strings.Fields(isoCurrencies) {
+		if _, found := units[code]; !found {
+			units[code] = &Unit{code, 1, dimOf("¤")}
+		}
+	}
+	return nil
+}
+
+// referenceCurrencies are the money units with the factor 1 that rates are relative to.
+var referenceCurrencies = map[string]bool{"¤": true, "$": true, "USD": true}
+
+// SetCurrencyRate sets the exchange rate of a registered currency as the amount of it per US
+// dollar, e.g. SetCurrencyRate("NZD", 1.65) for 1 USD = 1.65 NZD, so that UnitFor, Parse,
+// Convert and RegistryRates use it, for services that refresh their rates, e.g. hourly. The
+// rates are kept in a table that is replaced in one step, so it is safe to call while other
+// goroutines convert, also after FreezeRegistry, and no conversion mixes the old and new
+// rate. The units calculated from the currency, e.g. "NZD/kWh", are recalculated.
+// Quantities created before hold the old unit and keep converting with the old rate. Rates
+// are not exact, see IsExact: their uncertainty is half a unit in the last decimal of
+// perUSD. An error is returned if the code is not a registered currency, e.g. before
+// DefineISOCurrencies, is a reference currency ("USD", "$" or "¤"), or the rate is not a
+// positive number.
+func SetCurrencyRate(code string, perUSD float64) error {
+	if err := expectMoney(code); err != nil {
+		return err
+	}
+	if referenceCurrencies[code] {
+		return fmt.Errorf("cannot set rate of reference currency %s", code)
+	}
+	if !(perUSD > 0) || math.IsInf(perUSD, 0) {
+		return fmt.Errorf("invalid rate for %s: %g", code, perUSD)
+	}
+	ratesMu.Lock()
+	defer ratesMu.Unlock()
+	old := loadRates()
+	t := &rateTable{make(map[string]currencyRate, len(old.rates)+1)}
+	for c, r := range old.rates {
+		t.rates[c] = r
+	}
+	t.rates[code] = currencyRate{&Unit{code, 1 / perUSD, dimOf("¤")}, perUSD}
+	rates.Store(t)
+	return nil
+}
+
+// unratedCurrency reports whether the symbol is a registered currency without a rate: not a
+// reference currency, without a rate set by SetCurrencyRate and with the placeholder factor
+// 1 of DefineISOCurrencies. Conversions with it are not exact, see IsExact.
+func unratedCurrency(symbol string) bool {
+	u := units[symbol]
+	return u != nil && u.factor == 1 && u.exponents == dimOf("¤") && !referenceCurrencies[symbol] &&
+		ratedUnit(symbol) == nil
+}
+
+// rates holds the *rateTable with the rates set by SetCurrencyRate. A table is never changed
+// after it is stored, so it is read without locks.
+var (
+	rates   atomic.Value
+	ratesMu sync.Mutex // serializes SetCurrencyRate
+)
+
+type rateTable struct {
+	rates map[string]currencyRate
+}
+
+type currencyRate struct {
+	unit   *Unit
+	perUSD float64 // as given to SetCurrencyRate
+}
+
+func loadRates() *rateTable {
+	t, _ := rates.Load().(*rateTable)
+	if t == nil {
+		return &rateTable{}
+	}
+	return t
+}
+
+// ratedUnit returns the unit of a currency with a rate set by SetCurrencyRate, or nil. It
+// takes precedence over the unit in the unit table.
+func ratedUnit(symbol string) *Unit {
+	t, _ := rates.Load().(*rateTable)
+	if t == nil {
+		return nil
+	}
+	return t.rates[symbol].unit
+}
+
+// ratedParsed caches the units calculated from symbols with a rated currency, e.g. "NZD/kWh",
+// with the rate table they were calculated with, so that they are calculated again after
+// SetCurrencyRate.
+var ratedParsed sync.Map
+
+type ratedEntry struct {
+	rates *rateTable
+	unit  *Unit
+}
+
+// ratedUnitFor is UnitFor for a symbol that is not registered: it returns the cached unit if
+// it does not depend on the rates or was calculated with the current rates, or else parses
+// the symbol and caches the result with cache.
+func ratedUnitFor(symbol string, cache func(*Unit) *Unit) *Unit {
+	t := loadRates()
+	if e, found := ratedParsed.Load(symbol); found && e.(ratedEntry).rates == t {
+		return e.(ratedEntry).unit
+	}
+	q, rated, err := parseRated(symbol, NewParseOptions())
+	if err != nil {
+		return &UndefinedUnit
+	}
+	if rated {
+		ratedParsed.Store(symbol, ratedEntry{t, q.Unit})
+		return q.Unit
+	}
+	return cache(q.Unit)
+}
+
+// dimOf returns the exponents of a registered unit.
+func dimOf(symbol string) [nBaseUnits]int8 {
+	return units[symbol].exponents
+}
+
+// Cost returns the price of an amount of energy at a tariff in money per energy unit, e.g.
+// 87.50 $ for 350 kWh at 0.25 $/kWh. The result is in the currency of the tariff if its
+// unit is written as currency/energy, otherwise in "¤". An error is returned if energy is
+// not an energy or the tariff is not a price per energy unit.
+func Cost(energy, tariff Quantity) (Quantity, error) {
+	if err := ExpectDimension(energy, "J"); err != nil {
+		return Quantity{}, err
+	}
+	if err := ExpectDimension(tariff, "¤/J"); err != nil {
+		return Quantity{}, err
+	}
+	cost := Mult(energy, tariff)
+	if i := strings.IndexByte(tariff.symbol, '/'); i != -1 {
+		if c, ok := cost.ConvertTo(tariff.symbol[:i]); ok {
+			return c, nil
+		}
+	}
+	return cost, nil
+}
@@ -7,42 +7,63 @@ import (
 func setup() []*Unit {
 	// keep alphabetic order!
 	// only define quantities here that have a unit symbol that is not a combination of existing unit symbols
-	acceleration := def(&[nBaseUnits]int8{meter: 1, second: -2})
-	angle := def(&[nBaseUnits]int8{radian: 1})
-	angularVelocity := def(&[nBaseUnits]int8{radian: 1, second: -1})
-	area := def(&[nBaseUnits]int8{meter: 2})
-	capacitance := def(&[nBaseUnits]int8{ampere: 2, second: 4, kilogram: -1, meter: -2})
-	duration := def(&[nBaseUnits]int8{second: 1})
-	electricCharge := def(&[nBaseUnits]int8{ampere: 1, second: 1})
-	electricCurrent := def(&[nBaseUnits]int8{ampere: 1})
-	electricResistance := def(&[nBaseUnits]int8{kilogram: 1, meter: 2, ampere: -2, second: -3})
-	energy := def(&[nBaseUnits]int8{kilogram: 1, meter: 2, second: -2})
-	force := def(&[nBaseUnits]int8{kilogram: 1, meter: 1, second: -2})
-	frequency := def(&[nBaseUnits]int8{second: -1})
-	fuelEfficiency := def(&[nBaseUnits]int8{meter: 2})
-	illuminance := def(&[nBaseUnits]int8{candela: 1, steradian: 1, meter: -2})
-	information := def(&[nBaseUnits]int8{byte: 1})
-	length := def(&[nBaseUnits]int8{meter: 1})
-	luminousFlux := def(&[nBaseUnits]int8{candela: 1, steradian: 1})
-	luminousIntensity := def(&[nBaseUnits]int8{candela: 1})
-	mass := def(&[nBaseUnits]int8{kilogram: 1})
-	matter := def(&[nBaseUnits]int8{mole: 1})
-	money := def(&[nBaseUnits]int8{currency: 1})
-	power := def(&[nBaseUnits]int8{kilogram: 1, meter: 2, second: -3})
-	pressure := def(&[nBaseUnits]int8{kilogram: 1, meter: -1, second: -2})
-	solidAngle := def(&[nBaseUnits]int8{steradian: 1})
-	speed := def(&[nBaseUnits]int8{meter: 1, second: -1})
-	temperature := def(&[nBaseUnits]int8{kelvin: 1})
-	unitless := def(&[nBaseUnits]int8{})
-	voltage := def(&[nBaseUnits]int8{meter: 2, kilogram: 1, second: -3, ampere: -1})
-	volume := def(&[nBaseUnits]int8{meter: 3})
+	absorbedDose := def("absorbed dose", &[nBaseUnits]int8{meter: 2, second: -2})
+	acceleration := def("acceleration", &[nBaseUnits]int8{meter: 1, second: -2})
+	angle := def("angle", &[nBaseUnits]int8{radian: 1})
+	angularVelocity := def("angular velocity", &[nBaseUnits]int8{radian: 1, second: -1})
+	area := def("area", &[nBaseUnits]int8{meter: 2})
+	capacitance := def("capacitance", &[nBaseUnits]int8{ampere: 2, second: 4, kilogram: -1, meter: -2})
+	catalyticActivity := def("catalytic activity", &[nBaseUnits]int8{mole: 1, second: -1})
+	counting := def("count", &[nBaseUnits]int8{count: 1})
+	duration := def("duration", &[nBaseUnits]int8{second: 1})
+	dynamicViscosity := def("dynamic viscosity", &[nBaseUnits]int8{kilogram: 1, meter: -1, second: -1})
+	electricCharge := def("electric charge", &[nBaseUnits]int8{ampere: 1, second: 1})
+	electricConductance := def("electric conductance", &[nBaseUnits]int8{ampere: 2, second: 3, kilogram: -1, meter: -2})
+	electricCurrent := def("electric current", &[nBaseUnits]int8{ampere: 1})
+	electricResistance := def("electric resistance", &[nBaseUnits]int8{kilogram: 1, meter: 2, ampere: -2, second: -3})
+	energy := def("energy", &[nBaseUnits]int8{kilogram: 1, meter: 2, second: -2})
+	force := def("force", &[nBaseUnits]int8{kilogram: 1, meter: 1, second: -2})
+	def("heat transfer coefficient", &[nBaseUnits]int8{kilogram: 1, second: -3, kelvin: -1})
+	frequency := def("frequency", &[nBaseUnits]int8{second: -1})
+	fuelEfficiency := def("fuel efficiency", &[nBaseUnits]int8{meter: 2})
+	illuminance := def("illuminance", &[nBaseUnits]int8{candela: 1, steradian: 1, meter: -2})
+	inductance := def("inductance", &[nBaseUnits]int8{kilogram: 1, meter: 2, second: -2, ampere: -2})
+	information := def("information", &[nBaseUnits]int8{octet: 1})
+	kinematicViscosity := def("kinematic viscosity", &[nBaseUnits]int8{meter: 2, second: -1})
+	length := def("length", &[nBaseUnits]int8{meter: 1})
+	luminousFlux := def("luminous flux", &[nBaseUnits]int8{candela: 1, steradian: 1})
+	luminousIntensity := def("luminous intensity", &[nBaseUnits]int8{candela: 1})
+	magneticFlux := def("magnetic flux", &[nBaseUnits]int8{kilogram: 1, meter: 2, second: -2, ampere: -1})
+	magneticFluxDensity := def("magnetic flux density", &[nBaseUnits]int8{kilogram: 1, second: -2, ampere: -1})
+	mass := def("mass", &[nBaseUnits]int8{kilogram: 1})
+	massFlow := def("mass flow", &[nBaseUnits]int8{kilogram: 1, second: -1})
+	matter := def("matter", &[nBaseUnits]int8{mole: 1})
+	money := def("money", &[nBaseUnits]int8{currency: 1})
+	power := def("power", &[nBaseUnits]int8{kilogram: 1, meter: 2, second: -3})
+	pressure := def("pressure", &[nBaseUnits]int8{kilogram: 1, meter: -1, second: -2})
+	radioactivity := def("radioactivity", &[nBaseUnits]int8{second: -1})
+	resolution := def("resolution", &[nBaseUnits]int8{meter: -1})
+	solidAngle := def("solid angle", &[nBaseUnits]int8{steradian: 1})
+	def("specific heat capacity", &[nBaseUnits]int8{meter: 2, second: -2, kelvin: -1})
+	speed := def("speed", &[nBaseUnits]int8{meter: 1, second: -1})
+	temperature := def("temperature", &[nBaseUnits]int8{kelvin: 1})
+	def("thermal conductivity", &[nBaseUnits]int8{kilogram: 1, meter: 1, second: -3, kelvin: -1})
+	unitless := def("dimensionless", &[nBaseUnits]int8{})
+	voltage := def("voltage", &[nBaseUnits]int8{meter: 2, kilogram: 1, second: -3, ampere: -1})
+	volume := def("volume", &[nBaseUnits]int8{meter: 3})
+	volumeFlow := def("volume flow", &[nBaseUnits]int8{meter: 3, second: -1})
 
 	return []*Unit{
 		// define only basic unit symbols here, no derived symbols like m/s2, lb/cu ft
 
 		unitless("", 1),
+		unitless("bp", 1e-4), // basis point, 0.01 %
+
+		absorbedDose("Gy", 1), // gray
+		absorbedDose("Sv", 1), // sievert, equivalent dose
 
 		acceleration("G", 9.80665), //Earth's gravity constant
+		acceleration("Gal", 0.01),  // gal, CGS
 
 		angle("rad", 1),           // radians
 		angle("deg", math.Pi/180), // degrees (360deg per full circle)
@@ -59,21 +80,36 @@ func setup() []*Unit {
 
 		capacitance("F", 1), // farad
 
+		catalyticActivity("kat", 1), // katal
+
+		counting("count", 1),
+		counting("dozen", 12),
+		counting("gross", 144), // 12 dozen
+		counting("score", 20),
+
 		duration("s", 1),
 		duration("min", 60),
 		duration("h", 3600),
+		duration("hr", 3600),
 		duration("d", 24*3600),
 
+		dynamicViscosity("P", 0.1), // poise, CGS
+
 		electricCharge("C", 1),
 
+		electricConductance("S", 1), // siemens
+
 		electricCurrent("A", 1),
 
 		electricResistance("Ω", 1),
 
-		energy("J", 1), // joule
+		energy("J", 1),      // joule
+		energy("erg", 1e-7), // CGS
 		energy("kWh", 3.6e6),
+		energy("BTU", 1055.05585262), // British thermal unit, international table
 
 		force("N", 1),                 // newton
+		force("dyn", 1e-5),            // dyne, CGS
 		force("lbf", 4.4482216152605), // pound force
 
 		frequency("Hz", 1), // hertz
@@ -82,6 +118,8 @@ func setup() []*Unit {
 
 		illuminance("lx", 1),
 
+		inductance("H", 1), // henry
+
 		information("bit", 0.125),
 		information("byte", 1),
 		information("KiB", 1024),    // note: KB is 1000
@@ -90,6 +128,8 @@ func setup() []*Unit {
 		information("TiB", 1099511627776),
 		information("PiB", 1125899906842624),
 
+		kinematicViscosity("St", 1e-4), // stokes, CGS
+
 		length("m", 1), // meter, metre
 		length("mi", 1609.344), // mile
 		length("in", 0.0254),   // inch
@@ -100,6 +140,10 @@ func setup() []*Unit {
 		luminousFlux("lm", 1),      // lumen
 		luminousIntensity("cd", 1), // candela
 
+		magneticFlux("Wb", 1),              // weber
+		magneticFluxDensity("T", 1),        // tesla
+		magneticFluxDensity("gauss", 1e-4), // CGS
+
 		mass("kg", 1),              // kilogram
 		mass("g", 0.001),           // gram
 		mass("t", 1000),            // tonne, metric ton
@@ -109,13 +153,19 @@ func setup() []*Unit {
 		mass("short ton", 907.18474),
 		mass("long ton", 1016.04691),
 		mass("st", 6.35029318), // stone
+		mass("grain", 6.479891e-5), // "gr" is ambiguous, see ambiguousSymbols
+		mass("ct", 2e-4),           // metric carat
+		mass("oz t", 0.0311034768), // troy ounce
+		mass("slug", 4.4482216152605/0.3048),
+
+		massFlow("tph", 1000.0/3600), // tonne per hour
 
 		matter("mol", 1),
 
-		money("¤", 1),      // generic currency symbol
-		money("$", 1),      // dollar
-		money("USD", 1),    // US dollar
-		money("NZD", 1.57), // todo: use conversion table updated by function
+		money("¤", 1),   // generic currency symbol
+		money("$", 1),   // dollar
+		money("USD", 1), // US dollar
+		money("NZD", 1), // New Zealand dollar, no rate until SetCurrencyRate
 
 		power("W", 1), // watts
 		power("hp", 745.699872), // horsepower
@@ -128,6 +178,11 @@ func setup() []*Unit {
 		pressure("mmHg", 133.322387415), // millimeter mercury
 		pressure("cmHg", 1333.22387415), // centimeter mercury
 
+		radioactivity("Bq", 1), // becquerel
+
+		resolution("dpi", 1/0.0254), // dots per inch
+		resolution("ppi", 1/0.0254), // pixels per inch
+
 		solidAngle("sr", 1), // steradian
 
 		speed("kph", 1000.0/3600.0),   // kilometer per hour, alt unit
@@ -137,14 +192,22 @@ func setup() []*Unit {
 		temperature("K", 1), // kelvin
 		temperature("degC", 1), // degree celsius, relative temperature
 		temperature("degF", 5.0/9), // degree fahrenheit, relative temperature
+		temperature("°C", 1),       // degree celsius, relative temperature
+		temperature("°F", 5.0/9),   // degree fahrenheit, relative temperature
 
 		voltage("V", 1), // volt
 
-		volume("cu ft", 35.3146665722),           // cubic foot
+		volume("cu ft", 0.028316846592),          // cubic foot
 		volume("L", 1e-3),                        // liter
 		volume("us gal", 0.003785411784),         // US gallon
-		volume("imp gal", 0.00454609188),         // Imperial gallon
+		volume("imp gal", 0.00454609),            // Imperial gallon
 		volume("us fl oz", 0.0000295735295625),   // US fluid ounce
-		volume("imp fl oz", 0.00002841307424375), // Imperial fluid ounce
+		volume("imp fl oz", 0.0000284130625),     // Imperial fluid ounce
+
+		volumeFlow("CFM", 0.028316846592/60), // cubic foot per minute
+		// SCFM is an alias of CFM: converting an actual flow to standard conditions needs the
+		// pressure and temperature of the gas, so the caller has to do that before using it.
+		volumeFlow("SCFM", 0.028316846592/60), // standard cubic foot per minute
+		volumeFlow("GPM", 0.003785411784/60),  // US gallon per minute
 	}
 }
@@ -0,0 +1,106 @@
+package quantity
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// dimensionNames maps exponent vectors to the name of the physical quantity,
+// e.g. "pressure". It is filled by def in setup; the first name defined for a
+// vector wins.
+var dimensionNames = make(map[[nBaseUnits]int8]string)
+
+var superscripts = strings.NewReplacer(
+	"-", "⁻", "0", "⁰", "1", "¹", "2", "²", "3", "³", "4", "⁴",
+	"5", "⁵", "6", "⁶", "7", "⁷", "8", "⁸", "9", "⁹")
+
+// DimensionName returns the name of the physical quantity the unit measures,
+// e.g. "speed" for "km/h", or "" if the dimension has no name.
+func (u Unit) DimensionName() string {
+	return dimensionNames[u.exponents]
+}
+
+// prettySymbol returns the SI symbol for the exponents using a middle dot and
+// superscript exponents, e.g. "m⁻¹·kg·s⁻²".
+func prettySymbol(expon [nBaseUnits]int8) string {
+	var a []string
+	for i := 0; i < nBaseUnits; i++ {
+		if e := expon[i]; e != 0 {
+			s := baseSymbols[i]
+			if e != 1 {
+				s += superscripts.Replace(strconv.Itoa(int(e)))
+			}
+			a = append(a, s)
+		}
+	}
+	return strings.Join(a, "·")
+}
+
+func describeDimension(expon [nBaseUnits]int8) string {
+	name, sym := dimensionNames[expon], prettySymbol(expon)
+	switch {
+	case name == "":
+		return sym
+	case sym == "":
+		return name
+	}
+	return name + " (" + sym + ")"
+}
+
+// CompatibleUnits returns the sorted symbols of the registered units with the same dimension
+// as the given unit, including the unit itself if it is registered, e.g. for a list of the
+// units a pressure in "Pa" can be converted to. The result is nil if the unit is unknown.
+func CompatibleUnits(symbol string) []string {
+	u := UnitFor(symbol)
+	if u == &UndefinedUnit {
+		return nil
+	}
+	symbols := []string{}
+	for _, s := range Symbols() {
+		if units[s].exponents == u.exponents {
+			symbols = append(symbols, s)
+		}
+	}
+	return symbols
+}
+
+// DimensionVector returns the exponents of the SI base units and the SI factor of the unit,
+// e.g. [1 0 0 0 0 0 0 0 0 0 -1 0] and 0.2778 for "km/h", for tools that use units as
+// features or in schemas. The order of the exponents is that of BaseUnits. An
+// UnknownUnitError is returned if the unit is unknown.
+func DimensionVector(symbol string) ([nBaseUnits]int8, float64, error) {
+	u := UnitFor(symbol)
+	if u == &UndefinedUnit {
+		return [nBaseUnits]int8{}, 0, &UnknownUnitError{symbol}
+	}
+	return u.exponents, u.factor, nil
+}
+
+// ExpectDimension checks that the Quantity has a unit compatible with the given unit
+// symbol. It returns nil if so, otherwise an error describing both dimensions, e.g.
+// "got pressure (m⁻¹·kg·s⁻²), want speed (m·s⁻¹)". Use it at API boundaries.
+func ExpectDimension(q Quantity, symbol string) error {
+	want := UnitFor(symbol)
+	if want == &UndefinedUnit {
+		return &UnknownUnitError{symbol}
+	}
+	if !q.defined() {
+		return &IncompatibleUnitsError{q.unitSymbol(), symbol,
+			"got undefined unit, want " + describeDimension(want.exponents)}
+	}
+	if !haveSameExponents(q.exponents, want.exponents) {
+		return &IncompatibleUnitsError{q.symbol, symbol,
+			fmt.Sprintf("got %s, want %s", describeDimension(q.exponents), describeDimension(want.exponents))}
+	}
+	return nil
+}
+
+// MustBe is like ExpectDimension but panics if the Quantity is not compatible with
+// the given unit symbol. It returns the Quantity so calls can be chained.
+func MustBe(q Quantity, symbol string) Quantity {
+	if err := ExpectDimension(q, symbol); err != nil {
+		panic(err)
+	}
+	return q
+}
@@ -0,0 +1,423 @@
+package quantity
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"strconv"
+)
+
+// gobUnit is the exported representation of a Unit used for gob encoding.
+type gobUnit struct {
+	Symbol    string
+	Factor    float64
+	Exponents []int8
+}
+
+type gobQuantity struct {
+	Value float64
+	Unit  gobUnit
+}
+
+func (u Unit) toGob() gobUnit {
+	return gobUnit{u.symbol, u.factor, u.exponents[:]}
+}
+
+func unitFromGob(g gobUnit) Unit {
+	u := Unit{symbol: g.Symbol, factor: g.Factor}
+	copy(u.exponents[:], g.Exponents)
+	return u
+}
+
+func gobEncode(v interface{}) ([]byte, error) {
+	var b bytes.Buffer
+	if err := gob.NewEncoder(&b).Encode(v); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// GobEncode implements the gob.GobEncoder interface.
+func (u Unit) GobEncode() ([]byte, error) {
+	return gobEncode(u.toGob())
+}
+
+// GobDecode implements the gob.GobDecoder interface.
+func (u *Unit) GobDecode(data []byte) error {
+	var g gobUnit
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&g); err != nil {
+		return err
+	}
+	*u = unitFromGob(g)
+	return nil
+}
+
+// GobEncode implements the gob.GobEncoder interface.
+func (m Quantity) GobEncode() ([]byte, error) {
+	var u Unit
+	if m.Unit != nil {
+		u = *m.Unit
+	}
+	return gobEncode(gobQuantity{m.value, u.toGob()})
+}
+
+// GobDecode implements the gob.GobDecoder interface.
+func (m *Quantity) GobDecode(data []byte) error {
+	var g gobQuantity
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&g); err != nil {
+		return err
+	}
+	m.value, m.Unit = g.Value, sharedUnit(unitFromGob(g.Unit))
+	return nil
+}
+
+// sharedUnit returns the registered unit if it equals u, so decoded quantities share it,
+// otherwise a pointer to u. The zero Unit, written for a Quantity without a unit, gives
+// nil.
+func sharedUnit(u Unit) *Unit {
+	if u == (Unit{}) {
+		return nil
+	}
+	if r := units[u.symbol]; r != nil && *r == u {
+		return r
+	}
+	return &u
+}
+
+// binaryVersion is the first byte of the output of MarshalBinary.
+const binaryVersion = 1
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface with a compact format: a
+// version byte, the value and the SI factor as little endian float64, the exponents as
+// varints and the symbol prefixed by its length as uvarint. A quantity in "km/h" takes 34
+// bytes.
+func (m Quantity) MarshalBinary() ([]byte, error) {
+	if m.Unit == nil {
+		m.Unit = &Unit{}
+	}
+	b := make([]byte, 17, 17+nBaseUnits+1+len(m.symbol))
+	b[0] = binaryVersion
+	binary.LittleEndian.PutUint64(b[1:], math.Float64bits(m.value))
+	binary.LittleEndian.PutUint64(b[9:], math.Float64bits(m.factor))
+	var buf [binary.MaxVarintLen64]byte
+	for _, e := range m.exponents {
+		b = append(b, buf[:binary.PutVarint(buf[:], int64(e))]...)
+	}
+	b = append(b, buf[:binary.PutUvarint(buf[:], uint64(len(m.symbol)))]...)
+	return append(b, m.symbol...), nil
+}
+
+var errBinaryFormat = errors.New("invalid binary quantity")
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface for the format
+// written by MarshalBinary.
+func (m *Quantity) UnmarshalBinary(data []byte) error {
+	if len(data) < 17 || data[0] != binaryVersion {
+		return errBinaryFormat
+	}
+	var u Unit
+	value := math.Float64frombits(binary.LittleEndian.Uint64(data[1:]))
+	u.factor = math.Float64frombits(binary.LittleEndian.Uint64(data[9:]))
+	data = data[17:]
+	for i := range u.exponents {
+		e, n := binary.Varint(data)
+		if n <= 0 || e < math.MinInt8 || e > math.MaxInt8 {
+			return errBinaryFormat
+		}
+		u.exponents[i], data = int8(e), data[n:]
+	}
+	l, n := binary.Uvarint(data)
+	if n <= 0 || uint64(len(data)-n) != l {
+		return errBinaryFormat
+	}
+	u.symbol = string(data[n:])
+	*m = Quantity{value, sharedUnit(u)}
+	return nil
+}
+
+// jsonQuantity is the JSON representation of a Quantity, e.g. {"value":1.5,"unit":"km"}.
+type jsonQuantity struct {
+	Value float64 `json:"value"`
+	Unit  string  `json:"unit"`
+}
+
+// MarshalJSON implements the json.Marshaler interface. A Quantity is encoded as an object
+// with the value and the unit symbol, e.g. {"value":1.5,"unit":"km"}, so no precision is
+// lost. The zero Quantity, which has no unit, is encoded as null; quantities with the
+// UndefinedUnit cannot be encoded.
+func (m Quantity) MarshalJSON() ([]byte, error) {
+	if m.Unit == nil {
+		return []byte("null"), nil
+	}
+	if !m.defined() {
+		return nil, &UnknownUnitError{m.unitSymbol()}
+	}
+	return json.Marshal(jsonQuantity{m.value, m.symbol})
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface. Both the object written by
+// MarshalJSON and a string accepted by Parse, e.g. "1.5 km", are decoded, and null gives
+// the zero Quantity.
+func (m *Quantity) UnmarshalJSON(data []byte) error {
+	if string(bytes.TrimSpace(data)) == "null" {
+		*m = Quantity{}
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		q, err := Parse(s)
+		if err != nil {
+			return err
+		}
+		*m = q
+		return nil
+	}
+	var j jsonQuantity
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	u := UnitFor(j.Unit)
+	if u == &UndefinedUnit {
+		return &UnknownUnitError{j.Unit}
+	}
+	*m = Quantity{j.Value, u}
+	return nil
+}
+
+// MarshalJSON implements the json.Marshaler interface. The Quantities are encoded as an
+// array of objects, see Quantity.MarshalJSON. An error names the index of the first
+// Quantity that cannot be encoded.
+func (a Quantities) MarshalJSON() ([]byte, error) {
+	if a == nil {
+		return []byte("null"), nil
+	}
+	var b bytes.Buffer
+	b.WriteByte('[')
+	for i, q := range a {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		data, err := q.MarshalJSON()
+		if err != nil {
+			return nil, fmt.Errorf("element %d: %w", i, err)
+		}
+		b.Write(data)
+	}
+	b.WriteByte(']')
+	return b.Bytes(), nil
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface. The elements of the array can be
+// objects or strings, see Quantity.UnmarshalJSON. An error names the index of the first
+// element that cannot be decoded.
+func (a *Quantities) UnmarshalJSON(data []byte) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if raw == nil {
+		*a = nil
+		return nil
+	}
+	qs := make(Quantities, len(raw))
+	for i, r := range raw {
+		if err := qs[i].UnmarshalJSON(r); err != nil {
+			return fmt.Errorf("element %d: %w", i, err)
+		}
+	}
+	*a = qs
+	return nil
+}
+
+// ParseQuantities parses each string with Parse, e.g. the measurements in a request. An
+// error names the index of the first string that cannot be parsed.
+func ParseQuantities(ss []string) (Quantities, error) {
+	qs := make(Quantities, len(ss))
+	for i, s := range ss {
+		q, err := Parse(s)
+		if err != nil {
+			return nil, fmt.Errorf("element %d: %w", i, err)
+		}
+		qs[i] = q
+	}
+	return qs, nil
+}
+
+// LoadDefinitions reads unit definitions in JSON and adds them with DefineAll, e.g. from a
+// configuration file. The JSON is an object with the new symbols as keys. A value is either
+// a definition {"factor": 201.168, "base": "m"} or, for an alias, the symbol of a unit, e.g.
+// "furlong". Either all units are defined, or none of them are and an error is returned.
+func LoadDefinitions(r io.Reader) error {
+	var raw map[string]json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return fmt.Errorf("unit definitions: %w", err)
+	}
+	defs := make(map[string]Definition, len(raw))
+	for symbol, data := range raw {
+		var alias string
+		if json.Unmarshal(data, &alias) == nil {
+			defs[symbol] = Definition{1, alias}
+			continue
+		}
+		var d Definition
+		if err := json.Unmarshal(data, &d); err != nil {
+			return fmt.Errorf("unit definition %s: %w", symbol, err)
+		}
+		if d.Factor == 0 || d.Base == "" {
+			return fmt.Errorf("unit definition %s: missing factor or base", symbol)
+		}
+		defs[symbol] = d
+	}
+	return DefineAll(defs)
+}
+
+// jsonUnit is the JSON representation of a registered unit in a RegistrySnapshot.
+type jsonUnit struct {
+	Factor    float64 `json:"factor"`
+	Exponents []int8  `json:"exponents"`
+}
+
+// jsonScale is the JSON representation of an OrdinalScale in a RegistrySnapshot.
+type jsonScale struct {
+	Unit   string    `json:"unit"`
+	First  int       `json:"first"`
+	Bounds []float64 `json:"bounds"`
+}
+
+// jsonRegistry is the JSON representation of a RegistrySnapshot.
+type jsonRegistry struct {
+	Units  map[string]jsonUnit  `json:"units"`
+	Exact  map[string]string    `json:"exact,omitempty"`
+	Scales map[string]jsonScale `json:"scales,omitempty"`
+	Rates  map[string]float64   `json:"rates,omitempty"`
+}
+
+// MarshalJSON encodes the snapshot as an object with the units by symbol, the exact factors
+// as decimals or fractions, the ordinal scales by name and the currency rates per US
+// dollar, e.g.
+//
+//	{"units":{"km":{"factor":1000,"exponents":[1,0,0,0,0,0,0,0,0,0,0,0]},...},
+//	 "exact":{"ft":"0.3048",...},"scales":{"beaufort":{"unit":"m/s",...},...},
+//	 "rates":{"NZD":1.65}}
+//
+// so it can be shipped to other runtimes, e.g. a browser, and restored there with
+// UnmarshalJSON and RestoreRegistry.
+func (s RegistrySnapshot) MarshalJSON() ([]byte, error) {
+	j := jsonRegistry{
+		Units:  make(map[string]jsonUnit, len(s.units)),
+		Exact:  s.exactDecimals,
+		Scales: make(map[string]jsonScale, len(s.scales)),
+	}
+	for symbol, u := range s.units {
+		j.Units[symbol] = jsonUnit{u.factor, u.exponents[:]}
+	}
+	for name, scale := range s.scales {
+		j.Scales[name] = jsonScale{scale.Unit, scale.First, scale.Bounds}
+	}
+	if s.rates != nil && len(s.rates.rates) > 0 {
+		j.Rates = make(map[string]float64, len(s.rates.rates))
+		for code, r := range s.rates.rates {
+			j.Rates[code] = r.perUSD
+		}
+	}
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON decodes a snapshot encoded by MarshalJSON. An error is returned for factors
+// that are zero or not finite, for more exponents than there are base units, for exact
+// factors that are not numbers, for scales with bounds that are not ascending and for rates
+// of currencies that are not in the snapshot or are not positive numbers.
+func (s *RegistrySnapshot) UnmarshalJSON(data []byte) error {
+	var j jsonRegistry
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	if j.Units == nil {
+		return errors.New("missing units in registry")
+	}
+	r := RegistrySnapshot{
+		units:         make(map[string]*Unit, len(j.Units)),
+		exactDecimals: make(map[string]string, len(j.Exact)),
+		scales:        make(map[string]OrdinalScale, len(j.Scales)),
+		rates:         &rateTable{make(map[string]currencyRate, len(j.Rates))},
+	}
+	for symbol, ju := range j.Units {
+		if ju.Factor == 0 || math.IsInf(ju.Factor, 0) || math.IsNaN(ju.Factor) || len(ju.Exponents) > nBaseUnits {
+			return fmt.Errorf("invalid unit in registry: %s", symbol)
+		}
+		u := &Unit{symbol: symbol, factor: ju.Factor}
+		copy(u.exponents[:], ju.Exponents)
+		r.units[symbol] = u
+	}
+	for symbol, d := range j.Exact {
+		if _, ok := new(big.Rat).SetString(d); !ok {
+			return fmt.Errorf("invalid exact factor in registry: %s %s", symbol, d)
+		}
+		r.exactDecimals[symbol] = d
+	}
+	for name, js := range j.Scales {
+		for i := 1; i < len(js.Bounds); i++ {
+			if js.Bounds[i] <= js.Bounds[i-1] {
+				return fmt.Errorf("bounds of scale %s not ascending: %v", name, js.Bounds)
+			}
+		}
+		r.scales[name] = OrdinalScale{name, js.Unit, js.First, js.Bounds}
+	}
+	money := r.units["¤"]
+	for code, perUSD := range j.Rates {
+		u := r.units[code]
+		if u == nil || money == nil || u.exponents != money.exponents || !(perUSD > 0) || math.IsInf(perUSD, 0) {
+			return fmt.Errorf("invalid rate in registry: %s %g", code, perUSD)
+		}
+		r.rates.rates[code] = currencyRate{&Unit{code, 1 / perUSD, money.exponents}, perUSD}
+	}
+	*s = r
+	return nil
+}
+
+// UnitVector is a registered unit as a row of numbers: 1 Symbol = Factor SI units, with the
+// exponents of the SI base units, see DimensionVector.
+type UnitVector struct {
+	Symbol    string
+	Factor    float64
+	Exponents [nBaseUnits]int8
+}
+
+// UnitVectors returns all registered units sorted by symbol, see Symbols, e.g. to embed
+// units in feature engineering or to publish them in a schema registry.
+func UnitVectors() []UnitVector {
+	symbols := Symbols()
+	vectors := make([]UnitVector, len(symbols))
+	for i, symbol := range symbols {
+		u := units[symbol]
+		vectors[i] = UnitVector{symbol, u.factor, u.exponents}
+	}
+	return vectors
+}
+
+// WriteUnitVectors writes UnitVectors as CSV with a header, e.g.
+//
+//	symbol,factor,m,kg,K,A,cd,mol,rad,sr,¤,byte,s,count
+//	km,1000,1,0,0,0,0,0,0,0,0,0,0,0
+//
+// Factors are written in the shortest form that parses back to the same float64.
+func WriteUnitVectors(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write(append([]string{"symbol", "factor"}, BaseUnits()...))
+	for _, v := range UnitVectors() {
+		row := []string{v.Symbol, strconv.FormatFloat(v.Factor, 'g', -1, 64)}
+		for _, e := range v.Exponents {
+			row = append(row, strconv.Itoa(int(e)))
+		}
+		cw.Write(row)
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,92 @@
+package quantity
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf8"
+)
+
+// Errors that can be checked with errors.Is.
+var (
+	// ErrUnknownUnit is matched by UnknownUnitError.
+	ErrUnknownUnit = errors.New("unknown unit")
+	// ErrIncompatibleUnits is matched by IncompatibleUnitsError.
+	ErrIncompatibleUnits = errors.New("units not compatible")
+	// ErrSyntax is matched by SyntaxError.
+	ErrSyntax = errors.New("invalid syntax")
+	// ErrDuplicateSymbol is returned when defining a unit symbol that already exists.
+	ErrDuplicateSymbol = errors.New("duplicate symbol")
+	// ErrCircularDefinition is returned when new units are defined in terms of each other.
+	ErrCircularDefinition = errors.New("circular definition")
+	// ErrNotDimensionless is returned when a dimensionless quantity is required.
+	ErrNotDimensionless = errors.New("not dimensionless")
+	// ErrExponentOverflow is returned when an exponent of a calculated unit is outside the
+	// range -128..127, e.g. for m100 * m100.
+	ErrExponentOverflow = errors.New("exponent overflow")
+	// ErrInvalidExponent is returned when a quantity with a dimension is raised to a power
+	// that gives a fractional exponent, e.g. the square root of a length.
+	ErrInvalidExponent = errors.New("invalid exponent")
+	// ErrRegistryFrozen is returned when defining a unit or registering a scale after
+	// FreezeRegistry.
+	ErrRegistryFrozen = errors.New("registry frozen")
+)
+
+// UnknownUnitError reports a unit symbol that is not registered and cannot be calculated.
+type UnknownUnitError struct {
+	Symbol string
+}
+
+func (e *UnknownUnitError) Error() string {
+	return "unknown unit [" + e.Symbol + "]"
+}
+
+// Is makes errors.Is(err, ErrUnknownUnit) true.
+func (e *UnknownUnitError) Is(target error) bool {
+	return target == ErrUnknownUnit
+}
+
+// IncompatibleUnitsError reports two units with different dimensions.
+type IncompatibleUnitsError struct {
+	A, B string // unit symbols
+	msg  string
+}
+
+func (e *IncompatibleUnitsError) Error() string {
+	if e.msg != "" {
+		return e.msg
+	}
+	return fmt.Sprintf("units not compatible: [%s] <> [%s]", e.A, e.B)
+}
+
+// Is makes errors.Is(err, ErrIncompatibleUnits) true.
+func (e *IncompatibleUnitsError) Is(target error) bool {
+	return target == ErrIncompatibleUnits
+}
+
+// SyntaxError reports text input that cannot be parsed.
+type SyntaxError struct {
+	Input  string // the text being parsed
+	Reason string // what is wrong
+}
+
+func (e *SyntaxError) Error() string {
+	return e.Reason + " [" + e.Input + "]"
+}
+
+// Is makes errors.Is(err, ErrSyntax) true.
+func (e *SyntaxError) Is(target error) bool {
+	return target == ErrSyntax
+}
+
+// abbreviate returns the start of input that is too long to repeat in a SyntaxError, at most
+// 16 bytes cut at a rune boundary, followed by "...".
+func abbreviate(s string) string {
+	n := 16
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + "..."
+}
@@ -0,0 +1,210 @@
+package quantity
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Eval evaluates an arithmetic expression of quantities, e.g. "3 kg * 9.81 m/s2 in lbf" or
+// "(5 ft + 3 in) / 2". In EBNF, with quantity as defined for Parse but without group
+// separators and uncertainty:
+//
+//	input      = expression [ "in" unit ] .
+//	expression = term { ( "+" | "-" ) term } .
+//	term       = factor { ( "*" | "/" ) factor } .
+//	factor     = [ "-" ] ( quantity | "(" expression ")" [ "^" integer ] ) .
+//
+// A slash between spaces divides, a slash in a unit is part of the unit, so "10 m / 2 s" and
+// "5 m/s" are both speeds. The unit of a quantity can consist of several words, e.g.
+// "us gal". Sums and differences are in the unit of the left operand, products and
+// quotients in SI units, unless the result is converted with the "in" suffix.
+func Eval(s string) (Quantity, error) {
+	undef := Quantity{0, &UndefinedUnit}
+	if len(s) > MaxInputLength {
+		return undef, &SyntaxError{abbreviate(s), "input too long"}
+	}
+	expr, target := s, ""
+	if i := strings.LastIndex(s, " in "); i != -1 {
+		if symbol := strings.TrimSpace(s[i+4:]); UnitFor(symbol) != &UndefinedUnit {
+			expr, target = s[:i], symbol
+		}
+	}
+	e := evaluator{input: s, s: expr}
+	q, err := e.expression()
+	if err != nil {
+		return undef, err
+	}
+	if e.skipSpace(); e.pos < len(e.s) {
+		return undef, e.errorf("unexpected " + strconv.Quote(e.s[e.pos:e.pos+1]) + " in")
+	}
+	if target != "" {
+		c, ok := q.ConvertTo(target)
+		if !ok {
+			return undef, &IncompatibleUnitsError{A: q.symbol, B: target}
+		}
+		q = c
+	}
+	return q, nil
+}
+
+// evaluator is a recursive descent parser for Eval.
+type evaluator struct {
+	input, s string // the whole input and the expression without the "in" suffix
+	pos      int
+}
+
+func (e *evaluator) errorf(reason string) error {
+	return &SyntaxError{e.input, reason}
+}
+
+func (e *evaluator) skipSpace() {
+	for e.pos < len(e.s) && (e.s[e.pos] == ' ' || e.s[e.pos] == '\t') {
+		e.pos++
+	}
+}
+
+// next returns the next character after white space, or 0 at the end.
+func (e *evaluator) next() byte {
+	e.skipSpace()
+	if e.pos == len(e.s) {
+		return 0
+	}
+	return e.s[e.pos]
+}
+
+func (e *evaluator) expression() (Quantity, error) {
+	q, err := e.term()
+	for err == nil {
+		op := e.next()
+		if op != '+' && op != '-' {
+			break
+		}
+		e.pos++
+		var r Quantity
+		if r, err = e.term(); err != nil {
+			break
+		}
+		if !AreCompatible(q, r) {
+			return q, &IncompatibleUnitsError{A: q.symbol, B: r.symbol}
+		}
+		if op == '-' {
+			r = Neg(r)
+		}
+		q = Add(q, r).Convert(q.Unit)
+	}
+	return q, err
+}
+
+func (e *evaluator) term() (Quantity, error) {
+	q, err := e.factor()
+	for err == nil {
+		op := e.next()
+		if op != '*' && op != '/' {
+			break
+		}
+		e.pos++
+		var r Quantity
+		if r, err = e.factor(); err != nil {
+			break
+		}
+		if op == '*' {
+			q = Mult(q, r)
+		} else {
+			q = Div(q, r)
+		}
+	}
+	return q, err
+}
+
+func (e *evaluator) factor() (Quantity, error) {
+	switch e.next() {
+	case '-':
+		e.pos++
+		q, err := e.factor()
+		return Neg(q), err
+	case '(':
+		e.pos++
+		q, err := e.expression()
+		if err != nil {
+			return q, err
+		}
+		if e.next() != ')' {
+			return q, e.errorf("missing ) in")
+		}
+		e.pos++
+		if e.next() == '^' {
+			e.pos++
+			e.skipSpace()
+			start := e.pos
+			for e.pos < len(e.s) && (e.s[e.pos] == '-' && e.pos == start || isDigit(e.s[e.pos])) {
+				e.pos++
+			}
+			n, err := strconv.ParseInt(e.s[start:e.pos], 10, 8)
+			if err != nil {
+				return q, e.errorf("invalid exponent in")
+			}
+			q = Power(q, int8(n))
+		}
+		return q, nil
+	}
+	return e.quantity()
+}
+
+// quantity parses a number and the longest unit of up to 3 words after it.
+func (e *evaluator) quantity() (Quantity, error) {
+	start := e.pos
+	for e.pos < len(e.s) && (isDigit(e.s[e.pos]) || e.s[e.pos] == '.') {
+		e.pos++
+	}
+	if e.pos < len(e.s) && e.pos > start && (e.s[e.pos] == 'e' || e.s[e.pos] == 'E') {
+		end := e.pos + 1
+		if end < len(e.s) && (e.s[end] == '-' || e.s[end] == '+') {
+			end++
+		}
+		if end < len(e.s) && isDigit(e.s[end]) {
+			for e.pos = end; e.pos < len(e.s) && isDigit(e.s[e.pos]); e.pos++ {
+			}
+		}
+	}
+	if e.pos == start {
+		if e.pos == len(e.s) {
+			return Quantity{}, e.errorf("missing number in")
+		}
+		return Quantity{}, e.errorf("unexpected " + strconv.Quote(e.s[e.pos:e.pos+1]) + " in")
+	}
+	value, err := strconv.ParseFloat(e.s[start:e.pos], 64)
+	if err != nil {
+		return Quantity{}, e.errorf("invalid number in")
+	}
+	var words []string
+	var ends []int
+	for pos := e.pos; len(words) < 3; {
+		for pos < len(e.s) && (e.s[pos] == ' ' || e.s[pos] == '\t') {
+			pos++
+		}
+		w := pos
+		for pos < len(e.s) && !unicode.IsSpace(rune(e.s[pos])) && !strings.ContainsRune("+*()", rune(e.s[pos])) {
+			pos++
+		}
+		if pos == w || strings.ContainsRune("/-.0123456789", rune(e.s[w])) {
+			break // an operator or a number, not a unit
+		}
+		words = append(words, e.s[w:pos])
+		ends = append(ends, pos)
+	}
+	for n := len(words); n > 0; n-- {
+		if u := UnitFor(strings.Join(words[:n], " ")); u != &UndefinedUnit {
+			e.pos = ends[n-1]
+			return Quantity{value, u}, nil
+		}
+	}
+	if len(words) > 0 {
+		return Quantity{}, &UnknownUnitError{words[0]}
+	}
+	return Quantity{value, UnitFor("")}, nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
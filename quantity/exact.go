@@ -0,0 +1,310 @@
+package quantity
+
+import (
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// exactDecimals holds the exact factors of the registered units that are defined as exact
+// ratios, e.g. "ft" as 0.3048 m. Units with an integer factor, e.g. "h", are exact too.
+var exactDecimals = map[string]string{
+	"in": "0.0254", "ft": "0.3048", "yd": "0.9144", "mi": "1609.344",
+	"sq in": "0.00064516", "sq ft": "0.09290304", "sq mi": "2589988.110336", "acre": "4046.8564224",
+	"cu ft": "0.028316846592", "L": "0.001", "us gal": "0.003785411784", "imp gal": "0.00454609",
+	"us fl oz": "0.0000295735295625", "imp fl oz": "0.0000284130625",
+	"g": "0.001", "lb": "0.45359237", "lbs": "0.45359237", "oz": "0.028349523125",
+	"short ton": "907.18474", "st": "6.35029318", "lbf": "4.4482216152605",
+	"dyn": "0.00001", "erg": "0.0000001", "P": "0.1", "St": "0.0001", "gauss": "0.0001", "Gal": "0.01",
+	"G": "9.80665", "BTU": "1055.05585262", "mmHg": "133.322387415", "cmHg": "1333.22387415",
+	"kph": "5/18", "mph": "0.44704", "kn": "463/900", "tph": "5/18",
+	"degF": "5/9", "°F": "5/9", "bit": "0.125", "dpi": "5000/127", "ppi": "5000/127",
+	"CFM": "0.0004719474432", "SCFM": "0.0004719474432", "GPM": "0.0000630901964",
+	"L/100km": "0.00000001", "bp": "0.0001",
+	"grain": "0.00006479891", "ct": "0.0002", "oz t": "0.0311034768", "slug": "44482216152605/3048000000000",
+}
+
+// definedFactors holds the registered units whose factors are exact by definition but not
+// rational, e.g. "deg" as π/180 rad.
+var definedFactors = map[string]bool{"deg": true, "cycles": true, "rpm": true}
+
+// exactCache caches exactFactor by symbol and factor, so a redefined unit is looked up again.
+// exactCacheMu guards exactCache and exactDecimals, which Define adds to.
+var (
+	exactCache   = make(map[exactKey]*big.Rat)
+	exactCacheMu sync.RWMutex
+)
+
+type exactKey struct {
+	symbol string
+	factor float64
+}
+
+// QRat returns a Quantity with the value num/den in the unit of the given symbol, e.g.
+// QRat(1, 3, "h") for 20 minutes. The value is the float64 nearest to the ratio. It panics
+// if den is 0 or the unit is undefined, like Q.
+func QRat(num, den int64, symbol string) Quantity {
+	if den == 0 {
+		panic("zero denominator")
+	}
+	v, _ := big.NewRat(num, den).Float64()
+	return Q(v, symbol)
+}
+
+// ExactFactor returns the factor of the unit to SI units as a reduced fraction, e.g. 381/1250
+// for "ft", if the unit is defined as an exact ratio: the units of length, area, volume and
+// mass of the imperial and US systems, units with integer factors such as "h", and units
+// made of these, e.g. "mi/h". The result is false for other units, e.g. "deg", or if the
+// fraction does not fit in int64. Conversions between units with exact factors calculate
+// the ratio of the factors exactly, so that e.g. 1 ft is exactly 12 in.
+func (u Unit) ExactFactor() (num, den int64, ok bool) {
+	r := exactFactor(u.symbol, u.factor)
+	if r == nil || !r.Num().IsInt64() || !r.Denom().IsInt64() {
+		return 0, 0, false
+	}
+	return r.Num().Int64(), r.Denom().Int64(), true
+}
+
+// exactRatio returns the ratio of the factors of the units from and to, and true if both
+// factors are exact.
+func exactRatio(from, to *Unit) (float64, bool) {
+	if from.factor == to.factor {
+		return 1, true
+	}
+	f, t := exactFactor(from.symbol, from.factor), exactFactor(to.symbol, to.factor)
+	if f == nil || t == nil {
+		return 0, false
+	}
+	r, _ := new(big.Rat).Quo(f, t).Float64()
+	return r, true
+}
+
+// conversionRatio returns the number to multiply a value in the unit from with to get the
+// value in the unit to, rounded once: the exact ratio if both factors are exact, otherwise
+// the ratio of the factors. The ratio is calculated once per pair of units, as units are
+// never changed.
+func conversionRatio(from, to *Unit) float64 {
+	if from.factor == to.factor {
+		return 1
+	}
+	key := ratioKey{from, to}
+	ratioCacheMu.RLock()
+	r, found := ratioCache[key]
+	ratioCacheMu.RUnlock()
+	if found {
+		return r
+	}
+	r, ok := exactRatio(from, to)
+	if !ok {
+		r = from.factor / to.factor
+	}
+	ratioCacheMu.Lock()
+	if len(ratioCache) >= maxRatioCache {
+		ratioCache = make(map[ratioKey]float64) // units replaced by SetCurrencyRate are dropped too
+	}
+	ratioCache[key] = r
+	ratioCacheMu.Unlock()
+	return r
+}
+
+// ratioCache caches conversionRatio by pair of units, up to maxRatioCache pairs.
+var (
+	ratioCache   = make(map[ratioKey]float64)
+	ratioCacheMu sync.RWMutex
+)
+
+const maxRatioCache = 4096
+
+type ratioKey struct {
+	from, to *Unit
+}
+
+// unitRoundoff is the maximum relative error of rounding a real number to a float64.
+const unitRoundoff = 0x1p-53
+
+// MaxRelativeError returns the bound of the relative error of converting a value from one
+// unit to the other with Convert, ConvertTo or In: 0 if the units have the same factor,
+// 2^-53 (about 1.1e-16) if the ratio of the factors is exactly a float64, e.g. 12 for "ft" to
+// "in", and 2^-52 otherwise, as the ratio and the product are both rounded once. For units
+// with exact factors, see ExactFactor, the bound is relative to the exact result; for other
+// units it is relative to the registered factors, which are themselves rounded. A round trip
+// has at most twice the error. The bound holds as long as the result is neither subnormal nor
+// infinite. The result is NaN if a unit is unknown or the units are not compatible.
+func MaxRelativeError(from, to string) float64 {
+	f, t := UnitFor(from), UnitFor(to)
+	if f == &UndefinedUnit || t == &UndefinedUnit || !haveSameExponents(f.exponents, t.exponents) {
+		return math.NaN()
+	}
+	var r *big.Rat
+	ef, et := exactFactor(f.symbol, f.factor), exactFactor(t.symbol, t.factor)
+	if ef != nil && et != nil {
+		r = new(big.Rat).Quo(ef, et)
+	} else {
+		r = new(big.Rat).Quo(new(big.Rat).SetFloat64(f.factor), new(big.Rat).SetFloat64(t.factor))
+	}
+	switch _, exact := r.Float64(); {
+	case r.Cmp(big.NewRat(1, 1)) == 0:
+		return 0
+	case exact:
+		return unitRoundoff
+	}
+	return 2 * unitRoundoff
+}
+
+// IsExact reports whether the conversion between the units is exact by definition, e.g. "ft"
+// to "in" or "deg" to "rad", rather than based on a measured or rounded factor, e.g. "psi"
+// (6894.75729 Pa), an exchange rate or the placeholder factor of a currency without a rate,
+// see DefineISOCurrencies. Exact conversions still round the result to a float64, see
+// MaxRelativeError. The result is false if a unit is unknown or the units are not compatible.
+func IsExact(from, to string) bool {
+	f, t := UnitFor(from), UnitFor(to)
+	if f == &UndefinedUnit || t == &UndefinedUnit || !haveSameExponents(f.exponents, t.exponents) {
+		return false
+	}
+	uf, ut := factorUncertainty(f.symbol), factorUncertainty(t.symbol)
+	return f == t || uf == 0 && ut == 0 || f.factor == t.factor && !math.IsInf(uf, 0) && !math.IsInf(ut, 0)
+}
+
+// factorUncertainty returns the bound of the relative error of the factor of a unit: 0 if it
+// is exact by definition, otherwise half a unit in the last decimal of the registered
+// factors, e.g. 7.3e-10 for "psi", multiplied by the exponents of a compound symbol. The
+// result is +Inf for a currency without a rate and NaN if a part of the symbol is unknown.
+func factorUncertainty(symbol string) float64 {
+	s := normalizeSpace(symbol)
+	if r, found := loadRates().rates[s]; found {
+		return decimalUncertainty(r.perUSD)
+	}
+	if unratedCurrency(s) {
+		return math.Inf(1)
+	}
+	if u, found := units[s]; found {
+		if definedFactors[s] || exactFactor(s, u.factor) != nil {
+			return 0
+		}
+		return decimalUncertainty(u.factor)
+	}
+	sum := 0.0
+	s = symbolReplacer.Replace(s)
+	for _, part := range strings.Split(s, "/") {
+		for _, symbol := range strings.Split(unparen(part), ".") {
+			match := symbolRx.FindStringSubmatch(symbol)
+			if len(match) != 3 {
+				return math.NaN()
+			}
+			base := match[1]
+			if units[base] == nil {
+				if _, b, ok := prefix(base); ok {
+					base = b
+				}
+			}
+			if units[base] == nil {
+				return math.NaN()
+			}
+			x := 1
+			if match[2] != "" {
+				x, _ = strconv.Atoi(match[2])
+			}
+			sum += math.Abs(float64(x)) * factorUncertainty(base)
+		}
+	}
+	return sum
+}
+
+// decimalUncertainty returns half a unit in the last digit of the shortest decimal of f,
+// relative to f, e.g. 0.005/1.57 for 1.57.
+func decimalUncertainty(f float64) float64 {
+	s := strconv.FormatFloat(math.Abs(f), 'e', -1, 64)
+	mantissa, exp, _ := strings.Cut(s, "e")
+	e, _ := strconv.Atoi(exp)
+	if i := strings.IndexByte(mantissa, '.'); i >= 0 {
+		e -= len(mantissa) - i - 1
+	}
+	return 0.5 * math.Pow(10, float64(e)) / math.Abs(f)
+}
+
+// exactFactor returns the exact factor of the unit with the symbol and factor, or nil.
+func exactFactor(symbol string, factor float64) *big.Rat {
+	key := exactKey{symbol, factor}
+	exactCacheMu.RLock()
+	r, found := exactCache[key]
+	exactCacheMu.RUnlock()
+	if found {
+		return r
+	}
+	r = calculateExactFactor(symbol)
+	if r != nil {
+		// the parts of a symbol may have been redefined since its factor was calculated
+		if f, _ := r.Float64(); math.Abs(f-factor) > 1e-12*math.Abs(factor) {
+			r = nil
+		}
+	}
+	exactCacheMu.Lock()
+	exactCache[key] = r
+	exactCacheMu.Unlock()
+	return r
+}
+
+// calculateExactFactor returns the exact factor of a registered unit, or calculates it from
+// the parts of a compound symbol like ParseSymbol. The result is nil if a part is not exact.
+func calculateExactFactor(symbol string) *big.Rat {
+	s := normalizeSpace(symbol)
+	if ratedUnit(s) != nil || unratedCurrency(s) {
+		return nil // exchange rates are not exact
+	}
+	if u, found := units[s]; found {
+		exactCacheMu.RLock()
+		d, found := exactDecimals[s]
+		exactCacheMu.RUnlock()
+		if found {
+			r, _ := new(big.Rat).SetString(d)
+			return r
+		}
+		return exactFloat(u.factor)
+	}
+	result := big.NewRat(1, 1)
+	s = symbolReplacer.Replace(s)
+	for i, part := range strings.Split(s, "/") {
+		for _, symbol := range strings.Split(unparen(part), ".") {
+			match := symbolRx.FindStringSubmatch(symbol)
+			if len(match) != 3 {
+				return nil
+			}
+			var f *big.Rat
+			if u, found := units[match[1]]; found {
+				f = exactFactor(match[1], u.factor)
+			} else if p, base, ok := prefix(match[1]); ok && units[base] != nil {
+				pr, _ := new(big.Rat).SetString(strconv.FormatFloat(p, 'g', -1, 64))
+				if bf := exactFactor(base, units[base].factor); bf != nil {
+					f = pr.Mul(pr, bf)
+				}
+			}
+			if f == nil {
+				return nil
+			}
+			x := 1
+			if match[2] != "" {
+				x, _ = strconv.Atoi(match[2])
+			}
+			if i == 1 {
+				x = -x
+			}
+			for ; x > 0; x-- {
+				result.Mul(result, f)
+			}
+			for ; x < 0; x++ {
+				result.Quo(result, f)
+			}
+		}
+	}
+	return result
+}
+
+// exactFloat returns f as a fraction if it is an integer that float64 represents exactly.
+func exactFloat(f float64) *big.Rat {
+	if f != math.Trunc(f) || math.Abs(f) > 1<<53 || f == 0 {
+		return nil
+	}
+	return new(big.Rat).SetInt64(int64(f))
+}
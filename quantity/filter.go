@@ -0,0 +1,95 @@
+package quantity
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Filter is a unit-aware condition on a Quantity, see ParseFilter. The error is an
+// IncompatibleUnitsError if the Quantity cannot be compared with the bounds of the filter.
+type Filter func(Quantity) (bool, error)
+
+// filterOps are the comparison operators of ParseFilter, longest first.
+var filterOps = []string{"<=", ">=", "!=", "==", "<", ">", "="}
+
+// ParseFilter parses a condition on quantities, e.g. ">= 10 bar", "< 5 km" or
+// "10..20 degC", and returns it as a Filter, so that query layers and alerting rules can be
+// configured with text. In EBNF, with quantity as defined for Parse:
+//
+//	filter   = operator quantity | range .
+//	operator = "<" | "<=" | ">" | ">=" | "=" | "==" | "!=" .
+//	range    = number [ unit ] ".." quantity .
+//
+// A range includes its bounds. If the lower bound has no unit, it has the unit of the
+// upper bound, so "10..20 degC" is "10 degC..20 degC". The bounds of a range must be
+// compatible. Quantities are compared in SI units, e.g. 1 km passes "> 500 m". Equality
+// allows a relative difference of 1e-9 for rounding in conversions, so 12 in passes "= 1 ft".
+func ParseFilter(s string) (Filter, error) {
+	t := strings.TrimSpace(s)
+	if len(t) > MaxInputLength {
+		return nil, &SyntaxError{abbreviate(t), "input too long"}
+	}
+	if lo, hi, found := strings.Cut(t, ".."); found {
+		high, err := Parse(hi)
+		if err != nil {
+			return nil, err
+		}
+		lo = strings.TrimSpace(lo)
+		if _, err := strconv.ParseFloat(lo, 64); err == nil {
+			lo += " " + high.symbol
+		}
+		low, err := Parse(lo)
+		if err != nil {
+			return nil, err
+		}
+		if err := compatible(low, high); err != nil {
+			return nil, err
+		}
+		if low.ToSI().value > high.ToSI().value {
+			return nil, &SyntaxError{s, "empty range in"}
+		}
+		return func(q Quantity) (bool, error) {
+			if err := compatible(q, low); err != nil {
+				return false, err
+			}
+			v := q.ToSI().value
+			return v >= low.ToSI().value && v <= high.ToSI().value, nil
+		}, nil
+	}
+	for _, op := range filterOps {
+		if !strings.HasPrefix(t, op) {
+			continue
+		}
+		bound, err := Parse(t[len(op):])
+		if err != nil {
+			return nil, err
+		}
+		b := bound.ToSI().value
+		return func(q Quantity) (bool, error) {
+			if err := compatible(q, bound); err != nil {
+				return false, err
+			}
+			v := q.ToSI().value
+			switch op {
+			case "<":
+				return v < b, nil
+			case "<=":
+				return v <= b, nil
+			case ">":
+				return v > b, nil
+			case ">=":
+				return v >= b, nil
+			case "!=":
+				return !nearlyEqual(v, b), nil
+			}
+			return nearlyEqual(v, b), nil
+		}, nil
+	}
+	return nil, &SyntaxError{s, "missing operator in filter"}
+}
+
+// nearlyEqual reports whether a and b differ by at most 1e-9 relative to the largest.
+func nearlyEqual(a, b float64) bool {
+	return math.Abs(a-b) <= 1e-9*math.Max(math.Abs(a), math.Abs(b))
+}
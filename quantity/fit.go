@@ -0,0 +1,62 @@
+package quantity
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Fit returns the least squares line through the points (xs[i], ys[i]), e.g. the trend of
+// sensor readings over time. The intercept is in the unit of ys[0] and the slope in the
+// unit of ys[0] per unit of xs[0], e.g. "degC/h", or in SI units if that cannot be
+// calculated. An error is returned if the slices differ in length, have fewer than 2
+// points, contain incompatible units, or if all xs are equal.
+func Fit(xs, ys Quantities) (slope Quantity, intercept Quantity, err error) {
+	undef := Quantity{0, &UndefinedUnit}
+	if len(xs) != len(ys) {
+		return undef, undef, fmt.Errorf("fit of %d x and %d y values", len(xs), len(ys))
+	}
+	if len(xs) < 2 {
+		return undef, undef, errors.New("fit of fewer than 2 points")
+	}
+	x, err := values(xs)
+	if err != nil {
+		return undef, undef, err
+	}
+	y, err := values(ys)
+	if err != nil {
+		return undef, undef, err
+	}
+	var mx, my float64
+	for i := range x {
+		mx += x[i]
+		my += y[i]
+	}
+	n := float64(len(x))
+	mx, my = mx/n, my/n
+	var sxy, sxx float64
+	for i := range x {
+		sxy += (x[i] - mx) * (y[i] - my)
+		sxx += (x[i] - mx) * (x[i] - mx)
+	}
+	if sxx == 0 {
+		return undef, undef, errors.New("fit of points with equal x values")
+	}
+	b := sxy / sxx
+	slope = Div(Quantity{b, ys[0].Unit}, Quantity{1, xs[0].Unit})
+	if c, ok := slope.ConvertTo(ys[0].symbol + "/" + xs[0].symbol); ok {
+		slope = c
+	}
+	return slope, Quantity{my - b*mx, ys[0].Unit}, nil
+}
+
+// values returns the values of the quantities in the unit of the first.
+func values(qs Quantities) ([]float64, error) {
+	v := make([]float64, len(qs))
+	for i, q := range qs {
+		if err := compatible(qs[0], q); err != nil {
+			return nil, fmt.Errorf("element %d: %w", i, err)
+		}
+		v[i] = q.Convert(qs[0].Unit).value
+	}
+	return v, nil
+}
@@ -0,0 +1,168 @@
+package quantity
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Formatter formats quantities like String, but with its own formats instead of
+// DefaultFormat and ScientificFormat, so a library can change how its quantities are shown
+// without changing the package variables that other packages in the same process use.
+// The zero Formatter formats like String. A Formatter with only Format set uses it for all
+// values; set ScientificFormat too to switch to it outside the scientific range.
+type Formatter struct {
+	Format           string // format for the value and the symbol, e.g. "%.2f %s"
+	ScientificFormat string // format for values outside ScientificMin to ScientificMax
+	// ScientificMin and ScientificMax are the range of absolute values that are formatted
+	// with Format; both 0 for the package variables ScientificMin and ScientificMax.
+	ScientificMin, ScientificMax float64
+}
+
+// String returns q formatted like Quantity.String, with the formats of f.
+func (f Formatter) String(q Quantity) string {
+	format, sci := f.Format, f.ScientificFormat
+	switch {
+	case format == "" && sci == "":
+		format, sci = DefaultFormat, ScientificFormat
+	case format == "":
+		format = DefaultFormat
+	case sci == "":
+		return q.Format(format)
+	}
+	min, max := f.ScientificMin, f.ScientificMax
+	if min == 0 && max == 0 {
+		min, max = ScientificMin, ScientificMax
+	}
+	if a := math.Abs(q.value); a != 0 && (a < min || a >= max) {
+		return q.Format(sci)
+	}
+	return q.Format(format)
+}
+
+// FormatAligned converts the quantities to the given unit and formats the values right
+// aligned in a column of the given width with prec decimals, followed by the unit symbol,
+// e.g. "   12.50 km". Quantities that cannot be converted show UnknownSymbol instead of a
+// value. Use it for report and CLI tables.
+func FormatAligned(qs Quantities, unit string, width, prec int) []string {
+	lines := make([]string, len(qs))
+	for i, q := range qs {
+		if c, ok := q.ConvertTo(unit); ok {
+			lines[i] = fmt.Sprintf("%*.*f %s", width, prec, c.value, unit)
+		} else {
+			lines[i] = fmt.Sprintf("%*s %s", width, UnknownSymbol, unit)
+		}
+	}
+	return lines
+}
+
+// The preferred range of absolute values for BestUnit: BestUnitMin <= |value| < BestUnitMax.
+var (
+	BestUnitMin = 1.0
+	BestUnitMax = 1000.0
+)
+
+// BestUnit converts the Quantity to the candidate unit that gives the nicest value for
+// display: the largest unit with an absolute value in the preferred range, see BestUnitMin,
+// or else the unit with the value closest to that range, e.g. "0.42 L" for 0.00042 m3 and
+// the candidates "m3" and "L". Without candidates all registered compatible units are
+// considered. Candidates that are unknown or not compatible are skipped; if none is left,
+// the Quantity is returned as is.
+func BestUnit(q Quantity, candidates ...string) Quantity {
+	if len(candidates) == 0 {
+		candidates = CompatibleUnits(q.symbol)
+	}
+	best, bestDist := q, math.Inf(1)
+	for _, c := range candidates {
+		cq, ok := q.ConvertTo(c)
+		if !ok {
+			continue
+		}
+		v := math.Abs(cq.value)
+		var dist float64
+		switch {
+		case v == 0:
+			dist = 0
+		case v < BestUnitMin:
+			dist = math.Log10(BestUnitMin / v)
+		case v >= BestUnitMax:
+			dist = math.Log10(v / BestUnitMax)
+		default:
+			dist = -1 / v // in range, prefer smaller values
+		}
+		if dist < bestDist {
+			best, bestDist = cq, dist
+		}
+	}
+	return best
+}
+
+// ConversionMatrix returns the factors between each pair of the given units: element [i][j]
+// is the value in unit j of 1 unit i, see FactorBetween. An error is returned if a unit is
+// unknown or not compatible with the first one.
+func ConversionMatrix(symbols ...string) ([][]float64, error) {
+	m := make([][]float64, len(symbols))
+	for i, from := range symbols {
+		m[i] = make([]float64, len(symbols))
+		for j, to := range symbols {
+			f, err := FactorBetween(from, to)
+			if err != nil {
+				return nil, err
+			}
+			m[i][j] = f
+		}
+	}
+	return m, nil
+}
+
+// FormatMatrix formats the ConversionMatrix of the units as a table for terminals: a
+// header line with the units, then for each unit a line with its symbol and the values of
+// 1 of that unit in each of the units, right aligned in columns of the given width with
+// prec significant digits, e.g. for m and ft, width 9 and prec 4:
+//
+//	           m        ft
+//	m          1     3.281
+//	ft    0.3048         1
+func FormatMatrix(symbols []string, width, prec int) ([]string, error) {
+	m, err := ConversionMatrix(symbols...)
+	if err != nil {
+		return nil, err
+	}
+	first := 0
+	for _, s := range symbols {
+		if n := len([]rune(s)); n > first {
+			first = n
+		}
+	}
+	var b strings.Builder
+	b.WriteString(strings.Repeat(" ", first))
+	for _, s := range symbols {
+		fmt.Fprintf(&b, " %*s", width, s)
+	}
+	lines := []string{b.String()}
+	for i, row := range m {
+		b.Reset()
+		fmt.Fprintf(&b, "%-*s", first, symbols[i])
+		for _, f := range row {
+			fmt.Fprintf(&b, " %*.*g", width, prec, f)
+		}
+		lines = append(lines, b.String())
+	}
+	return lines, nil
+}
+
+// DiffString describes the difference a - b for test failures and assertion libraries, in
+// SI units and in the unit of a, e.g. "Δ = 0.02 m (2 cm)" for 5 cm and 3 cm. Values are
+// formatted with 6 significant digits. (Diff is the subtraction of several quantities.)
+func DiffString(a, b Quantity) string {
+	if err := compatible(a, b); err != nil {
+		return fmt.Sprintf("Δ undefined: %v and %v are not compatible", a, b)
+	}
+	d := Quantity{a.value - b.Convert(a.Unit).value, a.Unit}
+	si := d.ToSI()
+	s := strings.TrimSpace(fmt.Sprintf("Δ = %.6g %s", si.value, si.symbol))
+	if a.symbol == si.symbol {
+		return s
+	}
+	return s + fmt.Sprintf(" (%.6g %s)", d.value, a.symbol)
+}
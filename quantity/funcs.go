@@ -0,0 +1,97 @@
+package quantity
+
+// Functions on quantities as plain strings, for template engines and other environments that
+// cannot construct Quantity values, e.g. static site generators. Inputs are parsed with
+// Parse; results are formatted with String unless stated otherwise.
+
+// ConvertString converts the quantity s to the unit to, e.g. "3.1069 mi" for "5 km" and "mi".
+func ConvertString(s, to string) (string, error) {
+	q, err := Parse(s)
+	if err != nil {
+		return "", err
+	}
+	c, ok := q.ConvertTo(to)
+	if !ok {
+		return "", compatibleTo(q, to)
+	}
+	return c.String(), nil
+}
+
+// FormatString formats the quantity s with the format, see Quantity.Format, e.g. "5.0 km" for
+// "5 km" and "%.1f %s".
+func FormatString(s, format string) (string, error) {
+	q, err := Parse(s)
+	if err != nil {
+		return "", err
+	}
+	return q.Format(format), nil
+}
+
+// BestUnitString converts the quantity s to the candidate unit that gives the nicest value,
+// see BestUnit, e.g. "1.5000 km" for "1500 m" and the candidates "m" and "km".
+func BestUnitString(s string, candidates ...string) (string, error) {
+	q, err := Parse(s)
+	if err != nil {
+		return "", err
+	}
+	return BestUnit(q, candidates...).String(), nil
+}
+
+// AddStrings adds the quantities a and b and returns the sum in the unit of a, e.g.
+// "5.2500 ft" for "5 ft" and "3 in".
+func AddStrings(a, b string) (string, error) {
+	qa, err := Parse(a)
+	if err != nil {
+		return "", err
+	}
+	qb, err := Parse(b)
+	if err != nil {
+		return "", err
+	}
+	if err := compatible(qa, qb); err != nil {
+		return "", err
+	}
+	return Add(qa, qb).Convert(qa.Unit).String(), nil
+}
+
+// ValueIn returns the value of the quantity s in the unit to, e.g. 3.1069 for "5 km" and
+// "mi", for calculations in templates.
+func ValueIn(s, to string) (float64, error) {
+	q, err := Parse(s)
+	if err != nil {
+		return 0, err
+	}
+	c, ok := q.ConvertTo(to)
+	if !ok {
+		return 0, compatibleTo(q, to)
+	}
+	return c.value, nil
+}
+
+// compatibleTo returns the error of converting q to the unit symbol.
+func compatibleTo(q Quantity, symbol string) error {
+	if UnitFor(symbol) == &UndefinedUnit {
+		return &UnknownUnitError{symbol}
+	}
+	return &IncompatibleUnitsError{A: q.symbol, B: symbol}
+}
+
+// TemplateFuncs returns the string functions by name for text/template and html/template,
+// e.g. tmpl.Funcs(quantity.TemplateFuncs()). The quantity is the last argument, so it can be
+// piped: {{ "5 km" | unitsConvert "mi" }}, {{ .Height | unitsFormat "%.0f %s" }},
+// {{ "1500 m" | unitsBest "m" "km" }}, {{ "3 in" | unitsAdd "5 ft" }} and
+// {{ .Distance | unitsValue "km" }}.
+func TemplateFuncs() map[string]any {
+	return map[string]any{
+		"unitsConvert": func(to, s string) (string, error) { return ConvertString(s, to) },
+		"unitsFormat":  func(format, s string) (string, error) { return FormatString(s, format) },
+		"unitsBest": func(args ...string) (string, error) {
+			if len(args) == 0 {
+				return "", &SyntaxError{"", "missing quantity"}
+			}
+			return BestUnitString(args[len(args)-1], args[:len(args)-1]...)
+		},
+		"unitsAdd":   func(a, b string) (string, error) { return AddStrings(a, b) },
+		"unitsValue": func(to, s string) (float64, error) { return ValueIn(s, to) },
+	}
+}
@@ -0,0 +1,173 @@
+package quantity
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// coordinateRx matches a coordinate in degrees, optionally with minutes, seconds and a
+// hemisphere, e.g. 41°24'12.2"N, 41 24.2 N or -41.4035.
+var coordinateRx = regexp.MustCompile(
+	`^([+-])?(\d+(?:\.\d+)?)\s*°?\s*(?:(\d+(?:\.\d+)?)\s*'?\s*)?(?:(\d+(?:\.\d+)?)\s*"?\s*)?([NSEWnsew])?$`)
+
+// Latitude is an angle north (positive) or south (negative) of the equator, within ±90°.
+type Latitude struct {
+	angle Quantity
+}
+
+// Longitude is an angle east (positive) or west (negative) of the prime meridian, within
+// ±180°.
+type Longitude struct {
+	angle Quantity
+}
+
+// NewLatitude returns the latitude for the angle. An error is returned if q is not an angle
+// or not within ±90°.
+func NewLatitude(q Quantity) (Latitude, error) {
+	if err := checkCoordinate(q, 90, "latitude"); err != nil {
+		return Latitude{}, err
+	}
+	return Latitude{q}, nil
+}
+
+// NewLongitude returns the longitude for the angle. An error is returned if q is not an
+// angle or not within ±180°.
+func NewLongitude(q Quantity) (Longitude, error) {
+	if err := checkCoordinate(q, 180, "longitude"); err != nil {
+		return Longitude{}, err
+	}
+	return Longitude{q}, nil
+}
+
+// ParseLatitude parses a latitude in degrees, optionally followed by minutes and seconds of
+// arc and N or S, e.g. 41°24'12.2"N, 41°24.2′ S or -41.4. Without a hemisphere a negative
+// value is south.
+func ParseLatitude(s string) (Latitude, error) {
+	q, err := parseCoordinate(s, "NS")
+	if err != nil {
+		return Latitude{}, err
+	}
+	return NewLatitude(q)
+}
+
+// ParseLongitude parses a longitude like ParseLatitude, with E or W for the hemisphere,
+// e.g. 2°10'26.5"E.
+func ParseLongitude(s string) (Longitude, error) {
+	q, err := parseCoordinate(s, "EW")
+	if err != nil {
+		return Longitude{}, err
+	}
+	return NewLongitude(q)
+}
+
+// Angle returns the latitude as an angle, positive to the north.
+func (l Latitude) Angle() Quantity {
+	return l.angle
+}
+
+// Degrees returns the latitude in decimal degrees, positive to the north.
+func (l Latitude) Degrees() float64 {
+	return l.angle.In("deg").value
+}
+
+// String formats the latitude in degrees, minutes and seconds, e.g. 41°24′12.2″N.
+func (l Latitude) String() string {
+	return formatCoordinate(l.angle, "NS")
+}
+
+// Angle returns the longitude as an angle, positive to the east.
+func (l Longitude) Angle() Quantity {
+	return l.angle
+}
+
+// Degrees returns the longitude in decimal degrees, positive to the east.
+func (l Longitude) Degrees() float64 {
+	return l.angle.In("deg").value
+}
+
+// String formats the longitude in degrees, minutes and seconds, e.g. 2°10′26.5″E.
+func (l Longitude) String() string {
+	return formatCoordinate(l.angle, "EW")
+}
+
+// earthRadius is the mean radius of the Earth in m, as defined by the IUGG.
+const earthRadius = 6371008.8
+
+// HaversineDistance returns the great-circle distance between two points given by their
+// latitudes and longitudes, in m, on a sphere with the mean radius of the Earth, 6371.0088
+// km. The angles may be in any angular unit, e.g. "deg" or "rad", so that they cannot be
+// mixed up. An error is returned if an argument is not an angle or out of range, see
+// NewLatitude and NewLongitude. The error of the spherical model is up to about 0.5%.
+func HaversineDistance(lat1, lon1, lat2, lon2 Quantity) (Quantity, error) {
+	undef := Quantity{0, &UndefinedUnit}
+	for i, q := range []Quantity{lat1, lon1, lat2, lon2} {
+		limit, name := 90.0, "latitude"
+		if i%2 == 1 {
+			limit, name = 180, "longitude"
+		}
+		if err := checkCoordinate(q, limit, name); err != nil {
+			return undef, err
+		}
+	}
+	phi1, phi2 := lat1.In("rad").value, lat2.In("rad").value
+	dPhi, dLambda := phi2-phi1, lon2.In("rad").value-lon1.In("rad").value
+	h := math.Sin(dPhi/2)*math.Sin(dPhi/2) + math.Cos(phi1)*math.Cos(phi2)*math.Sin(dLambda/2)*math.Sin(dLambda/2)
+	return Q(2*earthRadius*math.Asin(math.Min(1, math.Sqrt(h))), "m"), nil
+}
+
+func checkCoordinate(q Quantity, limit float64, name string) error {
+	if err := ExpectDimension(q, "deg"); err != nil {
+		return err
+	}
+	if d := q.In("deg").value; !(math.Abs(d) <= limit) {
+		return fmt.Errorf("%s out of range ±%g°: %v", name, limit, q)
+	}
+	return nil
+}
+
+// parseCoordinate parses s with the given positive and negative hemisphere letters.
+func parseCoordinate(s, hemispheres string) (Quantity, error) {
+	undef := Quantity{0, &UndefinedUnit}
+	if len(s) > MaxInputLength {
+		return undef, &SyntaxError{abbreviate(s), "input too long"}
+	}
+	m := coordinateRx.FindStringSubmatch(strings.TrimSpace(confusables.Replace(s)))
+	if m == nil {
+		return undef, &SyntaxError{s, "invalid coordinate"}
+	}
+	deg, _ := strconv.ParseFloat(m[2], 64)
+	var min, sec float64
+	if m[3] != "" {
+		min, _ = strconv.ParseFloat(m[3], 64)
+	}
+	if m[4] != "" {
+		sec, _ = strconv.ParseFloat(m[4], 64)
+	}
+	if min >= 60 || sec >= 60 || m[4] != "" && m[3] == "" {
+		return undef, &SyntaxError{s, "invalid minutes or seconds in"}
+	}
+	v := deg + min/60 + sec/3600
+	hemisphere := strings.ToUpper(m[5])
+	switch {
+	case hemisphere != "" && m[1] != "":
+		return undef, &SyntaxError{s, "both sign and hemisphere in"}
+	case hemisphere != "" && !strings.Contains(hemispheres, hemisphere):
+		return undef, &SyntaxError{s, "invalid hemisphere in"}
+	case m[1] == "-" || hemisphere == hemispheres[1:]:
+		v = -v
+	}
+	return Q(v, "deg"), nil
+}
+
+func formatCoordinate(q Quantity, hemispheres string) string {
+	a, _ := DegMinSec(q)
+	hemisphere := hemispheres[:1]
+	if a.Negative {
+		hemisphere = hemispheres[1:]
+	}
+	a.Negative = false
+	return a.String() + hemisphere
+}
@@ -1,3 +1,3 @@
 module github.com/imhotep-nb/units/quantity
 
-go 1.16
+go 1.18
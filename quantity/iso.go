@@ -0,0 +1,108 @@
+package quantity
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// isoSymbols maps registered symbols that are not those of ISO 80000 and the SI Brochure to
+// the standard notation.
+var isoSymbols = map[string]string{
+	"kph":   "km/h",
+	"hr":    "h",
+	"sqm":   "m²",
+	"sq in": "in²",
+	"sq ft": "ft²",
+	"sq mi": "mi²",
+	"cu ft": "ft³",
+	"degC":  "°C",
+	"degF":  "°F",
+	"deg":   "°",
+	"byte":  "B",
+	"lbs":   "lb",
+	"tph":   "t/h",
+	"CFM":   "ft³/min",
+	"SCFM":  "ft³/min",
+}
+
+// FormatISO formats the Quantity as required by ISO 80000-1 and the SI Brochure, with prec
+// decimals: a space between the value and the unit, except for the degree sign of angles,
+// products with a middle dot, exponents in superscript, at most one solidus with the
+// denominator in parentheses if it is a product, and standard symbols instead of e.g.
+// "kph", e.g. "9.81 m·s⁻²", "4.2 J/(kg·K)" or "100 km/h".
+func FormatISO(q Quantity, prec int) string {
+	value := strconv.FormatFloat(q.value, 'f', prec, 64)
+	if !q.defined() {
+		return value + " " + UnknownSymbol
+	}
+	symbol := ISOSymbol(q.symbol)
+	switch symbol {
+	case "":
+		return value
+	case "°":
+		return value + symbol
+	}
+	return value + " " + symbol
+}
+
+// ISOSymbol returns the unit symbol in the notation of ISO 80000, see FormatISO, e.g.
+// "m·s⁻²" for "m.s-2" or "km/h" for "kph". Symbols that cannot be parsed are returned as is.
+func ISOSymbol(symbol string) string {
+	if s, found := isoSymbols[symbol]; found {
+		return s
+	}
+	s := symbolReplacer.Replace(normalizeSpace(symbol))
+	if _, found := units[s]; found && !strings.ContainsAny(s, "./0123456789") {
+		return s
+	}
+	parts := strings.Split(s, "/")
+	if len(parts) > 2 {
+		return symbol
+	}
+	var iso [2][]string
+	for i, part := range parts {
+		for _, factor := range strings.Split(unparen(part), ".") {
+			match := symbolRx.FindStringSubmatch(factor)
+			if len(match) != 3 {
+				return symbol
+			}
+			name := match[1]
+			if std, found := isoSymbols[name]; found && !strings.Contains(std, "/") {
+				name = std
+			}
+			iso[i] = append(iso[i], name+superscripts.Replace(match[2]))
+		}
+	}
+	result := strings.Join(iso[0], "·")
+	switch {
+	case len(iso[1]) == 1:
+		result += "/" + iso[1][0]
+	case len(iso[1]) > 1:
+		result += "/(" + strings.Join(iso[1], "·") + ")"
+	}
+	return result
+}
+
+// LintISO reports the registered symbols that are not those of ISO 80000 and the SI
+// Brochure, with the standard notation where there is one, e.g. "kph: use km/h". Symbols
+// of several words are reported too. Use it to review the unit table for documentation
+// that must follow the standards; see also Audit and CheckRegistry.
+func LintISO() []Anomaly {
+	var anomalies []Anomaly
+	for symbol := range units {
+		switch std, found := isoSymbols[symbol]; {
+		case found:
+			anomalies = append(anomalies, Anomaly{[]string{symbol}, "use " + std})
+		case strings.Contains(symbol, " "):
+			anomalies = append(anomalies, Anomaly{[]string{symbol}, "symbol of several words"})
+		case strings.ContainsAny(symbol, "./"):
+			anomalies = append(anomalies, Anomaly{[]string{symbol}, fmt.Sprintf("compound symbol, write %s", ISOSymbol(symbol))})
+		}
+	}
+	sort.Slice(anomalies, func(i, j int) bool {
+		return anomalies[i].Symbols[0] < anomalies[j].Symbols[0]
+	})
+	return anomalies
+}
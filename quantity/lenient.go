@@ -0,0 +1,92 @@
+package quantity
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ParseLenient is Parse for messy input, e.g. from forms: if s does not parse as is, the
+// case of the unit symbols is corrected where the registered units allow only one reading,
+// e.g. "KM/HR" is read as "km/hr", "Kg" as "kg" and " 5 m /s " as "5 m/s". A symbol that
+// matches several units regardless of case, or only by changing the case of a prefix, is
+// not guessed: e.g. "MM" (Mm or mm), "H" (H or h), "pa" (Pa or pA) and "MBAR" (Mbar or
+// mbar) give a SyntaxError naming the readings. The result has the canonical symbol.
+func ParseLenient(s string) (Quantity, error) {
+	q, err := Parse(s)
+	if err == nil || len(s) > MaxInputLength {
+		return q, err
+	}
+	match := muRx.FindStringSubmatch(s)
+	if len(match) != 3 {
+		return q, err
+	}
+	symbol, ferr := foldSymbol(match[2])
+	if ferr != nil {
+		if _, unknown := ferr.(*UnknownUnitError); unknown {
+			return q, err
+		}
+		return q, &SyntaxError{s, ferr.Error() + " in"}
+	}
+	return Parse(match[1] + " " + symbol)
+}
+
+// foldSymbol returns the unit symbol with the case of each factor corrected, see ParseLenient.
+func foldSymbol(s string) (string, error) {
+	s = symbolReplacer.Replace(normalizeSpace(s))
+	if s == "" {
+		return s, nil
+	}
+	parts := strings.Split(s, "/")
+	for i, part := range parts {
+		inner := unparen(part)
+		factors := strings.Split(inner, ".")
+		for j, factor := range factors {
+			match := symbolRx.FindStringSubmatch(factor)
+			if len(match) != 3 {
+				return "", &SyntaxError{s, "cannot parse unit"}
+			}
+			symbol, err := foldFactor(match[1])
+			if err != nil {
+				return "", err
+			}
+			factors[j] = symbol + match[2]
+		}
+		if parts[i] = strings.Join(factors, "."); inner != part {
+			parts[i] = "(" + parts[i] + ")"
+		}
+	}
+	return strings.Join(parts, "/"), nil
+}
+
+// foldFactor returns the registered symbol, or prefix and registered symbol, that equals
+// the given symbol regardless of case, or an error if there is not exactly one or if the
+// case of a prefix would change, e.g. from M (mega) to m (milli).
+func foldFactor(symbol string) (string, error) {
+	var candidates []string
+	for s := range units {
+		if s != "" && strings.EqualFold(s, symbol) {
+			candidates = append(candidates, s)
+		}
+		for _, p := range append(strings.Split(prefixSymbols, ""), "da") {
+			if len(symbol) > len(p) && strings.EqualFold(p+s, symbol) {
+				if _, _, ok := prefix(p + s); ok && units[p+s] == nil {
+					candidates = append(candidates, p+s)
+				}
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return "", &UnknownUnitError{symbol}
+	}
+	if c := candidates[0]; len(candidates) == 1 && c[0] != symbol[0] && units[c[1:]] != nil &&
+		strings.IndexByte(prefixSymbols, symbol[0]) != -1 && strings.IndexByte(prefixSymbols, c[0]) != -1 {
+		// the case of the prefix was changed, e.g. mega in "MBAR" would be read as milli
+		candidates = append(candidates, symbol[:1]+c[1:])
+	}
+	if len(candidates) > 1 {
+		sort.Strings(candidates)
+		return "", fmt.Errorf("ambiguous unit %q: %s", symbol, strings.Join(candidates, " or "))
+	}
+	return candidates[0], nil
+}
@@ -0,0 +1,112 @@
+package quantity
+
+import (
+	"fmt"
+	"sort"
+)
+
+// RateProvider provides exchange rates between currency codes: 1 from = rate to.
+type RateProvider interface {
+	Rate(from, to string) (float64, error)
+}
+
+// RateFunc is a function that is a RateProvider, e.g. one that looks up a rates service.
+type RateFunc func(from, to string) (float64, error)
+
+// Rate calls f.
+func (f RateFunc) Rate(from, to string) (float64, error) {
+	return f(from, to)
+}
+
+// RegistryRates is the RateProvider that uses the factors of the registered money units,
+// the same rates that Convert uses. An error is returned for a currency without a rate, e.g.
+// after DefineISOCurrencies until SetCurrencyRate is called for it.
+var RegistryRates RateProvider = RateFunc(func(from, to string) (float64, error) {
+	for _, code := range []string{from, to} {
+		if err := expectMoney(code); err != nil {
+			return 0, err
+		}
+		if unratedCurrency(code) {
+			return 0, fmt.Errorf("no rate for %s, see SetCurrencyRate", code)
+		}
+	}
+	return conversionRatio(UnitFor(from), UnitFor(to)), nil
+})
+
+// expectMoney checks that the code is a registered money unit.
+func expectMoney(code string) error {
+	u, found := units[code]
+	if !found {
+		return &UnknownUnitError{code}
+	}
+	if u.exponents != dimOf("¤") {
+		return &IncompatibleUnitsError{code, "¤", fmt.Sprintf("%s is not a currency", code)}
+	}
+	return nil
+}
+
+// MoneyBag holds balances in several currencies, e.g. a wallet of 20 EUR and 15 USD, which a
+// single Quantity cannot represent as there is no fixed rate between them. The zero value
+// is an empty MoneyBag; it is not safe for concurrent use.
+type MoneyBag struct {
+	balances map[string]float64
+}
+
+// Add adds the amount to the balance of its currency, e.g. Q(20, "EUR"). An error is returned
+// if the unit of the amount is not a registered currency, e.g. "k$" or "kg".
+func (b *MoneyBag) Add(amount Quantity) error {
+	if err := expectMoney(amount.symbol); err != nil {
+		return err
+	}
+	if b.balances == nil {
+		b.balances = make(map[string]float64)
+	}
+	b.balances[amount.symbol] += amount.value
+	return nil
+}
+
+// Sub subtracts the amount from the balance of its currency, see Add. Balances may become
+// negative.
+func (b *MoneyBag) Sub(amount Quantity) error {
+	return b.Add(Neg(amount))
+}
+
+// Balance returns the balance in the currency, 0 if the MoneyBag holds none of it. It
+// panics if the code is not a registered unit, like Q.
+func (b *MoneyBag) Balance(code string) Quantity {
+	return Q(b.balances[code], code)
+}
+
+// Codes returns the sorted currency codes of the balances that are not 0.
+func (b *MoneyBag) Codes() []string {
+	codes := []string{}
+	for code, v := range b.balances {
+		if v != 0 {
+			codes = append(codes, code)
+		}
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// TotalIn returns the sum of the balances converted to the currency with the rates, e.g.
+// b.TotalIn("EUR", RegistryRates). An error is returned if the code is not a registered
+// currency or a rate is not available.
+func (b *MoneyBag) TotalIn(code string, rates RateProvider) (Quantity, error) {
+	if err := expectMoney(code); err != nil {
+		return Quantity{0, &UndefinedUnit}, err
+	}
+	var total Accumulator
+	total.Add(Q(0, code))
+	for _, c := range b.Codes() {
+		rate := 1.0
+		if c != code {
+			var err error
+			if rate, err = rates.Rate(c, code); err != nil {
+				return Quantity{0, &UndefinedUnit}, fmt.Errorf("rate %s to %s: %w", c, code, err)
+			}
+		}
+		total.Add(Q(b.balances[c]*rate, code))
+	}
+	return total.Result(code)
+}
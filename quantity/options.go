@@ -0,0 +1,124 @@
+package quantity
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseOptions sets how Parse and ParseSymbol read text, so that parts of a program can
+// parse differently, e.g. a strict API next to a lenient form. Create them with
+// NewParseOptions; the zero value rejects white space and typographic notations.
+type ParseOptions struct {
+	NumberFormat NumberFormat      // separators of the number, see ParseNumberFormat
+	AllowSpaces  bool              // white space around separators and in runs, e.g. "us  gal / h"
+	AllowUnicode bool              // "·", superscript exponents and the marks ' " °, see ParseSymbol
+	Strict       bool              // also reject "*", "^" and uncertainties, e.g. "3 ± 1 m"
+	Registry     *RegistrySnapshot // the units to parse with; nil for the unit table
+}
+
+// ParseOption changes ParseOptions, see NewParseOptions.
+type ParseOption func(*ParseOptions)
+
+// NewParseOptions returns the options of Parse and ParseSymbol, which accept all notations
+// with the PointDecimal number format, changed by the given options, e.g.
+// NewParseOptions(WithNumberFormat(CommaDecimal), AllowSpaces(false)).
+func NewParseOptions(opts ...ParseOption) ParseOptions {
+	o := ParseOptions{NumberFormat: PointDecimal, AllowSpaces: true, AllowUnicode: true}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// Strict accepts only the notation of the unit table: no extra white space, no typographic
+// notations, no "*" or "^" and no uncertainty, e.g. "9.81 m/s2" but not "9.81 m / s²".
+func Strict() ParseOption {
+	return func(o *ParseOptions) {
+		o.AllowSpaces, o.AllowUnicode, o.Strict = false, false, true
+	}
+}
+
+// WithNumberFormat sets the separators of the number, e.g. CommaDecimal for "1.234,5 km".
+func WithNumberFormat(nf NumberFormat) ParseOption {
+	return func(o *ParseOptions) { o.NumberFormat = nf }
+}
+
+// AllowSpaces sets whether white space around separators and runs of white space in unit
+// symbols are accepted. Single spaces in registered symbols, e.g. "us gal", always are.
+func AllowSpaces(allow bool) ParseOption {
+	return func(o *ParseOptions) { o.AllowSpaces = allow }
+}
+
+// AllowUnicode sets whether the middle dot, superscript exponents and the marks for feet,
+// inches and degrees are accepted, e.g. "m·s⁻²" or 5'11". Registered symbols such as "°C"
+// and "Ω" always are.
+func AllowUnicode(allow bool) ParseOption {
+	return func(o *ParseOptions) { o.AllowUnicode = allow }
+}
+
+// WithRegistry parses with the units of the snapshot instead of the unit table, e.g. a table
+// loaded from JSON for one tenant, see RegistrySnapshot.UnmarshalJSON.
+func WithRegistry(r RegistrySnapshot) ParseOption {
+	return func(o *ParseOptions) { o.Registry = &r }
+}
+
+// ParseWith is Parse with the given options, see NewParseOptions.
+func ParseWith(s string, opts ...ParseOption) (Quantity, error) {
+	return NewParseOptions(opts...).Parse(s)
+}
+
+// ParseSymbolWith is ParseSymbol with the given options, see NewParseOptions.
+func ParseSymbolWith(s string, opts ...ParseOption) (Quantity, error) {
+	return NewParseOptions(opts...).ParseSymbol(s)
+}
+
+// Parse parses a quantity like the function Parse, with these options.
+func (o ParseOptions) Parse(s string) (Quantity, error) {
+	if err := o.NumberFormat.check(); err != nil {
+		return Quantity{0, &UndefinedUnit}, err
+	}
+	q, _, err := parse(s, o)
+	return q, err
+}
+
+// ParseSymbol parses a unit symbol like the function ParseSymbol, with these options.
+func (o ParseOptions) ParseSymbol(s string) (Quantity, error) {
+	return parseSymbol(s, o)
+}
+
+// units returns the units to parse with.
+func (o ParseOptions) units() map[string]*Unit {
+	if o.Registry != nil {
+		return o.Registry.units
+	}
+	return units
+}
+
+// normalize returns the unit symbol s with the notations allowed by the options replaced by
+// those of the unit table, or an error if s uses a notation that is not allowed.
+func (o ParseOptions) normalize(s string) (string, error) {
+	n := normalizeSpace(s)
+	if n != s && !o.AllowSpaces {
+		return "", &SyntaxError{s, "unexpected white space in unit"}
+	}
+	s = n
+	if o.Strict && strings.ContainsAny(s, "*^") {
+		return "", &SyntaxError{s, "unexpected '*' or '^' in unit"}
+	}
+	if o.AllowUnicode {
+		return unmark(s), nil
+	}
+	if strings.ContainsAny(s, "·⁻¹²³⁴") || unmark(s) != s {
+		return "", &SyntaxError{s, "unexpected notation in unit"}
+	}
+	return s, nil
+}
+
+// check returns an error if the separators of nf are not valid.
+func (nf NumberFormat) check() error {
+	if nf.Decimal != "." && nf.Decimal != "," || nf.Group != "." && nf.Group != "," && nf.Group != "" ||
+		nf.Group == nf.Decimal {
+		return fmt.Errorf("invalid number format %q", nf)
+	}
+	return nil
+}
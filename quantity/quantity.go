@@ -11,19 +11,67 @@ import (
 
 // Quantity represents a physical quantity: a value and a unit.
 // The units have to be registered in the unit table with DefineUnit.
+// Quantities share their *Unit, which is never modified after it is created, so copies of
+// a Quantity are independent and only methods with a pointer receiver, such as Normalize,
+// change it.
 type Quantity struct {
 	value float64
 	*Unit
 }
 
-// String returns a default string representation of the Quantity
+// defined returns false for a Quantity without a unit, e.g. the zero Quantity, or with
+// UndefinedUnit.
+func (m Quantity) defined() bool {
+	return m.Unit != nil && m.Unit.defined()
+}
+
+// unitSymbol returns the symbol of the unit, or "" for a Quantity without a unit.
+func (m Quantity) unitSymbol() string {
+	if m.Unit == nil {
+		return ""
+	}
+	return m.symbol
+}
+
+// String returns a default string representation of the Quantity. Values that are not 0
+// and whose absolute value is outside ScientificMin to ScientificMax are formatted with
+// ScientificFormat, so 1.2e-9 m does not show as 0. Use a Formatter for another format.
 func (m Quantity) String() string {
-	return m.Format(DefaultFormat)
+	return Formatter{}.String(m)
+}
+
+// QuantityDebug holds the internals of a Quantity, see Debug.
+type QuantityDebug struct {
+	Value     float64         // value in Symbol units
+	Symbol    string          // unit symbol
+	SIFactor  float64         // 1 Symbol = SIFactor SI units
+	Exponents map[string]int8 // non-zero exponents of the SI base units, keyed by base symbol
+}
+
+// Debug returns the internals of the Quantity, for tools and tests.
+func (m Quantity) Debug() QuantityDebug {
+	if !m.defined() {
+		return QuantityDebug{m.value, UnknownSymbol, 0, map[string]int8{}}
+	}
+	d := QuantityDebug{m.value, m.symbol, m.factor, make(map[string]int8)}
+	for i, e := range m.exponents {
+		if e != 0 {
+			d.Exponents[baseSymbols[i]] = e
+		}
+	}
+	return d
 }
 
-// Inspect returns a string representation of the Quantity for debugging
+// Inspect returns a string representation of the Quantity for debugging. The layout is
+// "<value> <symbol> = <SI value> <SI symbol> (factor <SI factor>)", with values in %g format,
+// e.g. "2 km = 2000 m (factor 1000)".
 func (m Quantity) Inspect() string {
-	return fmt.Sprintf("%f %s -> %f %s %v", m.value, m.symbol, m.factor, makeSymbol(m.exponents), m.exponents)
+	d := m.Debug()
+	var si string
+	if m.defined() {
+		si = makeSymbol(m.exponents)
+	}
+	return fmt.Sprintf("%g %s = %g %s (factor %g)", d.Value, d.Symbol, d.Value*d.SIFactor, si, d.SIFactor)
 }
 
 // Format returns a string representation of the Quantity according to the
@@ -32,14 +80,15 @@ func (m Quantity) Inspect() string {
 // format string indexes such as in "%[2]s %.2[1]f". If only one argument is to be
 // used, then an index must be used as well, e.g. "%[1]e radians".
 // A better way to format quantities is by using a Context.
+// Quantities without a unit show UnknownSymbol, dimensionless ones DimensionlessSymbol.
 func (m Quantity) Format(format string) string {
-	var a, b interface{}
-	if m.Unit == nil {
-		a, b = m.value, "?"
-	} else {
-		a, b = m.value, m.symbol
+	switch {
+	case !m.defined():
+		return fmt.Sprintf(format, m.value, UnknownSymbol)
+	case m.symbol == "":
+		return strings.TrimRight(fmt.Sprintf(format, m.value, DimensionlessSymbol), " ")
 	}
-	return fmt.Sprintf(format, a, b)
+	return fmt.Sprintf(format, m.value, m.symbol)
 }
 
 // Split returns the value and the unit symbol of the Quantity
@@ -52,9 +101,10 @@ func (m Quantity) Value() float64 {
 	return m.value
 }
 
-// Convert a quantity to another compatible unit.
+// Convert a quantity to another compatible unit. The value is multiplied once by the ratio of
+// the factors, so the relative error is within MaxRelativeError even for extreme values.
 func (m Quantity) Convert(u *Unit) Quantity {
-	return Quantity{m.value * m.factor / u.factor, u}
+	return Quantity{m.value * conversionRatio(m.Unit, u), u}
 }
 
 // ConvertTo creates and returns a new Quantity that has undergone conversion to the given unit.
@@ -62,19 +112,16 @@ func (m Quantity) Convert(u *Unit) Quantity {
 // cannot be found or calculated, or if that unit is not compatible.
 func (m Quantity) ConvertTo(u string) (Quantity, bool) {
 	target := UnitFor(u)
-	compatible := haveSameExponents(m.exponents, target.exponents)
-	if target == nil || !compatible {
+	if target == &UndefinedUnit || !haveSameExponents(m.exponents, target.exponents) {
 		return Quantity{}, false
 	}
-	f := target.factor / m.factor
-	return Quantity{m.value / f, target}, true
+	return m.Convert(target), true
 }
 
 // In returns a Quantity converted to the given unit. No unit compatibility check is
 // performed. If the target unit is not compatible the function will return garbage.
 func (m Quantity) In(u string) Quantity {
-	target := UnitFor(u)
-	return Quantity{m.value * m.factor / target.factor, target}
+	return m.Convert(UnitFor(u))
 }
 
 // Q returns a Quantity with the given value and unit.
@@ -93,32 +140,116 @@ func Q(value float64, symbol string) Quantity {
 // factors, numbers for exponents and optional minus signs, e.g. "-1,500 N.m/s2" =
 // -1500 newton meter per square second. This function returns the Quantity and an
 // error which is nil in case the string has been correctly parsed into a Quantity.
+// An uncertainty, e.g. "9.81 ± 0.02 m/s2" or "9.81(2) m/s2", is accepted but dropped: the
+// result is the value, 9.81 m/s2; use ParseUncertain to keep it. In EBNF, with unit as
+// defined for ParseSymbol and uncertainty for ParseUncertain:
+//
+//	quantity    = number [ uncertainty ] unit .
+//	number      = [ "-" ] digit { digit | "," } [ "." { digit | "," } ] [ exponent ] .
+//	exponent    = ( "e" | "E" ) [ "+" | "-" ] digit { digit } .
+//
+// An exponent, as in "1.200000e-09 m" written by String, is separated from the unit by
+// white space.
+// Whitespace is allowed around each part. The input must not be longer than MaxInputLength.
+// A length in feet and inches, e.g. 5'11" or 5′ 11″ as pasted from documents, is returned
+// in "ft"; the inch mark may be left out, so 5'6 is 5.5 ft.
 func Parse(s string) (Quantity, error) {
+	return NewParseOptions().Parse(s)
+}
+
+// NumberFormat holds the separators of numbers in text input.
+type NumberFormat struct {
+	Group   string // digit group separator: ",", "." or "" for none
+	Decimal string // decimal separator: "." or ","
+}
+
+// Number formats for Parse: PointDecimal is the default, "1,234.5"; CommaDecimal is used in
+// most of Europe, "1.234,5".
+var (
+	PointDecimal = NumberFormat{",", "."}
+	CommaDecimal = NumberFormat{".", ","}
+)
+
+// ParseNumberFormat is Parse with the separators of the number given by nf, e.g.
+// ParseNumberFormat("1.234,56 km", CommaDecimal).
+func ParseNumberFormat(s string, nf NumberFormat) (Quantity, error) {
+	return ParseWith(s, WithNumberFormat(nf))
+}
+
+// parse returns the Quantity and its uncertainty, which is 0 if there is none.
+func parse(s string, o ParseOptions) (Quantity, float64, error) {
+	nf := o.NumberFormat
 	undef := Quantity{0, &UndefinedUnit}
+	if len(s) > MaxInputLength {
+		return undef, 0, &SyntaxError{abbreviate(s), "input too long"}
+	}
+	if match := feetInchRx.FindStringSubmatch(s); match != nil && o.AllowUnicode {
+		ft, _ := strconv.ParseFloat(match[2], 64)
+		in, _ := strconv.ParseFloat(match[3], 64)
+		if in >= 12 {
+			return undef, 0, &SyntaxError{s, "more than 11 inches in"}
+		}
+		if ft += in / 12; match[1] == "-" {
+			ft = -ft
+		}
+		mu, err := parseSymbol("ft", o)
+		return Quantity{ft, mu.Unit}, 0, err
+	}
+	var uncertainty float64
+	if match := uncertainRx.FindStringSubmatch(s); match != nil && !o.Strict {
+		var err error
+		if match[2] != "" {
+			uncertainty, err = parseNumber(match[2], s, nf)
+		} else {
+			uncertainty, err = strconv.ParseFloat(match[3], 64)
+			if i := strings.Index(match[1], nf.Decimal); i != -1 {
+				uncertainty *= math.Pow10(i + 1 - len(match[1]))
+			}
+		}
+		if err != nil {
+			return undef, 0, err
+		}
+		s = match[1] + " " + match[4]
+	}
 	match := muRx.FindStringSubmatch(s)
 	if len(match) != 3 {
-		return undef, errors.New("invalid quantity format [" + s + "]")
-	}
-	f := match[1]
-	if strings.Count(f, ".") > 1 {
-		return undef, errors.New("more than one decimal point in [" + s + "]")
+		return undef, 0, &SyntaxError{s, "invalid quantity format"}
 	}
-	f = strings.Replace(f, ",", "", -1)
-	value, err := strconv.ParseFloat(f, 64)
+	value, err := parseNumber(match[1], s, nf)
 	if err != nil {
-		return undef, err
+		return undef, 0, err
 	}
 	sym := strings.Trim(match[2], " \r\n\t")
-	mu, err := ParseSymbol(sym)
+	mu, err := parseSymbol(sym, o)
+	if err != nil {
+		return undef, 0, err
+	}
+	return Quantity{value, mu.Unit}, uncertainty, nil
+}
+
+func parseNumber(f, s string, nf NumberFormat) (float64, error) {
+	if strings.Count(f, nf.Decimal) > 1 {
+		return 0, &SyntaxError{s, "more than one decimal point in"}
+	}
+	for _, sep := range []string{".", ","} {
+		if sep != nf.Decimal && sep != nf.Group && strings.Contains(f, sep) {
+			return 0, &SyntaxError{s, "unexpected separator in"}
+		}
+	}
+	if nf.Group != "" {
+		f = strings.Replace(f, nf.Group, "", -1)
+	}
+	f = strings.Replace(f, nf.Decimal, ".", 1)
+	v, err := strconv.ParseFloat(f, 64)
 	if err != nil {
-		return undef, err
+		return 0, &SyntaxError{s, "invalid number in"}
 	}
-	return Quantity{value, mu.Unit}, nil
+	return v, nil
 }
 
 // Invalid checks if the Quantity is valid, i.e. if it has a unit.
 func (m Quantity) Invalid() bool {
-	return m.Unit == nil
+	return !m.defined()
 }
 
 // AreCompatible checks if two quantities are compatible. Compatibility means the exponents
@@ -146,9 +277,7 @@ func check(a, b Quantity) {
 // to the desired units with methods In or ConvertTo.
 func Add(a, b Quantity) Quantity {
 	check(a, b)
-	u := &Unit{"", 1, a.exponents}
-	u.setSymbol()
-	return Quantity{a.value*a.factor + b.value*b.factor, u}
+	return Quantity{a.value*a.factor + b.value*b.factor, siUnit(a.exponents)}
 }
 
 // Sum adds one or more Quantities. The Quantities should have compatible units.
@@ -179,9 +308,7 @@ func multi(
 		check(a, b)
 		op(&result, b)
 	}
-	u := &Unit{"", 1, a.exponents}
-	u.setSymbol()
-	return Quantity{result, u}
+	return Quantity{result, siUnit(a.exponents)}
 }
 
 // Neg negates a Quantity value. The unit does not change.
@@ -190,23 +317,69 @@ func Neg(a Quantity) Quantity {
 }
 
 // Mult multiplies 2 Quantities. A new unit will be calculated. The returned Quantity will
-// have SI units. Use In or ConvertTo to convert it to the desired unit.
+// have SI units. Use In or ConvertTo to convert it to the desired unit. An exponent overflow
+// is handled as set by PanicOnExponentOverflow.
 func Mult(a, b Quantity) Quantity {
-	return Quantity{a.value * a.factor * b.value * b.factor, addu(a.Unit, b.Unit)}
+	return unchecked(CheckedMult(a, b))
+}
+
+// CheckedMult is Mult, but returns an ErrExponentOverflow error if an exponent of the new
+// unit is out of the range -128..127.
+func CheckedMult(a, b Quantity) (Quantity, error) {
+	if err := checkDefined(a, b); err != nil {
+		return Quantity{0, &UndefinedUnit}, err
+	}
+	e, ok := combine(a.exponents, b.exponents, 1)
+	if !ok {
+		return Quantity{0, &UndefinedUnit}, fmt.Errorf("%w: %s * %s", ErrExponentOverflow, a.symbol, b.symbol)
+	}
+	return Quantity{a.value * a.factor * b.value * b.factor, siUnit(e)}, nil
 }
 
 // Div divides the first argument by the second. A new unit will be calculated.
 // The returned Quantity will have SI units. Use In or ConvertTo to convert it to the desired unit.
+// An exponent overflow is handled as set by PanicOnExponentOverflow.
 func Div(a, b Quantity) Quantity {
-	return Quantity{(a.value * a.factor) / (b.value * b.factor), subu(a.Unit, b.Unit)}
+	return unchecked(CheckedDiv(a, b))
+}
+
+// CheckedDiv is Div, but returns an ErrExponentOverflow error if an exponent of the new
+// unit is out of the range -128..127.
+func CheckedDiv(a, b Quantity) (Quantity, error) {
+	if err := checkDefined(a, b); err != nil {
+		return Quantity{0, &UndefinedUnit}, err
+	}
+	e, ok := combine(a.exponents, b.exponents, -1)
+	if !ok {
+		return Quantity{0, &UndefinedUnit}, fmt.Errorf("%w: %s / %s", ErrExponentOverflow, a.symbol, b.symbol)
+	}
+	return Quantity{(a.value * a.factor) / (b.value * b.factor), siUnit(e)}, nil
 }
 
 // Reciprocal calculates 1 divided by the given Quantity. The unit changes accordingly but
 // will be represented in SI units.
 func Reciprocal(a Quantity) Quantity {
-	u := &Unit{"", 1, negx(a.exponents)}
-	u.setSymbol()
-	return Quantity{1 / (a.value * a.factor), u}
+	return unchecked(CheckedDiv(Quantity{1, units[""]}, a))
+}
+
+// unchecked returns q, or handles an overflow as set by PanicOnExponentOverflow.
+func unchecked(q Quantity, err error) Quantity {
+	if PanicOnExponentOverflow && errors.Is(err, ErrExponentOverflow) {
+		panic(err.Error())
+	}
+	return q
+}
+
+// checkDefined returns an UnknownUnitError if a or b has the UndefinedUnit, e.g. after an
+// overflow, so the calculations with it give undefined results too.
+func checkDefined(a, b Quantity) error {
+	if !a.defined() {
+		return &UnknownUnitError{a.unitSymbol()}
+	}
+	if !b.defined() {
+		return &UnknownUnitError{b.unitSymbol()}
+	}
+	return nil
 }
 
 // MultFac multiplies a Quantity with a factor and returns the new Quantity. The unit
@@ -222,12 +395,41 @@ func DivFac(m Quantity, f float64) Quantity {
 }
 
 // Power raises the Quantity to the given power n. The exponents of the resulting unit must
-// be in the range -128..127.
+// be in the range -128..127; an overflow is handled as set by PanicOnExponentOverflow.
 func Power(a Quantity, n int8) Quantity {
-	calc := func(e int8) int8 { return e * n }
-	u := &Unit{"", 1, mapexp(a.exponents, calc)}
-	u.setSymbol()
-	return Quantity{math.Pow(a.value*a.factor, float64(n)), u}
+	return unchecked(CheckedPower(a, int(n)))
+}
+
+// CheckedPower is Power, but returns an ErrExponentOverflow error if an exponent of the
+// new unit is out of the range -128..127.
+func CheckedPower(a Quantity, n int) (Quantity, error) {
+	if err := checkDefined(a, a); err != nil {
+		return Quantity{0, &UndefinedUnit}, err
+	}
+	e, ok := combine([nBaseUnits]int8{}, a.exponents, n)
+	if !ok {
+		return Quantity{0, &UndefinedUnit}, fmt.Errorf("%w: %s to the power %d", ErrExponentOverflow, a.symbol, n)
+	}
+	return Quantity{math.Pow(a.value*a.factor, float64(n)), siUnit(e)}, nil
+}
+
+// PowF raises the Quantity to a real power x. This is allowed for dimensionless quantities
+// and when the exponents of the resulting unit are integers in the range -128..127, e.g.
+// the square root of an area. The returned Quantity has SI units. Other exponents give an
+// ErrInvalidExponent error, or ErrExponentOverflow if they are out of range.
+func PowF(q Quantity, x float64) (Quantity, error) {
+	var e [nBaseUnits]int8
+	for i, qe := range q.exponents {
+		r := float64(qe) * x
+		if r != math.Trunc(r) {
+			return Quantity{}, fmt.Errorf("%w: cannot raise %s to the power %g", ErrInvalidExponent, q.symbol, x)
+		}
+		if r < math.MinInt8 || r > math.MaxInt8 {
+			return Quantity{}, fmt.Errorf("%w: %s to the power %g", ErrExponentOverflow, q.symbol, x)
+		}
+		e[i] = int8(r)
+	}
+	return Quantity{math.Pow(q.value*q.factor, x), siUnit(e)}, nil
 }
 
 // Abs returns the absolute of Quantity: the result is always >= 0.
@@ -247,6 +449,59 @@ func Equal(a, b, epsilon Quantity) bool {
 	return Abs(Subtract(a, b)).value < epsilon.value*epsilon.factor
 }
 
+// RelativeDiff returns the absolute difference of two compatible quantities relative to
+// the larger absolute value of the two, e.g. 0.01 for 99 m and 1 hm. The result is 0 if
+// both are 0. An error is returned if the units are not compatible.
+func RelativeDiff(a, b Quantity) (float64, error) {
+	if err := compatible(a, b); err != nil {
+		return 0, err
+	}
+	x, y := a.value*a.factor, b.value*b.factor
+	if x == y {
+		return 0, nil
+	}
+	return math.Abs(x-y) / math.Max(math.Abs(x), math.Abs(y)), nil
+}
+
+// PercentChange returns the change from old to new as a percentage of old, e.g. 50 for
+// 2 m -> 300 cm. An error is returned if the units are not compatible or old is 0.
+func PercentChange(old, new Quantity) (float64, error) {
+	if err := compatible(old, new); err != nil {
+		return 0, err
+	}
+	x := old.value * old.factor
+	if x == 0 {
+		return 0, errors.New("percent change from zero: " + old.String())
+	}
+	return (new.value*new.factor - x) / math.Abs(x) * 100, nil
+}
+
+// PercentOf returns part as a percentage of whole, e.g. 25 for 50 cm of 2 m. An error is
+// returned if the units are not compatible or whole is 0.
+func PercentOf(part, whole Quantity) (float64, error) {
+	if err := compatible(part, whole); err != nil {
+		return 0, err
+	}
+	w := whole.value * whole.factor
+	if w == 0 {
+		return 0, errors.New("percent of zero: " + whole.String())
+	}
+	return part.value * part.factor / w * 100, nil
+}
+
+// Scale returns the given percentage of the Quantity in the same unit, e.g. 5 L for 25
+// percent of 20 L.
+func Scale(q Quantity, pct float64) Quantity {
+	return Quantity{q.value * pct / 100, q.Unit}
+}
+
+func compatible(a, b Quantity) error {
+	if !a.defined() || !b.defined() || !haveSameExponents(a.exponents, b.exponents) {
+		return &IncompatibleUnitsError{A: a.unitSymbol(), B: b.unitSymbol()}
+	}
+	return nil
+}
+
 // More checks if the first argument is greater than the second.
 func More(a, b Quantity) bool {
 	check(a, b)
@@ -261,19 +516,50 @@ func Less(a, b Quantity) bool {
 
 // ToSI returns a converted Quantity represented in SI units.
 func (m Quantity) ToSI() Quantity {
-	factor, u := m.toSI()
-	return Quantity{m.value * factor, &u}
+	return Quantity{m.value * m.factor, siUnit(m.exponents)}
+}
+
+// CacheKey returns a compact key for memoization, the SI value in the shortest decimal that
+// round trips and the SI unit, e.g. "6894.75729|m-1.kg.s-2" for 1 psi. Quantities have the
+// same key if they have the same value in SI units, e.g. 1 km and 1000 m, and different keys
+// otherwise, except that 0 and -0 share a key. As for Convert, "°C" is taken as a
+// temperature difference, so 1 °C and 1 K have the same key.
+func (m Quantity) CacheKey() string {
+	if !m.defined() {
+		return strconv.FormatFloat(m.value, 'g', -1, 64) + "|" + m.unitSymbol()
+	}
+	q := m.ToSI()
+	if q.value == 0 {
+		q.value = 0 // no -0
+	}
+	return strconv.FormatFloat(q.value, 'g', -1, 64) + "|" + q.symbol
+}
+
+// IsDimensionless returns true if the Quantity has no dimension, e.g. the ratio
+// of two lengths.
+func (m Quantity) IsDimensionless() bool {
+	return m.defined() && isDimensionless(m.exponents)
+}
+
+// AsFloat returns the value of a dimensionless Quantity, e.g. the result of dividing
+// a length by a length. An error is returned if the Quantity has a dimension.
+func AsFloat(q Quantity) (float64, error) {
+	if !q.IsDimensionless() {
+		return 0, fmt.Errorf("%w: %s", ErrNotDimensionless, q)
+	}
+	return q.value * q.factor, nil
 }
 
 // Dimensionality returns a vector representing the dimensionality of m
 func (m Quantity) Dimensionality() []int8 {
-	return m.exponents
+	e := m.exponents
+	return e[:]
 }
 
 // Normalize changes the Quantity to SI units.
 func (m *Quantity) Normalize() {
 	m.value *= m.factor
-	m.Unit = &Unit{makeSymbol(m.exponents), 1, m.exponents}
+	m.Unit = siUnit(m.exponents)
 }
 
 // Duration converts a Quantity with a duration unit to a time.Duration.
@@ -282,7 +568,36 @@ func Duration(m Quantity) (time.Duration, error) {
 	if si, ok := m.ConvertTo("s"); ok {
 		return time.Duration(si.Value()) * time.Second, nil
 	}
-	return time.Duration(0), errors.New("not a Duration: " + m.String())
+	return time.Duration(0), &IncompatibleUnitsError{m.symbol, "s", "not a Duration: " + m.String()}
+}
+
+// ConvertAll converts the quantities in src to the given unit and stores them in dst, which
+// may be the same slice as src. The unit is looked up only once and no memory is allocated
+// per element. An error is returned if dst is shorter than src, the unit is unknown, or a
+// quantity is not compatible; the elements of dst before that quantity have been set.
+func ConvertAll(dst, src []Quantity, to string) error {
+	if len(dst) < len(src) {
+		return fmt.Errorf("destination too short: %d < %d", len(dst), len(src))
+	}
+	target := UnitFor(to)
+	if target == &UndefinedUnit {
+		return &UnknownUnitError{to}
+	}
+	for i, q := range src {
+		if !haveSameExponents(q.exponents, target.exponents) {
+			return fmt.Errorf("element %d: %w", i, &IncompatibleUnitsError{A: q.symbol, B: to})
+		}
+		dst[i] = q.Convert(target)
+	}
+	return nil
+}
+
+// NormalizeAll changes all quantities to SI units in place, see Normalize. Units are values,
+// so no memory is allocated per element.
+func NormalizeAll(qs []Quantity) {
+	for i := range qs {
+		qs[i].Normalize()
+	}
 }
 
 // Quantities is a slice of Quantity values. Useful for sorting.
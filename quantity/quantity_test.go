@@ -1,12 +1,26 @@
 package quantity
 
 import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
+	"math/big"
+	"math/rand"
 	"os"
+	"reflect"
+	"regexp"
 	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"text/template"
 	"time"
+	"unicode/utf8"
 )
 
 func TestPanic(t *testing.T) {
@@ -90,7 +104,11 @@ func TestString(t *testing.T) {
 		{Q(12.3456, "kn"), "12.3456 kn"},
 		{Q(0, "kn"), "0.0000 kn"},
 		{Q(-14.581699, "mph"), "-14.5817 mph"},
-		{Q(0.00001, "m"), "0.0000 m"},
+		{Q(0.00001, "m"), "1.000000e-05 m"},
+		{Q(-1.2e-9, "m"), "-1.200000e-09 m"},
+		{Q(0.0001, "m"), "0.0001 m"},
+		{Q(6.02e23, "mol"), "6.020000e+23 mol"},
+		{Q(1e14, "J"), "100000000000000.0000 J"},
 	}
 	for _, d := range data {
 		s := d.input.String()
@@ -103,6 +121,11 @@ func TestString(t *testing.T) {
 		t.Error("setting default format failed")
 	}
 	DefaultFormat = "%.4f %s"
+	ScientificMin = 0
+	if Q(1e-5, "m").String() != "0.0000 m" {
+		t.Error("disabling scientific format failed")
+	}
+	ScientificMin = 1e-4
 	a := Q(123.5, "NZD")
 	if a.String() != "123.5000 NZD" {
 		t.Error("currency formatting failed", a)
@@ -291,6 +314,191 @@ func TestParse2(t *testing.T) {
 	}
 }
 
+func TestParseLimits(t *testing.T) {
+	data := []string{
+		"1 m127",
+		"1 m-128",
+		"1 m128",
+		"1 m99999999999999999999",
+		"1 s4/m64.m65",
+		"1 F32",
+		"1 m100.m100",
+		"1 " + strings.Repeat("m.", 200) + "m",
+		"1 m^2**s",
+		"1 YA17",
+		"1 yA17",
+	}
+	want := []bool{true, true, false, false, false, false, false, false, false, false, false}
+	if f, _ := FactorBetween("Ym", "m"); f != Yotta {
+		t.Errorf("Ym: got factor %g, want %g", f, Yotta)
+	}
+	for i, s := range data {
+		_, err := Parse(s)
+		if (err == nil) != want[i] {
+			t.Errorf("%.20s: got error %v, want success %v", s, err, want[i])
+		}
+		if err != nil && !errors.Is(err, ErrSyntax) {
+			t.Errorf("%.20s: %v is not a syntax error", s, err)
+		}
+	}
+
+	// the input in the error of a too long input is cut at a rune boundary
+	long := "12 " + strings.Repeat("°", MaxInputLength)
+	for _, parse := range []func(string) error{
+		func(s string) error { _, err := Parse(s); return err },
+		func(s string) error { _, err := ParseSymbol(s); return err },
+		func(s string) error { _, err := ParseWords(s); return err },
+		func(s string) error { _, err := ParseFilter(s); return err },
+		func(s string) error { _, err := ParseLatitude(s); return err },
+		func(s string) error { _, err := Eval(s); return err },
+	} {
+		var se *SyntaxError
+		if err := parse(long); !errors.As(err, &se) || !utf8.ValidString(se.Input) || !strings.HasSuffix(se.Input, "...") {
+			t.Error("expected: too long input cut at a rune boundary, actual:", err)
+		}
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	for _, s := range []string{"38J", "  -15.5  K  ", "1,000 kW/sr", "/12309.8m", "1.1 sq in",
+		"5.5.6 m", "9.81 ± 0.02 m/s2", "9.81(2) m/s2", "3 kg*m^2/s^-2", "1 m127"} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		q, err := Parse(s)
+		if err != nil {
+			return
+		}
+		if q.Invalid() {
+			t.Fatalf("%q: parsed without error into an invalid quantity", s)
+		}
+		if q.IsDimensionless() {
+			return
+		}
+		si, err := ParseSymbol(makeSymbol(q.exponents))
+		if err != nil {
+			t.Fatalf("%q: SI symbol %q does not parse: %v", s, makeSymbol(q.exponents), err)
+		}
+		if si.exponents != q.exponents {
+			t.Fatalf("%q: SI symbol %q has exponents %v, want %v", s, si.symbol, si.exponents, q.exponents)
+		}
+	})
+}
+
+func TestParseWords(t *testing.T) {
+	data := []struct {
+		input, expected string
+	}{
+		{"3 meters per second", "3 m/s"},
+		{"10 Kilometers per Hour", "10 km/h"},
+		{"5 pounds per square inch", "5 lb/in2"},
+		{"9.81 metres per second squared", "9.81 m/s2"},
+		{"2 cubic feet", "2 ft3"},
+		{"1,500 newton meters", "1500 N.m"},
+		{"250 millilitres", "250 mL"},
+		{"-4 degrees celsius", "-4 degC"},
+		{"3 nautical miles per hour", "3 kn"},
+		{"12 inches", "1 ft"},
+	}
+	for _, d := range data {
+		q, err := ParseWords(d.input)
+		expected := mustParse(t, d.expected)
+		if err != nil || !Equal(q, expected, Abs(MultFac(expected, 1e-12))) {
+			t.Error("input:", d.input, "expected:", expected, "actual:", q, err)
+		}
+	}
+	for _, s := range []string{"3", "3 meters per", "3 per second", "3 furlongs", "3 square", "x meters", "3 squared"} {
+		if q, err := ParseWords(s); err == nil {
+			t.Error("input:", s, "should fail, actual:", q)
+		}
+	}
+}
+
+func mustParse(t *testing.T, s string) Quantity {
+	q, err := Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return q
+}
+
+// unfreezeRegistry undoes FreezeRegistry for the other tests, including the units it cached.
+func unfreezeRegistry() {
+	atomic.StoreInt32(&frozen, 0)
+	for _, m := range []*sync.Map{&frozenParsed, &ratedParsed} {
+		m.Range(func(symbol, _ interface{}) bool {
+			m.Delete(symbol)
+			return true
+		})
+	}
+}
+
+func TestParseNumberFormat(t *testing.T) {
+	data := []struct {
+		input    string
+		nf       NumberFormat
+		expected float64
+		fail     bool
+	}{
+		{"1.234,56 km", CommaDecimal, 1234.56, false},
+		{"-0,5 m", CommaDecimal, -0.5, false},
+		{"1,234.56 km", PointDecimal, 1234.56, false},
+		{"1234,5 km", NumberFormat{"", ","}, 1234.5, false},
+		{"1.234,5 km", NumberFormat{"", ","}, 0, true},
+		{"1,2,3 m", CommaDecimal, 0, true},
+		{"1,5 m", NumberFormat{",", ","}, 0, true},
+		{"1 m", NumberFormat{" ", "."}, 0, true},
+		{"1.200000e-09 km", PointDecimal, 1.2e-9, false},
+		{"-1,5E+3 m", CommaDecimal, -1500, false},
+		{"1.5e3m", PointDecimal, 0, true},
+	}
+	for _, d := range data {
+		q, err := ParseNumberFormat(d.input, d.nf)
+		if d.fail != (err != nil) || !d.fail && q.Value() != d.expected {
+			t.Error("input:", d.input, d.nf, "expected:", d.expected, "fail:", d.fail, "actual:", q, err)
+		}
+	}
+}
+
+func TestSymbols(t *testing.T) {
+	UnitFor("km/h")
+	symbols := Symbols()
+	if !sort.StringsAreSorted(symbols) || len(symbols) < 50 {
+		t.Error("expected: sorted symbols, actual:", symbols)
+	}
+	for _, s := range symbols {
+		if s == "km/h" || s == "" {
+			t.Errorf("unexpected symbol %q", s)
+		}
+		if _, err := ParseSymbol(s); err != nil {
+			t.Error(err)
+		}
+	}
+	if q, err := Parse("6.2 L/100km"); err != nil || q.Symbol() != "L/100km" {
+		t.Error("expected: 6.2 L/100km, actual:", q, err)
+	}
+}
+
+func TestCompatibleUnits(t *testing.T) {
+	pressure := CompatibleUnits("Pa")
+	for _, s := range []string{"Pa", "psi", "bar", "mmHg"} {
+		if i := sort.SearchStrings(pressure, s); i == len(pressure) || pressure[i] != s {
+			t.Error("expected", s, "in", pressure)
+		}
+	}
+	for _, s := range pressure {
+		if !Q(1, "Pa").HasCompatibleUnit(s) {
+			t.Error("not a unit of pressure:", s)
+		}
+	}
+	if speed := CompatibleUnits("km/h"); len(speed) < 3 || speed[0] != "kn" {
+		t.Error("expected: kn kph mph, actual:", speed)
+	}
+	if CompatibleUnits("furlong") != nil {
+		t.Error("expected: nil for unknown unit")
+	}
+}
+
 func TestSort(t *testing.T) {
 	arr := Quantities{
 		Q(0.2, "M"),
@@ -320,18 +528,23 @@ func TestDuration(t *testing.T) {
 	}
 }
 
-//func TestPrefix(t *testing.T) {
-//	m1 := Q(25*Centi, "m")
-//	m2 := Q(25, "cm")
-//	if !Equal(m1, m2, Q(1e-6, "m")) {
-//		t.Error("not equal:", m1, m2)
-//	}
-//	m3 := Q(7*Cubic(Deci), "m3")
-//	m4 := Q(7, "L")
-//	if !AreCompatible(m3, m4) || !Equal(m3, m4, Q(1e-6, "m")) {
-//		t.Error("not equal:", m3, m4)
-//	}
-//}
+func TestPrefixConstants(t *testing.T) {
+	m1 := Q(25*Centi, "m")
+	m2 := Q(25, "cm")
+	if !Equal(m1, m2, Q(1e-6, "m")) {
+		t.Error("not equal:", m1, m2)
+	}
+	m3 := Q(7*Cubic(Deci), "m3")
+	m4 := Q(7, "L")
+	if !AreCompatible(m3, m4) || !Equal(m3, m4, Q(1e-9, "m3")) {
+		t.Error("not equal:", m3, m4)
+	}
+	m5 := Q(3*Square(Kilo), "m2")
+	m6 := Q(3, "km2")
+	if !Equal(m5, m6, Q(1e-3, "m2")) || Pow(Milli, -2) != 1e6 {
+		t.Error("not equal:", m5, m6)
+	}
+}
 
 func TestKFC(t *testing.T) {
 	var k Quantity
@@ -409,3 +622,2238 @@ func TestPrefix(t *testing.T) {
 		}
 	}
 }
+
+func TestExpectDimension(t *testing.T) {
+	if err := ExpectDimension(Q(3, "kph"), "m/s"); err != nil {
+		t.Error(err)
+	}
+	err := ExpectDimension(Q(3, "bar"), "m/s")
+	if err == nil || err.Error() != "got pressure (m⁻¹·kg·s⁻²), want speed (m·s⁻¹)" {
+		t.Error("unexpected error:", err)
+	}
+	if err := ExpectDimension(Q(3, "m"), "chickens"); err == nil {
+		t.Error("unknown unit accepted")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("MustBe should panic")
+		}
+	}()
+	MustBe(Q(1, "kg"), "m")
+}
+
+func TestMultiWordSymbols(t *testing.T) {
+	defer RestoreRegistry(SnapshotRegistry())
+	if _, err := Define("board ft", 144, "in3"); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{" board ft", "board ft ", "board  ft", "board\tft", "us gal .h"} {
+		if _, err := Define(s, 1, "m3"); !errors.Is(err, ErrSyntax) {
+			t.Errorf("%q: expected: syntax error, actual: %v", s, err)
+		}
+	}
+	data := []struct {
+		input, expected string
+	}{
+		{"3 board ft", "3.0000 board ft"},
+		{"3 board  ft / h", "3.0000 board ft/h"},
+		{"2 us gal . min-1", "2.0000 us gal.min-1"},
+		{"1  sq in ", "1.0000 sq in"},
+		{"2 board ft2", "2.0000 board ft2"},
+	}
+	for _, d := range data {
+		q, err := Parse(d.input)
+		if err != nil || q.String() != d.expected {
+			t.Error("expected:", d.expected, "actual:", q, err)
+		}
+	}
+	if q, ok := Q(3, "board ft").ConvertTo("ft3"); !ok || math.Abs(q.Value()-0.25) > 1e-9 {
+		t.Error("expected: 0.25 ft3, actual:", q)
+	}
+}
+
+func TestRestoreRegistry(t *testing.T) {
+	snapshot := SnapshotRegistry()
+	if _, err := Define("furlong", 201.168, "m"); err != nil {
+		t.Fatal(err)
+	}
+	if u := UnitFor("furlong/h"); u == &UndefinedUnit {
+		t.Fatal("furlong/h not calculated")
+	}
+	RestoreRegistry(snapshot)
+	if u := UnitFor("furlong"); u != &UndefinedUnit {
+		t.Error("furlong not removed")
+	}
+	if u := UnitFor("furlong/h"); u != &UndefinedUnit {
+		t.Error("furlong/h not removed from cache")
+	}
+	if _, err := Define("furlong", 201.168, "m"); err != nil {
+		t.Error("cannot define furlong after restore:", err)
+	}
+	RestoreRegistry(snapshot)
+
+	// exact factors, scales and rates are restored too: a furlong of measured zorps is not
+	// exact like the removed one
+	Define("zorp", 0.5/6894.75729, "psi")
+	Define("furlong", 402.336, "zorp")
+	if _, _, ok := UnitFor("furlong").ExactFactor(); ok {
+		t.Error("exact factor of the removed furlong kept")
+	}
+	RestoreRegistry(snapshot)
+	if err := RegisterScale(OrdinalScale{"zorp scale", "m", 0, []float64{1}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetCurrencyRate("NZD", 2); err != nil {
+		t.Fatal(err)
+	}
+	RestoreRegistry(snapshot)
+	if _, found := LookupScale("zorp scale"); found {
+		t.Error("zorp scale not removed")
+	}
+	if ratedUnit("NZD") != nil {
+		t.Error("expected: rate of NZD removed, actual:", Q(1, "NZD").In("USD"))
+	}
+}
+
+func TestResolvedFactor(t *testing.T) {
+	defer RestoreRegistry(SnapshotRegistry())
+	if _, err := Define("quux", 7, "lbf/sq in"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Define("bar2x", 2, "quux"); err != nil {
+		t.Fatal(err)
+	}
+	f, err := ResolvedFactor("bar2x")
+	if expected := 14 * 4.4482216152605 / 0.00064516; err != nil || math.Abs(f-expected) > 1e-9 {
+		t.Error("expected:", expected, "actual:", f, err)
+	}
+	if f, err := ResolvedFactor("km/h"); err != nil || math.Abs(f-1/3.6) > 1e-12 {
+		t.Error("expected: 0.2778, actual:", f, err)
+	}
+	if _, err := ResolvedFactor("furlong"); !errors.Is(err, ErrUnknownUnit) {
+		t.Error("expected: unknown unit error, actual:", err)
+	}
+	if _, err := Define("selfish", 2, "selfish.m"); !errors.Is(err, ErrCircularDefinition) {
+		t.Error("expected: circular definition error, actual:", err)
+	}
+}
+
+func TestDefineAll(t *testing.T) {
+	defer RestoreRegistry(SnapshotRegistry())
+	err := DefineAll(map[string]Definition{
+		"smoot":  {1.7018, "m"},
+		"bridge": {364.4, "smoot"},
+		"span":   {2, "bridge/s"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q, ok := Q(1, "bridge").ConvertTo("m"); !ok || fmt.Sprintf("%.2f", q.Value()) != "620.14" {
+		t.Error("expected 620.14 m, actual:", q)
+	}
+	err = DefineAll(map[string]Definition{
+		"foo1":  {2, "m"},
+		"smoot": {1, "m"}, // duplicate
+	})
+	if err == nil {
+		t.Error("duplicate symbol accepted")
+	}
+	if _, err := ParseSymbol("foo1"); err == nil {
+		t.Error("foo1 not rolled back")
+	}
+	err = DefineAll(map[string]Definition{
+		"foo2": {2, "bar2"},
+		"bar2": {2, "foo2.m"},
+	})
+	if err == nil {
+		t.Error("circular definition accepted")
+	}
+}
+
+func TestFactorBetween(t *testing.T) {
+	f, err := FactorBetween("ft", "in")
+	if err != nil || math.Abs(f-12) > 1e-9 {
+		t.Error("expected 12, actual:", f, err)
+	}
+	f, err = FactorBetween("km/h", "m/s")
+	if err != nil || math.Abs(f-1/3.6) > 1e-9 {
+		t.Error("expected 0.2778, actual:", f, err)
+	}
+	if _, err = FactorBetween("m", "kg"); err == nil {
+		t.Error("incompatible units accepted")
+	}
+	if _, err = FactorBetween("m", "chickens"); err == nil {
+		t.Error("unknown unit accepted")
+	}
+}
+
+func TestParseUncertain(t *testing.T) {
+	data := []struct {
+		s        string
+		expected string
+	}{
+		{"9.81 ± 0.02 m/s2", "9.8100 ± 0.0200 m/s2"},
+		{"9.81 +/- 0.02 m/s2", "9.8100 ± 0.0200 m/s2"},
+		{"9.81(2) m/s2", "9.8100 ± 0.0200 m/s2"},
+		{"1,250(15) kg", "1250.0000 ± 15.0000 kg"},
+		{"3.5 m", "3.5000 ± 0.0000 m"},
+	}
+	for _, d := range data {
+		u, err := ParseUncertain(d.s)
+		if err != nil {
+			t.Error(err)
+		} else if u.String() != d.expected {
+			t.Error("expected:", d.expected, "actual:", u)
+		}
+	}
+	for _, s := range []string{"9.81(2) m/s2", "9.81 ± 0.02 m/s2", "9.81 +/- 0 m/s2"} {
+		if q, err := Parse(s); err != nil || q.String() != "9.8100 m/s2" {
+			t.Error(s, "expected: 9.8100 m/s2 without uncertainty, actual:", q, err)
+		}
+	}
+	sum := AddUncertain(QU(3, 0.3, "m"), QU(400, 40, "cm"))
+	if sum.String() != "7.0000 ± 0.5000 m" {
+		t.Error("expected: 7.0000 ± 0.5000 m, actual:", sum)
+	}
+	products := []struct {
+		a, b     Uncertain
+		expected string
+	}{
+		{QU(0, 0.1, "m"), QU(2, 0.1, "m"), "0.0000 ± 0.2000 m2"},
+		{QU(3, 0.3, "m"), QU(400, 40, "cm"), "12.0000 ± 1.6971 m2"},
+		{QU(0, 0, "m"), QU(0, 0, "m"), "0.0000 ± 0.0000 m2"},
+	}
+	for _, d := range products {
+		if p := MultUncertain(d.a, d.b); p.String() != d.expected {
+			t.Error("expected:", d.expected, "actual:", p)
+		}
+	}
+}
+
+func TestGob(t *testing.T) {
+	var b bytes.Buffer
+	in := Quantities{Q(12.5, "km/h"), Q(3, "psi"), Mult(Q(2, "m"), Q(3, "N"))}
+	if err := gob.NewEncoder(&b).Encode(in); err != nil {
+		t.Fatal(err)
+	}
+	var out Quantities
+	if err := gob.NewDecoder(&b).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(in) != fmt.Sprint(out) {
+		t.Error("expected:", in, "actual:", out)
+	}
+	if out[1].Unit != UnitFor("psi") {
+		t.Error("registered unit not restored after decoding")
+	}
+	if !Equal(out[0], Q(12.5, "kph"), Q(1e-9, "m/s")) {
+		t.Error("expected: 12.5 km/h, actual:", out[0])
+	}
+}
+
+func TestJSON(t *testing.T) {
+	in := Quantities{Q(12.5, "km/h"), Q(1.1, "sq in"), Q(6.2, "L/100km"), Div(Q(1, "m"), Q(4, "m"))}
+	data, err := json.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out Quantities
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(in) != fmt.Sprint(out) || out[2].Unit != UnitFor("L/100km") {
+		t.Error("expected:", in, "actual:", out)
+	}
+	if err := json.Unmarshal([]byte(`["3 psi", {"value": 2, "unit": "m"}]`), &out); err != nil || out[0] != Q(3, "psi") {
+		t.Error("expected: [3 psi 2 m], actual:", out, err)
+	}
+	err = json.Unmarshal([]byte(`["3 psi", "2 furlong"]`), &out)
+	if !errors.Is(err, ErrUnknownUnit) || !strings.HasPrefix(err.Error(), "element 1:") {
+		t.Error("expected: element 1 unknown unit error, actual:", err)
+	}
+	if _, err := json.Marshal(Quantities{{0, &UndefinedUnit}}); !errors.Is(err, ErrUnknownUnit) {
+		t.Error("expected: unknown unit error, actual:", err)
+	}
+	if data, err := json.Marshal(Quantities{{}, Q(2, "m")}); err != nil || string(data) != `[null,{"value":2,"unit":"m"}]` {
+		t.Error(`expected: [null,{"value":2,"unit":"m"}], actual:`, string(data), err)
+	}
+	out = Quantities{Q(1, "m")}
+	if err := json.Unmarshal([]byte(`[null]`), &out); err != nil || len(out) != 1 || out[0] != (Quantity{}) {
+		t.Error("expected: [zero Quantity], actual:", out, err)
+	}
+	if qs, err := ParseQuantities([]string{"1 m", "2 ft"}); err != nil || qs[1] != Q(2, "ft") {
+		t.Error("expected: [1 m 2 ft], actual:", qs, err)
+	}
+	if _, err := ParseQuantities([]string{"1 m", "2 ft", "5.5.6 m"}); !errors.Is(err, ErrSyntax) ||
+		!strings.HasPrefix(err.Error(), "element 2:") {
+		t.Error("expected: element 2 syntax error, actual:", err)
+	}
+}
+
+func TestMarshalBinary(t *testing.T) {
+	for _, q := range []Quantity{Q(12.5, "km/h"), Q(-3, "sq in"), Div(Q(1, "m"), Q(4, "m")), {}} {
+		data, err := q.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out Quantity
+		if err := out.UnmarshalBinary(data); err != nil || !reflect.DeepEqual(out, q) {
+			t.Error("expected:", q.Inspect(), "actual:", out.Inspect(), err)
+		}
+		for i := 0; i < len(data); i++ {
+			if err := out.UnmarshalBinary(data[:i]); err == nil {
+				t.Error("truncated data accepted:", data[:i])
+			}
+		}
+	}
+	if data, _ := Q(12.5, "km/h").MarshalBinary(); len(data) != 34 {
+		t.Error("expected: 34 bytes, actual:", len(data))
+	}
+}
+
+func TestDimensionless(t *testing.T) {
+	r := Div(Q(10, "m"), Q(2, "m"))
+	if !r.IsDimensionless() || r.Symbol() != "" {
+		t.Error("expected dimensionless, actual:", r.Inspect())
+	}
+	if f, err := AsFloat(r); err != nil || f != 5 {
+		t.Error("expected: 5, actual:", f, err)
+	}
+	if f, err := AsFloat(Div(Q(1, "km"), Q(1, "ft"))); err != nil || math.Abs(f-3280.8399) > 1e-4 {
+		t.Error("expected: 3280.8399, actual:", f, err)
+	}
+	if _, err := AsFloat(Q(3, "m")); err == nil {
+		t.Error("dimension ignored")
+	}
+	a := Mult(Q(2, "km"), Q(3, "km"))
+	if a.String() != "6000000.0000 m2" || a.ToSI().Value() != 6e6 {
+		t.Error("expected: 6000000.0000 m2, actual:", a.Inspect())
+	}
+}
+
+func TestDimensionlessSymbol(t *testing.T) {
+	r := Div(Q(3, "km"), Q(1500, "m"))
+	if r.String() != "2.0000" {
+		t.Errorf("expected: 2.0000, actual: %q", r.String())
+	}
+	DimensionlessSymbol = "1"
+	if r.String() != "2.0000 1" {
+		t.Errorf("expected: 2.0000 1, actual: %q", r.String())
+	}
+	DimensionlessSymbol = ""
+	if s := (Quantity{value: 2}).String(); s != "2.0000 ?" {
+		t.Errorf("expected: 2.0000 ?, actual: %q", s)
+	}
+}
+
+func TestSIUnitSharing(t *testing.T) {
+	a := Add(Q(1, "km"), Q(1, "mi"))
+	b := Q(3, "ft").ToSI()
+	if a.Unit != b.Unit {
+		t.Error("SI units differ:", a.Inspect(), b.Inspect())
+	}
+	if Mult(Q(1, "N"), Q(2, "m")).Unit != Q(5, "J").ToSI().Unit {
+		t.Error("SI units of Mult differ")
+	}
+}
+
+func TestValueSemantics(t *testing.T) {
+	a := Q(1.2, "mph")
+	b := a
+	b.Normalize()
+	if a.String() != "1.2000 mph" || b.String() != "0.5364 m.s-1" {
+		t.Error("copy not independent:", a, b)
+	}
+	d := a.Dimensionality()
+	d[0] = 5
+	if a.Dimensionality()[0] != 1 {
+		t.Error("exponents changed through Dimensionality:", a.Inspect())
+	}
+	if Q(2, "m").Invalid() || !(Quantity{}).Invalid() {
+		t.Error("Invalid wrong")
+	}
+}
+
+// TestUnitField checks that code written for the embedded *Unit still compiles and works.
+func TestUnitField(t *testing.T) {
+	symbolOf := func(u *Unit) string { return u.Symbol() }
+	var q Quantity
+	if q.Unit != nil {
+		t.Error("expected: nil unit, actual:", q.Unit)
+	}
+	q = Q(3, "km/h")
+	if symbolOf(q.Unit) != "km/h" || q.Unit != UnitFor("km/h") {
+		t.Error("expected: km/h, actual:", symbolOf(q.Unit))
+	}
+	var u *Unit = Q(1, "psi").Unit
+	if u != UnitFor("psi") || Q(2, "psi").Convert(u).String() != "2.0000 psi" {
+		t.Error("expected: psi, actual:", u.Symbol())
+	}
+}
+
+func BenchmarkAdd(b *testing.B) {
+	x, y := Q(15, "km"), Q(2, "mi")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Add(x, y)
+	}
+}
+
+func BenchmarkMult(b *testing.B) {
+	x, y := Q(15, "N"), Q(2, "ft")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Mult(x, y)
+	}
+}
+
+func BenchmarkConvert(b *testing.B) {
+	x, ft := Q(15, "in"), UnitFor("ft")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		x.Convert(ft)
+	}
+}
+
+func TestConvertAllocs(t *testing.T) {
+	x, ft, psi := Q(15, "in"), UnitFor("ft"), UnitFor("psi")
+	if n := testing.AllocsPerRun(100, func() { x.Convert(ft); x.In("m"); Q(2, "bar").Convert(psi) }); n != 0 {
+		t.Error("expected: no allocations, actual:", n)
+	}
+}
+
+func BenchmarkToSI(b *testing.B) {
+	x := Q(12.5, "mph")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		x.ToSI()
+	}
+}
+
+func TestPowF(t *testing.T) {
+	g, err := PowF(Div(Q(20, "m"), Q(2, "m")), 0.3)
+	if err != nil || math.Abs(g.Value()-math.Pow(10, 0.3)) > 1e-9 || !g.IsDimensionless() {
+		t.Error("expected: 1.9953, actual:", g, err)
+	}
+	side, err := PowF(Q(4, "ha"), 0.5)
+	if err != nil || side.String() != "200.0000 m" {
+		t.Error("expected: 200.0000 m, actual:", side, err)
+	}
+	if _, err = PowF(Q(2, "m"), 0.5); !errors.Is(err, ErrInvalidExponent) || errors.Is(err, ErrIncompatibleUnits) {
+		t.Error("expected: invalid exponent error, actual:", err)
+	}
+	if _, err = PowF(Q(2, "m"), 200); !errors.Is(err, ErrExponentOverflow) {
+		t.Error("expected: exponent overflow error, actual:", err)
+	}
+}
+
+func TestRelativeDiff(t *testing.T) {
+	d, err := RelativeDiff(Q(99, "m"), Q(1, "hm"))
+	if err != nil || math.Abs(d-0.01) > 1e-12 {
+		t.Error("expected: 0.01, actual:", d, err)
+	}
+	p, err := PercentChange(Q(2, "m"), Q(300, "cm"))
+	if err != nil || math.Abs(p-50) > 1e-12 {
+		t.Error("expected: 50, actual:", p, err)
+	}
+	if _, err = PercentChange(Q(0, "m"), Q(1, "m")); err == nil {
+		t.Error("change from zero accepted")
+	}
+	if _, err = RelativeDiff(Q(1, "m"), Q(1, "s")); err == nil {
+		t.Error("incompatible units accepted")
+	}
+}
+
+func TestPercentOf(t *testing.T) {
+	if p, err := PercentOf(Q(50, "cm"), Q(2, "m")); err != nil || math.Abs(p-25) > 1e-12 {
+		t.Error("expected: 25, actual:", p, err)
+	}
+	if _, err := PercentOf(Q(50, "cm"), Q(2, "s")); !errors.Is(err, ErrIncompatibleUnits) {
+		t.Error("expected: incompatible units error, actual:", err)
+	}
+	if _, err := PercentOf(Q(50, "cm"), Q(0, "m")); err == nil {
+		t.Error("percent of zero accepted")
+	}
+	if s := Scale(Q(20, "L"), 25); s != Q(5, "L") {
+		t.Error("expected: 5 L, actual:", s)
+	}
+}
+
+func TestDebug(t *testing.T) {
+	d := Q(2, "km/h").Debug()
+	if d.Value != 2 || d.Symbol != "km/h" || math.Abs(d.SIFactor-1/3.6) > 1e-12 ||
+		len(d.Exponents) != 2 || d.Exponents["m"] != 1 || d.Exponents["s"] != -1 {
+		t.Errorf("unexpected debug info: %+v", d)
+	}
+	if s := Q(2, "km").Inspect(); s != "2 km = 2000 m (factor 1000)" {
+		t.Error("expected: 2 km = 2000 m (factor 1000), actual:", s)
+	}
+}
+
+func TestDefineISOCurrencies(t *testing.T) {
+	defer RestoreRegistry(SnapshotRegistry())
+	if _, err := Define("EUR", 1.1, "USD"); err != nil {
+		t.Fatal(err)
+	}
+	DefineISOCurrencies()
+	q, err := Parse("250 EUR")
+	if err != nil || !q.HasCompatibleUnit("$") {
+		t.Error("expected: 250 EUR, actual:", q, err)
+	}
+	if _, err = Parse("10,000 JPY"); err != nil {
+		t.Error(err)
+	}
+	if Q(1, "EUR").Debug().SIFactor != 1.1 {
+		t.Error("existing currency replaced")
+	}
+	DefineISOCurrencies()
+}
+
+func TestSIDerivedUnits(t *testing.T) {
+	data := []struct {
+		symbol, si string
+	}{
+		{"rad", "rad"},
+		{"sr", "sr"},
+		{"Hz", "s-1"},
+		{"N", "m.kg.s-2"},
+		{"Pa", "m-1.kg.s-2"},
+		{"J", "m2.kg.s-2"},
+		{"W", "m2.kg.s-3"},
+		{"C", "A.s"},
+		{"V", "m2.kg.A-1.s-3"},
+		{"F", "m-2.kg-1.A2.s4"},
+		{"Ω", "m2.kg.A-2.s-3"},
+		{"S", "m-2.kg-1.A2.s3"},
+		{"Wb", "m2.kg.A-1.s-2"},
+		{"T", "kg.A-1.s-2"},
+		{"H", "m2.kg.A-2.s-2"},
+		{"degC", "K"},
+		{"lm", "cd.sr"},
+		{"lx", "m-2.cd.sr"},
+		{"Bq", "s-1"},
+		{"Gy", "m2.s-2"},
+		{"Sv", "m2.s-2"},
+		{"kat", "mol.s-1"},
+	}
+	for _, d := range data {
+		q, err := Parse("2 m" + d.symbol)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if si := q.ToSI(); si.Symbol() != d.si || math.Abs(si.Value()-0.002) > 1e-12 {
+			t.Errorf("m%s: expected 0.002 %s, actual: %v", d.symbol, d.si, si)
+		}
+	}
+}
+
+func TestCGSUnits(t *testing.T) {
+	data := []struct {
+		s, si    string
+		expected float64
+	}{
+		{"1 cP", "Pa.s", 1e-3},
+		{"1 cSt", "m2/s", 1e-6},
+		{"250 dyn", "N", 2.5e-3},
+		{"1 Merg", "J", 0.1},
+		{"3 gauss", "T", 3e-4},
+		{"5 mGal", "m/s2", 5e-5},
+		{"250 mL", "L", 0.25},
+	}
+	for _, d := range data {
+		q, err := Parse(d.s)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if r, ok := q.ConvertTo(d.si); !ok || math.Abs(r.Value()-d.expected) > 1e-12 {
+			t.Error("expected:", d.expected, d.si, "actual:", r)
+		}
+	}
+	if _, err := ParseSymbol("kbar2"); err != nil {
+		t.Error(err)
+	}
+	if _, err := ParseSymbol("cgauss"); err == nil {
+		t.Error("prefix accepted for gauss")
+	}
+}
+
+func TestCount(t *testing.T) {
+	defer RestoreRegistry(SnapshotRegistry())
+	for _, symbol := range []string{"request", "page", "cycle"} {
+		if err := DefineCount(symbol); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rate := Q(120, "request/min")
+	if r, ok := rate.ConvertTo("count/s"); !ok || r.Value() != 2 {
+		t.Error("expected: 2 count/s, actual:", r)
+	}
+	if AreCompatible(Q(1, "cycle"), Q(1, "cycles")) {
+		t.Error("count compatible with angle")
+	}
+	if AreCompatible(Q(1, "page"), Div(Q(1, "m"), Q(1, "m"))) {
+		t.Error("count compatible with dimensionless")
+	}
+	if s := Mult(rate, Q(1, "h")).String(); s != "7200.0000 count" {
+		t.Error("expected: 7200.0000 count, actual:", s)
+	}
+}
+
+func TestCheckRegistry(t *testing.T) {
+	if _, err := Define("Âµs", 1e-6, "s"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Define("µs", 1e-6, "s"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Define("μs", 1e-6, "s"); err != nil {
+		t.Fatal(err)
+	}
+	defer delete(units, "Âµs")
+	defer delete(units, "µs")
+	defer delete(units, "μs")
+	found := make(map[string]bool)
+	for _, c := range CheckRegistry() {
+		found[strings.Join(c.Symbols, " ")] = true
+	}
+	for _, expected := range []string{"M m", "Âµs", "µs μs"} {
+		if !found[expected] {
+			t.Error("conflict not reported:", expected)
+		}
+	}
+	_, err := Define("m", 2, "ft")
+	if err == nil || !strings.Contains(err.Error(), "already defined as 1 m (length)") {
+		t.Error("expected duplicate details, actual:", err)
+	}
+}
+
+func TestFormatAligned(t *testing.T) {
+	lines := FormatAligned(Quantities{Q(12.5, "km"), Q(1, "mi"), Q(3, "kg"), Q(-250, "m")}, "km", 8, 2)
+	expected := []string{
+		"   12.50 km",
+		"    1.61 km",
+		"       ? km",
+		"   -0.25 km",
+	}
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected:\n%s\nactual:\n%s", strings.Join(expected, "\n"), strings.Join(lines, "\n"))
+	}
+}
+
+func TestFormatter(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			short := Formatter{Format: "%.1f %s"}
+			if s := short.String(Q(2.25, "m")); s != "2.2 m" {
+				t.Error("expected: 2.2 m, actual:", s)
+			}
+			if s := Q(2.25, "m").String(); s != "2.2500 m" {
+				t.Error("expected: 2.2500 m, actual:", s)
+			}
+		}()
+	}
+	wg.Wait()
+	data := []struct {
+		f        Formatter
+		q        Quantity
+		expected string
+	}{
+		{Formatter{}, Q(2.25, "m"), "2.2500 m"},
+		{Formatter{}, Q(1.2e-9, "m"), "1.200000e-09 m"},
+		{Formatter{Format: "%.1f %s"}, Q(1.2e-9, "m"), "0.0 m"},
+		{Formatter{Format: "%.1f %s"}, Q(2e20, "m"), "200000000000000000000.0 m"},
+		{Formatter{Format: "%.1f %s", ScientificFormat: "%.2e %s"}, Q(1.2e-9, "m"), "1.20e-09 m"},
+		{Formatter{ScientificFormat: "%.1e %s"}, Q(2e20, "m"), "2.0e+20 m"},
+		{Formatter{ScientificFormat: "%.1e %s"}, Q(2, "m"), "2.0000 m"},
+		{Formatter{ScientificFormat: "%.1e %s", ScientificMin: 10, ScientificMax: 100}, Q(2, "m"), "2.0e+00 m"},
+		{Formatter{ScientificFormat: "%.1e %s", ScientificMin: 10, ScientificMax: 100}, Q(20, "m"), "20.0000 m"},
+	}
+	for _, d := range data {
+		if s := d.f.String(d.q); s != d.expected {
+			t.Error("expected:", d.expected, "actual:", s)
+		}
+	}
+}
+
+func TestBestUnit(t *testing.T) {
+	data := []struct {
+		q          Quantity
+		candidates []string
+		expected   string
+	}{
+		{Q(0.00042, "m3"), []string{"m3", "L"}, "0.4200 L"},
+		{Q(0.00042, "m3"), []string{"m3", "L", "mL"}, "420.0000 mL"},
+		{Q(1500, "m"), []string{"mm", "m", "km"}, "1.5000 km"},
+		{Q(-2, "mm"), []string{"mm", "m", "km"}, "-2.0000 mm"},
+		{Q(3, "ft"), []string{"s", "kg"}, "3.0000 ft"},
+		{Q(0, "ft"), []string{"m"}, "0.0000 m"},
+		{Q(90, "min"), nil, "1.5000 h"},
+	}
+	for _, d := range data {
+		if s := BestUnit(d.q, d.candidates...).String(); s != d.expected {
+			t.Error("expected:", d.expected, "actual:", s)
+		}
+	}
+}
+
+func TestRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	var sum float64
+	for i := 0; i < 1000; i++ {
+		q, err := RandomBetween(Q(1, "m"), Q(300, "cm"), r)
+		if err != nil || q.Symbol() != "m" || q.Value() < 1 || q.Value() >= 3 {
+			t.Fatal("expected: 1 m <= q < 3 m, actual:", q, err)
+		}
+		q, err = RandomNormal(Q(20, "degC"), Q(0.5, "K"), r)
+		if err != nil || q.Symbol() != "degC" {
+			t.Fatal("expected: degC, actual:", q, err)
+		}
+		sum += q.Value()
+	}
+	if mean := sum / 1000; math.Abs(mean-20) > 0.1 {
+		t.Error("expected: mean 20, actual:", mean)
+	}
+	if _, err := RandomBetween(Q(1, "m"), Q(1, "s"), nil); !errors.Is(err, ErrIncompatibleUnits) {
+		t.Error("expected: incompatible units error, actual:", err)
+	}
+	if _, err := RandomBetween(Q(1, "m"), Q(1, "ft"), nil); err == nil {
+		t.Error("max < min accepted")
+	}
+	if _, err := RandomNormal(Q(1, "m"), Q(-1, "m"), nil); err == nil {
+		t.Error("negative standard deviation accepted")
+	}
+}
+
+func TestFormatMatrix(t *testing.T) {
+	lines, err := FormatMatrix([]string{"m", "ft"}, 9, 4)
+	expected := []string{
+		"           m        ft",
+		"m          1     3.281",
+		"ft    0.3048         1",
+	}
+	if err != nil || strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected:\n%s\nactual:\n%s %v", strings.Join(expected, "\n"), strings.Join(lines, "\n"), err)
+	}
+	if _, err := FormatMatrix([]string{"m", "s"}, 9, 4); !errors.Is(err, ErrIncompatibleUnits) {
+		t.Error("expected: incompatible units error, actual:", err)
+	}
+}
+
+func TestGradient(t *testing.T) {
+	g, err := Gradient(Q(11, "hPa"), Q(100, "km"))
+	if err != nil || g.Symbol() != "hPa/km" || math.Abs(g.Value()-0.11) > 1e-12 {
+		t.Error("expected: 0.11 hPa/km, actual:", g, err)
+	}
+	if !Equal(g, HectopascalPerKm(0.11), PascalPerMeter(1e-9)) {
+		t.Error("expected: 0.11 hPa/km, actual:", g)
+	}
+	if g, err := Gradient(Q(6.5, "K"), Q(1, "km")); err != nil || !Equal(g, KelvinPerKm(6.5), Q(1e-12, "K/m")) {
+		t.Error("expected: 6.5 K/km, actual:", g, err)
+	}
+	if g, err := Gradient(Q(230, "V"), Q(2, "mm")); err != nil || !Equal(g, VoltPerMeter(115000), Q(1e-6, "V/m")) {
+		t.Error("expected: 115000 V/m, actual:", g, err)
+	}
+	if _, err := Gradient(Q(1, "Pa"), Q(1, "s")); !errors.Is(err, ErrIncompatibleUnits) {
+		t.Error("expected: incompatible units error, actual:", err)
+	}
+	if _, err := Gradient(Q(1, "Pa"), Q(0, "m")); err == nil {
+		t.Error("gradient over zero distance accepted")
+	}
+}
+
+func TestThermalUnits(t *testing.T) {
+	u, ok := Q(1, "BTU/(hr·ft²·°F)").ConvertTo("W/(m2.K)")
+	if !ok || math.Abs(u.Value()-5.678263) > 1e-6 {
+		t.Error("expected: 5.678263 W/(m2.K), actual:", u)
+	}
+	if !Equal(u, HeatTransferCoefficient(5.678263), Q(1e-6, "W/m2.K")) {
+		t.Error("expected: 5.678263 W/(m2.K), actual:", u)
+	}
+	if c, ok := Q(1, "BTU/(lb.°F)").ConvertTo("J/(kg·K)"); !ok || math.Abs(c.Value()-4186.8) > 1e-6 {
+		t.Error("expected: 4186.8 J/(kg·K), actual:", c)
+	}
+	if SpecificHeat(1).DimensionName() != "specific heat capacity" ||
+		ThermalConductivity(1).DimensionName() != "thermal conductivity" {
+		t.Error("expected dimension names, actual:", SpecificHeat(1).DimensionName(), ThermalConductivity(1).DimensionName())
+	}
+	if _, err := ParseSymbol("J/(kg.K"); err == nil {
+		t.Error("unbalanced parenthesis accepted")
+	}
+}
+
+func TestCost(t *testing.T) {
+	c, err := Cost(Q(350, "kWh"), Q(0.25, "$/kWh"))
+	if err != nil || c.Symbol() != "$" || math.Abs(c.Value()-87.5) > 1e-9 {
+		t.Error("expected: 87.5 $, actual:", c, err)
+	}
+	c, err = Cost(Q(2, "GJ"), Q(0.3, "NZD/kWh"))
+	if err != nil || c.Symbol() != "NZD" || math.Abs(c.Value()-2e9/3.6e6*0.3) > 1e-9 {
+		t.Error("expected: 166.67 NZD, actual:", c, err)
+	}
+	if _, err := Cost(Q(2, "kW"), Q(0.3, "$/kWh")); !errors.Is(err, ErrIncompatibleUnits) {
+		t.Error("expected: incompatible units error, actual:", err)
+	}
+	if _, err := Cost(Q(2, "kWh"), Q(0.3, "$/kg")); !errors.Is(err, ErrIncompatibleUnits) {
+		t.Error("expected: incompatible units error, actual:", err)
+	}
+}
+
+func TestWeather(t *testing.T) {
+	data := []struct {
+		name     string
+		f        func() (Quantity, error)
+		expected float64 // degrees Celsius
+	}{
+		{"wind chill", func() (Quantity, error) { return WindChill(CtoK(-10), Q(30, "kph")) }, -19.5},
+		{"wind chill calm", func() (Quantity, error) { return WindChill(CtoK(-10), Q(1, "m/s")) }, -10},
+		{"wind chill warm", func() (Quantity, error) { return WindChill(CtoK(20), Q(50, "kph")) }, 20},
+		{"heat index", func() (Quantity, error) { return HeatIndex(FtoK(90), 70) }, FtoC(105.9)},
+		{"heat index mild", func() (Quantity, error) { return HeatIndex(FtoK(70), 50) }, FtoC(69.05)},
+		{"dew point", func() (Quantity, error) { return DewPoint(CtoK(25), 60) }, 16.7},
+	}
+	for _, d := range data {
+		q, err := d.f()
+		if err != nil || q.Symbol() != "K" {
+			t.Error(d.name, "expected: K, actual:", q, err)
+			continue
+		}
+		if c, _ := KtoC(q); math.Abs(c-d.expected) > 0.1 {
+			t.Error(d.name, "expected:", d.expected, "actual:", c)
+		}
+	}
+	if _, err := WindChill(CtoK(-10), Q(30, "m")); !errors.Is(err, ErrIncompatibleUnits) {
+		t.Error("expected: incompatible units error, actual:", err)
+	}
+	for _, temp := range []Quantity{Q(20, "degC"), Q(68, "°F")} {
+		if _, err := DewPoint(temp, 50); err == nil {
+			t.Error("expected an error for the relative temperature", temp)
+		}
+	}
+	if _, err := DewPoint(Q(1, "m"), 50); !errors.Is(err, ErrIncompatibleUnits) {
+		t.Error("expected: incompatible units error, actual:", err)
+	}
+	if _, err := HeatIndex(CtoK(30), 120); err == nil {
+		t.Error("relative humidity of 120 percent accepted")
+	}
+}
+
+func TestNormalizeAngle(t *testing.T) {
+	data := []struct {
+		q, start, expected Quantity
+	}{
+		{Q(370, "deg"), Q(0, "rad"), Q(10, "deg")},
+		{Q(-10, "deg"), Q(0, "rad"), Q(350, "deg")},
+		{Q(190, "deg"), Q(-180, "deg"), Q(-170, "deg")},
+		{Q(-180, "deg"), Q(-180, "deg"), Q(-180, "deg")},
+		{Q(180, "deg"), Q(-180, "deg"), Q(-180, "deg")},
+		{Q(7, "rad"), Q(0, "deg"), Q(7-2*math.Pi, "rad")},
+		{Q(2.5, "cycles"), Q(0, "rad"), Q(0.5, "cycles")},
+	}
+	for _, d := range data {
+		r, err := NormalizeAngle(d.q, d.start)
+		if err != nil || r.Symbol() != d.expected.Symbol() || math.Abs(r.Value()-d.expected.Value()) > 1e-9 {
+			t.Error(d.q, "from", d.start, "expected:", d.expected, "actual:", r, err)
+		}
+	}
+	if _, err := NormalizeAngle(Q(1, "m"), Q(0, "rad")); !errors.Is(err, ErrIncompatibleUnits) {
+		t.Error("expected: incompatible units error, actual:", err)
+	}
+}
+
+func TestDegMinSec(t *testing.T) {
+	data := []struct {
+		q        Quantity
+		expected string
+	}{
+		{Q(52.374, "deg"), "52°22′26.4″"},
+		{Q(-4.8867, "deg"), "-4°53′12.1″"},
+		{Q(0.5, "deg"), "0°30′0.0″"},
+		{Q(math.Pi, "rad"), "180°0′0.0″"},
+		{Q(0.99999, "deg"), "1°0′0.0″"},
+		{Q(-59.99999, "deg"), "-60°0′0.0″"},
+		{Q(1.99998, "deg"), "1°59′59.9″"},
+	}
+	for _, d := range data {
+		if a, err := DegMinSec(d.q); err != nil || a.String() != d.expected {
+			t.Error("expected:", d.expected, "actual:", a, err)
+		}
+	}
+	if _, err := DegMinSec(Q(1, "s")); err == nil {
+		t.Error("expected an error for 1 s")
+	}
+}
+
+func TestCoordinates(t *testing.T) {
+	data := []struct {
+		lat, lon   string
+		latD, lonD float64
+		expected   string
+	}{
+		{`41°24'12.2"N`, `2°10'26.5"E`, 41.403389, 2.174028, "41°24′12.2″N 2°10′26.5″E"},
+		{"33°51′35.9″ S", "151°12′40″ E", -33.859972, 151.211111, "33°51′35.9″S 151°12′40.0″E"},
+		{"-22.9068", "-43.1729", -22.9068, -43.1729, "22°54′24.5″S 43°10′22.4″W"},
+		{"51 28.6 n", "0 0 5.3 w", 51.476667, -0.001472, "51°28′36.0″N 0°0′5.3″W"},
+		{"0.99999", "-9.99999", 0.99999, -9.99999, "1°0′0.0″N 10°0′0.0″W"},
+	}
+	for _, d := range data {
+		lat, err := ParseLatitude(d.lat)
+		if err != nil || math.Abs(lat.Degrees()-d.latD) > 1e-6 {
+			t.Error(d.lat, "expected:", d.latD, "actual:", lat.Degrees(), err)
+		}
+		lon, err := ParseLongitude(d.lon)
+		if err != nil || math.Abs(lon.Degrees()-d.lonD) > 1e-6 {
+			t.Error(d.lon, "expected:", d.lonD, "actual:", lon.Degrees(), err)
+		}
+		if s := lat.String() + " " + lon.String(); s != d.expected {
+			t.Error("expected:", d.expected, "actual:", s)
+		}
+	}
+	for _, s := range []string{"91N", "-90.5", "41°60'N", "41°24'12\"E", "-41N", "N", `41"N`, "4x"} {
+		if lat, err := ParseLatitude(s); err == nil {
+			t.Error(s, "expected an error, actual:", lat)
+		}
+	}
+	if _, err := ParseLongitude("180.1 E"); err == nil {
+		t.Error("expected an error for 180.1 E")
+	}
+	if lon, err := NewLongitude(Q(-math.Pi, "rad")); err != nil || lon.Angle().Symbol() != "rad" {
+		t.Error("expected: -3.14159 rad, actual:", lon.Angle(), err)
+	}
+	if _, err := NewLatitude(Q(45, "m")); !errors.Is(err, ErrIncompatibleUnits) {
+		t.Error("expected: incompatible units error, actual:", err)
+	}
+}
+
+func TestMach(t *testing.T) {
+	sea := Q(StandardSoundSpeed, "m/s")
+	data := []struct {
+		speed, sound Quantity
+		expected     float64
+	}{
+		{Q(1225.06, "kph"), sea, 1},
+		{Q(2179, "kph"), sea, 1.7787},
+		{Q(900, "kph"), Q(295.07, "m/s"), 0.8473},
+		{Q(-300, "kn"), sea, -0.4535},
+	}
+	for _, d := range data {
+		if m, err := Mach(d.speed, d.sound); err != nil || math.Abs(m-d.expected) > 1e-4 {
+			t.Error(d.speed, "expected:", d.expected, "actual:", m, err)
+		}
+	}
+	for _, q := range [][2]Quantity{{Q(1, "m"), sea}, {sea, Q(340, "m")}, {sea, Q(0, "m/s")}} {
+		if _, err := Mach(q[0], q[1]); err == nil {
+			t.Error("expected an error for", q)
+		}
+	}
+}
+
+func TestFit(t *testing.T) {
+	xs := Quantities{Q(0, "h"), Q(60, "min"), Q(2, "h"), Q(3, "h")}
+	ys := Quantities{Q(20, "degC"), Q(21.9, "degC"), Q(24.1, "degC"), Q(26, "degC")}
+	slope, intercept, err := Fit(xs, ys)
+	if err != nil || slope.Symbol() != "degC/h" || math.Abs(slope.Value()-2.02) > 1e-9 {
+		t.Error("expected: 2.02 degC/h, actual:", slope, err)
+	}
+	if intercept.Symbol() != "degC" || math.Abs(intercept.Value()-19.97) > 1e-9 {
+		t.Error("expected: 19.97 degC, actual:", intercept)
+	}
+	slope, _, err = Fit(Quantities{Q(1, "m"), Q(2, "m")}, Quantities{Q(1, "N"), Q(3, "N")})
+	if err != nil || math.Abs(slope.In("N/m").Value()-2) > 1e-9 {
+		t.Error("expected: 2 N/m, actual:", slope, err)
+	}
+	for _, d := range [][2]Quantities{
+		{{Q(1, "s")}, {Q(1, "m")}},
+		{{Q(1, "s"), Q(2, "s")}, {Q(1, "m")}},
+		{{Q(1, "s"), Q(2, "m")}, {Q(1, "m"), Q(2, "m")}},
+		{{Q(1, "s"), Q(2, "s")}, {Q(1, "m"), Q(2, "kg")}},
+		{{Q(1, "s"), Q(1, "s")}, {Q(1, "m"), Q(2, "m")}},
+	} {
+		if _, _, err := Fit(d[0], d[1]); err == nil {
+			t.Error("expected an error for", d)
+		}
+	}
+}
+
+func TestLoadDefinitions(t *testing.T) {
+	defer RestoreRegistry(SnapshotRegistry())
+	err := LoadDefinitions(strings.NewReader(`{
+		"fathom": {"factor": 6, "base": "ft"},
+		"shackle": {"factor": 15, "base": "fathom"},
+		"fth": "fathom"
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q := Q(2, "shackle").In("fth"); math.Abs(q.Value()-30) > 1e-9 {
+		t.Error("expected: 30 fth, actual:", q)
+	}
+	for _, s := range []string{
+		`{"widget": {"factor": 2}}`,
+		`{"widget": 2}`,
+		`{"widget": "gadget"}`,
+		`{"widget": {"factor": 2, "base": "m"}, "m": "ft"}`,
+		`[1, 2]`,
+	} {
+		if err := LoadDefinitions(strings.NewReader(s)); err == nil {
+			t.Error("expected an error for", s)
+		}
+	}
+	if UnitFor("widget") != &UndefinedUnit {
+		t.Error("widget defined by a failed load")
+	}
+}
+
+func TestEval(t *testing.T) {
+	data := []struct {
+		s        string
+		expected Quantity
+	}{
+		{"3 kg * 9.81 m/s2 in lbf", Q(6.6161, "lbf")},
+		{"5 ft + 3 in", Q(5.25, "ft")},
+		{"5 ft - 6 in in cm", Q(137.16, "cm")},
+		{"10 m / 2 s", Q(5, "m/s")},
+		{"(10 m + 2 m) / -(2 s)", Q(-6, "m/s")},
+		{"(3 m)^2 in sq ft", Q(96.875, "sq ft")},
+		{"2 * 3 + 4", Q(10, "")},
+		{"1.5e3 m in km", Q(1.5, "km")},
+		{"2 us gal in L", Q(7.5708, "L")},
+		{"100 kWh * 0.25 $/kWh", Q(25, "$")},
+	}
+	for _, d := range data {
+		q, err := Eval(d.s)
+		if err != nil || !AreCompatible(q, d.expected) || math.Abs(q.Value()/d.expected.Value()-1) > 1e-4 {
+			t.Error(d.s, "expected:", d.expected, "actual:", q, err)
+		}
+	}
+	if q, _ := Eval("5 ft + 3 in"); q.Symbol() != "ft" {
+		t.Error("expected: ft, actual:", q.Symbol())
+	}
+	for _, s := range []string{"", "3 m +", "3 m + 2 s", "(3 m", "3 furlongs", "3 m in kg", "3 m 4", "(2 m)^x", "m"} {
+		if q, err := Eval(s); err == nil {
+			t.Error(s, "expected an error, actual:", q)
+		}
+	}
+}
+
+func TestRegistryJSON(t *testing.T) {
+	defer RestoreRegistry(SnapshotRegistry())
+	data, err := json.Marshal(SnapshotRegistry())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s RegistrySnapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		t.Fatal(err)
+	}
+	Define("zorp", 3, "m")
+	RestoreRegistry(s)
+	if UnitFor("zorp") != &UndefinedUnit {
+		t.Error("zorp not removed")
+	}
+	if q := Q(1, "mi").In("km"); math.Abs(q.Value()-1.609344) > 1e-12 {
+		t.Error("expected: 1.609344 km, actual:", q)
+	}
+
+	// exact factors, scales and rates round-trip too
+	DefineISOCurrencies()
+	if err := SetCurrencyRate("EUR", 0.92); err != nil {
+		t.Fatal(err)
+	}
+	if data, err = json.Marshal(SnapshotRegistry()); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		t.Fatal(err)
+	}
+	SetCurrencyRate("EUR", 0.5)
+	RestoreRegistry(s)
+	if _, found := LookupScale("beaufort"); !found {
+		t.Error("expected: beaufort scale restored")
+	}
+	if num, den, ok := UnitFor("ft").ExactFactor(); !ok || num != 381 || den != 1250 {
+		t.Error("expected: exact factor 381/1250 of ft, actual:", num, den, ok)
+	}
+	if q := Q(92, "EUR").In("USD"); math.Abs(q.Value()-100) > 1e-9 {
+		t.Error("expected: 100 USD, actual:", q)
+	}
+	for _, d := range []string{
+		`{"x":{"factor":1}}`,
+		`{"units":{"x":{"factor":0}}}`,
+		`{"units":{"x":{"factor":1,"exponents":[1,1,1,1,1,1,1,1,1,1,1,1,1]}}}`,
+		`{"units":{"x":{"factor":1}},"exact":{"x":"one"}}`,
+		`{"units":{"x":{"factor":1}},"scales":{"y":{"unit":"x","bounds":[2,1]}}}`,
+		`{"units":{"x":{"factor":1}},"rates":{"x":2}}`,
+		`[]`,
+	} {
+		if err := json.Unmarshal([]byte(d), &s); err == nil {
+			t.Error("expected an error for", d)
+		}
+	}
+}
+
+func TestExponentOverflow(t *testing.T) {
+	m100 := Power(Q(1, "m"), 100)
+	if m100.Invalid() || m100.Dimensionality()[0] != 100 {
+		t.Fatal("expected: m100, actual:", m100)
+	}
+	for _, f := range []func() (Quantity, error){
+		func() (Quantity, error) { return CheckedMult(m100, m100) },
+		func() (Quantity, error) { return CheckedDiv(Reciprocal(m100), m100) },
+		func() (Quantity, error) { return CheckedPower(Q(1, "m3"), 43) },
+		func() (Quantity, error) { return CheckedPower(Q(1, "s-2"), -64) },
+		func() (Quantity, error) { return CheckedPower(Q(1, "m2"), 1<<40) },
+		func() (Quantity, error) { return CheckedPower(Q(1, "m2"), math.MaxInt) },
+		func() (Quantity, error) { return CheckedPower(Q(1, "m-2"), math.MinInt) },
+		func() (Quantity, error) { return CheckedPower(Q(1, "m"), 256) },
+	} {
+		if q, err := f(); !errors.Is(err, ErrExponentOverflow) || !q.Invalid() {
+			t.Error("expected: exponent overflow, actual:", q, err)
+		}
+	}
+	if q, err := CheckedPower(Q(2, "m"), 127); err != nil || q.Dimensionality()[0] != 127 {
+		t.Error("expected: m127, actual:", q, err)
+	}
+	if q, err := CheckedPower(Q(1, "m-1"), 128); err != nil || q.Dimensionality()[0] != -128 {
+		t.Error("expected: m-128, actual:", q, err)
+	}
+	if PanicOnExponentOverflow {
+		t.Skip("GOUNITSPANIC is set")
+	}
+	// repeated products overflow without wrapping around to a valid unit
+	q := Q(1, "m")
+	for i := 0; i < 130; i++ {
+		q = Mult(q, Q(1, "m"))
+	}
+	if !q.Invalid() {
+		t.Error("expected: invalid quantity, actual:", q)
+	}
+	if q := Power(Q(1, "m2"), 100); !q.Invalid() {
+		t.Error("expected: invalid quantity, actual:", q)
+	}
+	m128, _ := CheckedPower(Q(1, "m-1"), 128)
+	if q := Reciprocal(m128); !q.Invalid() {
+		t.Error("expected: invalid quantity, actual:", q)
+	}
+	saved := PanicOnExponentOverflow
+	PanicOnExponentOverflow = true
+	defer func() {
+		PanicOnExponentOverflow = saved
+		if recover() == nil {
+			t.Error("expected a panic")
+		}
+	}()
+	Mult(m100, m100)
+}
+
+func TestVerifyFactors(t *testing.T) {
+	for _, m := range VerifyFactors() {
+		t.Errorf("factor of %s: registered %g, reference %g", m.Symbol, m.Registered, m.Reference)
+	}
+	defer RestoreRegistry(SnapshotRegistry())
+	units["ft"] = &Unit{"ft", 1 / 0.3048, units["ft"].exponents}
+	delete(units, "hp")
+	if m := VerifyFactors(); len(m) != 2 || m[0].Symbol != "ft" || m[1].Symbol != "hp" || m[1].Registered != 0 {
+		t.Error("expected: mismatches for ft and hp, actual:", m)
+	}
+}
+
+func TestVerifyAgainst(t *testing.T) {
+	for _, m := range VerifyConversions() {
+		t.Errorf("line %d: %g %s in %s: expected %g, actual %g, %v", m.Line, m.Value, m.From, m.To, m.Expected, m.Actual, m.Err)
+	}
+	data := `value,from,to,expected
+# comment
+1,ft,in,12
+2, mi, km, 3.2
+1,zorp,m,1
+1,m,kg,1
+x,m,cm,100
+1,m,cm
+`
+	m := VerifyAgainst(strings.NewReader(data))
+	if len(m) != 5 {
+		t.Fatal("expected: 5 mismatches, actual:", m)
+	}
+	if m[0].Line != 4 || m[0].Err != nil || math.Abs(m[0].Actual-3.218688) > 1e-12 {
+		t.Error("expected: line 4, 3.218688, actual:", m[0])
+	}
+	for i, expected := range []error{ErrUnknownUnit, ErrIncompatibleUnits, ErrSyntax} {
+		if !errors.Is(m[i+1].Err, expected) || !math.IsNaN(m[i+1].Actual) {
+			t.Error("expected:", expected, "actual:", m[i+1])
+		}
+	}
+	if m[4].Line != 8 || m[4].Err == nil {
+		t.Error("expected: field count error in line 8, actual:", m[4])
+	}
+}
+
+// FuzzConvertReference converts random values with the reference conversions and compares
+// the results with the scaled expected values.
+func FuzzConvertReference(f *testing.F) {
+	var rows [][]string
+	for _, line := range strings.Split(referenceConversions, "\n") {
+		if line != "" && !strings.HasPrefix(line, "#") {
+			rows = append(rows, strings.Split(line, ","))
+		}
+	}
+	f.Add(1.0, 0)
+	f.Add(-273.15, 17)
+	f.Add(1e-300, 5)
+	f.Fuzz(func(t *testing.T, v float64, i int) {
+		row := rows[(i%len(rows)+len(rows))%len(rows)]
+		value, _ := strconv.ParseFloat(row[0], 64)
+		expected, _ := strconv.ParseFloat(row[3], 64)
+		expected *= v / value
+		if a := math.Abs(expected); a < 1e-290 || a > 1e290 || math.IsNaN(a) {
+			return
+		}
+		csv := fmt.Sprintf("%v,%s,%s,%v", v, row[1], row[2], expected)
+		if m := VerifyAgainst(strings.NewReader(csv)); len(m) != 0 {
+			t.Errorf("%s: actual %v, %v", csv, m[0].Actual, m[0].Err)
+		}
+	})
+}
+
+func TestAudit(t *testing.T) {
+	has := func(anomalies []Anomaly, symbol, reason string) bool {
+		for _, a := range anomalies {
+			if a.Symbols[0] == symbol && strings.Contains(a.Reason, reason) {
+				return true
+			}
+		}
+		return false
+	}
+	anomalies := Audit()
+	for _, a := range anomalies {
+		if !strings.HasPrefix(a.Reason, "same definition") {
+			t.Error("unexpected anomaly:", a)
+		}
+	}
+	if !has(anomalies, "h", "same definition") {
+		t.Error("expected: h and hr with the same definition, actual:", anomalies)
+	}
+	defer RestoreRegistry(SnapshotRegistry())
+	units["cu ft"] = &Unit{"cu ft", 35.3146667, units["cu ft"].exponents}
+	units["sq ft"] = &Unit{"sq ft", 0.093, units["sq ft"].exponents}
+	Define("kn/s", 1, "m/s2")
+	units["%"] = &Unit{"%", 0.01, units["m"].exponents}
+	anomalies = Audit()
+	for _, d := range []struct{ symbol, reason string }{
+		{"cu ft", "looks inverted"},
+		{"sq ft", "differs from 0.0929"},
+		{"kn/s", "differs from 0.514"},
+		{"%", "dimensionless unit with exponents"},
+	} {
+		if !has(anomalies, d.symbol, d.reason) {
+			t.Error("expected:", d.symbol, d.reason, "actual:", anomalies)
+		}
+	}
+}
+
+func TestExactFactor(t *testing.T) {
+	data := []struct {
+		symbol   string
+		num, den int64
+		ok       bool
+	}{
+		{"ft", 381, 1250, true},
+		{"h", 3600, 1, true},
+		{"mi/h", 1397, 3125, true},
+		{"sq ft", 145161, 1562500, true},
+		{"kg.m/s2", 1, 1, true},
+		{"mL", 1, 1000000, true},
+		{"deg", 0, 0, false},
+		{"NZD", 0, 0, false},
+		{"Ym", 0, 0, false}, // too large for int64
+	}
+	for _, d := range data {
+		num, den, ok := UnitFor(d.symbol).ExactFactor()
+		if ok != d.ok || num != d.num || den != d.den {
+			t.Error(d.symbol, "expected:", d.num, d.den, d.ok, "actual:", num, den, ok)
+		}
+	}
+	if q := Q(1, "ft").In("in"); q.Value() != 12 {
+		t.Errorf("expected: exactly 12 in, actual: %.17g", q.Value())
+	}
+	q := QRat(1, 3, "yd")
+	for i := 0; i < 1000; i++ {
+		for _, symbol := range []string{"in", "ft", "mi", "yd"} {
+			q, _ = q.ConvertTo(symbol)
+		}
+	}
+	if q.Value() != 1.0/3 {
+		t.Errorf("expected: exactly 1/3 yd, actual: %.17g", q.Value())
+	}
+	defer RestoreRegistry(SnapshotRegistry())
+	Define("fathom", 6, "ft")
+	if num, den, ok := UnitFor("fathom").ExactFactor(); !ok || num != 1143 || den != 625 {
+		t.Error("fathom expected: 1143/625, actual:", num, den, ok)
+	}
+}
+
+func TestDiffString(t *testing.T) {
+	data := []struct {
+		a, b     Quantity
+		expected string
+	}{
+		{Q(5, "cm"), Q(3, "cm"), "Δ = 0.02 m (2 cm)"},
+		{Q(1, "ft"), Q(12.5, "in"), "Δ = -0.0127 m (-0.0416667 ft)"},
+		{Q(10, "m"), Q(2, "m"), "Δ = 8 m"},
+		{Q(3, ""), Q(1, ""), "Δ = 2"},
+		{Q(1, "m"), Q(1, "s"), "Δ undefined: 1.0000 m and 1.0000 s are not compatible"},
+	}
+	for _, d := range data {
+		if s := DiffString(d.a, d.b); s != d.expected {
+			t.Error("expected:", d.expected, "actual:", s)
+		}
+	}
+}
+
+func TestFormatISO(t *testing.T) {
+	data := []struct {
+		q        Quantity
+		prec     int
+		expected string
+	}{
+		{Q(9.81, "m/s2"), 2, "9.81 m/s²"},
+		{Q(9.81, "m.s-2"), 2, "9.81 m·s⁻²"},
+		{Q(4.2, "J/(kg.K)"), 1, "4.2 J/(kg·K)"},
+		{Q(100, "kph"), 0, "100 km/h"},
+		{Q(3, "sq ft"), 0, "3 ft²"},
+		{Q(90, "deg"), 0, "90°"},
+		{Q(21.5, "degC"), 1, "21.5 °C"},
+		{Q(2, "kN.m"), 0, "2 kN·m"},
+		{Q(1, "BTU/(hr.ft2.degF)"), 0, "1 BTU/(h·ft²·°F)"},
+		{Q(0.5, ""), 1, "0.5"},
+		{Mult(Q(1, "m"), Q(2, "kg")), 0, "2 m·kg"},
+	}
+	for _, d := range data {
+		if s := FormatISO(d.q, d.prec); s != d.expected {
+			t.Error("expected:", d.expected, "actual:", s)
+		}
+	}
+	anomalies := LintISO()
+	lint := make(map[string]string)
+	for _, a := range anomalies {
+		lint[a.Symbols[0]] = a.Reason
+	}
+	for symbol, expected := range map[string]string{"kph": "use km/h", "us gal": "symbol of several words", "L/100km": "compound symbol"} {
+		if !strings.HasPrefix(lint[symbol], expected) {
+			t.Error(symbol, "expected:", expected, "actual:", lint[symbol])
+		}
+	}
+	if _, found := lint["km"]; found {
+		t.Error("km reported")
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	data := []struct {
+		filter string
+		q      Quantity
+		pass   bool
+	}{
+		{">= 10 bar", Q(10, "bar"), true},
+		{">= 10 bar", Q(140, "psi"), false},
+		{"> 10 bar", Q(1.1, "MPa"), true},
+		{"< 5 km", Q(3, "mi"), true},
+		{"<5 km", Q(5000, "m"), false},
+		{"<= 5 km", Q(5000, "m"), true},
+		{"= 1 ft", Q(12, "in"), true},
+		{"!= 1 ft", Q(12, "in"), false},
+		{"10..20 degC", Q(10, "degC"), true},
+		{"10..20 degC", Q(20.5, "degC"), false},
+		{"-5..5 m", Q(-300, "cm"), true},
+		{"1 h..90 min", Q(4000, "s"), true},
+	}
+	for _, d := range data {
+		f, err := ParseFilter(d.filter)
+		if err != nil {
+			t.Error(d.filter, err)
+			continue
+		}
+		if pass, err := f(d.q); err != nil || pass != d.pass {
+			t.Error(d.filter, d.q, "expected:", d.pass, "actual:", pass, err)
+		}
+	}
+	f, _ := ParseFilter("< 5 km")
+	if _, err := f(Q(3, "kg")); !errors.Is(err, ErrIncompatibleUnits) {
+		t.Error("expected ErrIncompatibleUnits, actual:", err)
+	}
+	for _, s := range []string{"5 km", "<< 5 km", "20..10 degC", "1 m..2 s", "> 5 zorp"} {
+		if _, err := ParseFilter(s); err == nil {
+			t.Error("expected an error for", s)
+		}
+	}
+}
+
+func TestMaxRelativeError(t *testing.T) {
+	data := []struct {
+		from, to string
+		expected float64
+	}{
+		{"m", "m", 0},
+		{"lb", "lbs", 0},
+		{"ft", "in", 0x1p-53},
+		{"KiB", "bit", 0x1p-53},
+		{"mi", "km", 0x1p-52},
+		{"BTU", "kWh", 0x1p-52},
+		{"m", "kg", math.NaN()},
+		{"zorp", "m", math.NaN()},
+	}
+	for _, d := range data {
+		if e := MaxRelativeError(d.from, d.to); e != d.expected && !(math.IsNaN(e) && math.IsNaN(d.expected)) {
+			t.Error(d.from, d.to, "expected:", d.expected, "actual:", e)
+		}
+	}
+	// check the bound against the exact result for units with exact factors
+	pairs := [][2]string{{"mi", "km"}, {"ft", "in"}, {"us gal", "L"}, {"acre", "sq ft"}, {"oz", "lb"}, {"kph", "kn"}}
+	r := rand.New(rand.NewSource(1))
+	for _, p := range pairs {
+		bound := MaxRelativeError(p[0], p[1])
+		from, to := UnitFor(p[0]), UnitFor(p[1])
+		ratio := new(big.Rat).Quo(exactFactor(from.symbol, from.factor), exactFactor(to.symbol, to.factor))
+		for i := 0; i < 1000; i++ {
+			v := math.Ldexp(r.Float64(), r.Intn(1200)-600)
+			got := Q(v, p[0]).In(p[1]).Value()
+			want := new(big.Rat).Mul(new(big.Rat).SetFloat64(v), ratio)
+			diff := new(big.Rat).Sub(new(big.Rat).SetFloat64(got), want)
+			rel, _ := new(big.Rat).Quo(diff.Abs(diff), want).Float64()
+			if rel > bound {
+				t.Fatalf("%g %s in %s: relative error %g > %g", v, p[0], p[1], rel, bound)
+			}
+			back := Q(got, p[1]).In(p[0]).Value()
+			if math.Abs(back-v) > 2*bound*v {
+				t.Fatalf("%g %s round trip: %g", v, p[0], back)
+			}
+		}
+	}
+	// the value is multiplied by the ratio only, so the SI value of extreme values may overflow
+	if v := Q(1e305, "PiB").In("TiB").Value(); v != 1.024e308 {
+		t.Error("expected 1.024e308 TiB, actual:", v)
+	}
+}
+
+func TestAccumulator(t *testing.T) {
+	var a Accumulator
+	if q, err := a.Result("kg"); err != nil || q.String() != "0.0000 kg" {
+		t.Error("expected: 0.0000 kg, actual:", q, err)
+	}
+	for i := 0; i < 1000000; i++ {
+		a.Add(Q(0.1, "kg"))
+	}
+	a.Add(Q(1, "lb"))
+	a.Sub(Q(1, "lb"))
+	a.Sub(Q(99900, "kg"))
+	if q, err := a.Result("t"); err != nil || math.Abs(q.Value()-0.1) > 1e-12 || a.Len() != 1000003 {
+		t.Error("expected: 0.1 t, actual:", q.Value(), err, a.Len())
+	}
+	if _, err := a.Result("m"); !errors.Is(err, ErrIncompatibleUnits) {
+		t.Error("expected ErrIncompatibleUnits, actual:", err)
+	}
+	if _, err := a.Result("zorp"); !errors.Is(err, ErrUnknownUnit) {
+		t.Error("expected ErrUnknownUnit, actual:", err)
+	}
+	a.Add(Q(1, "m"))
+	a.Add(Q(1, "kg"))
+	if _, err := a.Result("kg"); !errors.Is(err, ErrIncompatibleUnits) || !strings.Contains(err.Error(), "item 1000003") {
+		t.Error("expected ErrIncompatibleUnits for item 1000003, actual:", err)
+	}
+	a.Reset()
+	a.Add(Q(2, "ft"))
+	a.Add(Q(12, "in"))
+	if q, err := a.Result("yd"); err != nil || q.Value() != 1 {
+		t.Error("expected: 1 yd, actual:", q, err)
+	}
+}
+
+func BenchmarkAccumulator(b *testing.B) {
+	q := Q(12.5, "USD")
+	var a Accumulator
+	for i := 0; i < b.N; i++ {
+		a.Add(q)
+	}
+	a.Result("USD")
+}
+
+func TestMoneyBag(t *testing.T) {
+	defer RestoreRegistry(SnapshotRegistry())
+	DefineISOCurrencies()
+	var b MoneyBag
+	for _, q := range []Quantity{Q(20, "EUR"), Q(15, "USD"), Q(5, "EUR"), Q(10, "NZD")} {
+		if err := b.Add(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b.Sub(Q(10, "NZD"))
+	if codes := b.Codes(); strings.Join(codes, " ") != "EUR USD" {
+		t.Error("expected: EUR USD, actual:", codes)
+	}
+	if q := b.Balance("EUR"); q.Value() != 25 {
+		t.Error("expected: 25 EUR, actual:", q)
+	}
+	if q := b.Balance("GBP"); q.Value() != 0 || q.Symbol() != "GBP" {
+		t.Error("expected: 0 GBP, actual:", q)
+	}
+	rates := RateFunc(func(from, to string) (float64, error) {
+		switch from + to {
+		case "EURUSD":
+			return 1.1, nil
+		case "USDEUR":
+			return 1 / 1.1, nil
+		}
+		return 0, errors.New("no rate")
+	})
+	if q, err := b.TotalIn("USD", rates); err != nil || q.String() != "42.5000 USD" {
+		t.Error("expected: 42.5000 USD, actual:", q, err)
+	}
+	if _, err := b.TotalIn("EUR", RegistryRates); err == nil || !strings.Contains(err.Error(), "no rate for EUR") {
+		t.Error("expected: no rate error, actual:", err)
+	}
+	SetCurrencyRate("EUR", 0.8)
+	if q, err := b.TotalIn("EUR", RegistryRates); err != nil || q.String() != "37.0000 EUR" {
+		t.Error("expected: 37.0000 EUR, actual:", q, err)
+	}
+	if _, err := b.TotalIn("GBP", rates); err == nil || !strings.Contains(err.Error(), "no rate") {
+		t.Error("expected: no rate error, actual:", err)
+	}
+	if err := b.Add(Q(3, "kg")); !errors.Is(err, ErrIncompatibleUnits) {
+		t.Error("expected ErrIncompatibleUnits, actual:", err)
+	}
+	if _, err := b.TotalIn("XYZ", rates); !errors.Is(err, ErrUnknownUnit) {
+		t.Error("expected ErrUnknownUnit, actual:", err)
+	}
+}
+
+func TestStringFuncs(t *testing.T) {
+	if s, err := ConvertString("5 km", "mi"); err != nil || s != "3.1069 mi" {
+		t.Error("expected: 3.1069 mi, actual:", s, err)
+	}
+	if s, err := FormatString("5 km", "%.1f %s"); err != nil || s != "5.0 km" {
+		t.Error("expected: 5.0 km, actual:", s, err)
+	}
+	if s, err := AddStrings("5 ft", "3 in"); err != nil || s != "5.2500 ft" {
+		t.Error("expected: 5.2500 ft, actual:", s, err)
+	}
+	if v, err := ValueIn("2 h", "min"); err != nil || v != 120 {
+		t.Error("expected: 120, actual:", v, err)
+	}
+	if _, err := ConvertString("5 km", "kg"); !errors.Is(err, ErrIncompatibleUnits) {
+		t.Error("expected ErrIncompatibleUnits, actual:", err)
+	}
+	if _, err := ValueIn("5 km", "zorp"); !errors.Is(err, ErrUnknownUnit) {
+		t.Error("expected ErrUnknownUnit, actual:", err)
+	}
+	if _, err := AddStrings("5 km", "3 s"); !errors.Is(err, ErrIncompatibleUnits) {
+		t.Error("expected ErrIncompatibleUnits, actual:", err)
+	}
+	if _, err := FormatString("five km", "%.1f %s"); !errors.Is(err, ErrSyntax) {
+		t.Error("expected ErrSyntax, actual:", err)
+	}
+	tmpl := template.Must(template.New("").Funcs(TemplateFuncs()).Parse(
+		`{{ "5 km" | unitsConvert "mi" }}, {{ .H | unitsFormat "%.0f %s" }}, {{ "1500 m" | unitsBest "m" "km" }}, ` +
+			`{{ "3 in" | unitsAdd "5 ft" }}, {{ .D | unitsValue "km" | printf "%.1f" }}`))
+	var b strings.Builder
+	if err := tmpl.Execute(&b, map[string]string{"H": "180.4 cm", "D": "26.2 mi"}); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "3.1069 mi, 180 cm, 1.5000 km, 5.2500 ft, 42.2"; b.String() != expected {
+		t.Error("expected:", expected, "actual:", b.String())
+	}
+	if err := tmpl.Execute(&b, map[string]string{"H": "tall", "D": "26.2 mi"}); err == nil {
+		t.Error("expected an error for an invalid quantity")
+	}
+}
+
+func TestParseLenient(t *testing.T) {
+	data := []struct {
+		input, expected string
+	}{
+		{"100 KM/HR", "100.0000 km/hr"},
+		{" 5 m /s ", "5.0000 m/s"},
+		{"70 Kg", "70.0000 kg"},
+		{"2 KWH", "2.0000 kWh"},
+		{"4.2 J/(KG.K)", "4.2000 J/(kg.K)"},
+		{"3 US GAL", "3.0000 us gal"},
+		{"20 DEGC", "20.0000 degC"},
+		{"60 MPH", "60.0000 mph"},
+		{"5 kpa", "5.0000 kPa"},
+		{"5 Pa", "5.0000 Pa"},
+	}
+	for _, d := range data {
+		q, err := ParseLenient(d.input)
+		if err != nil || q.String() != d.expected {
+			t.Error(d.input, "expected:", d.expected, "actual:", q, err)
+		}
+	}
+	ambiguous := []struct {
+		input, readings string
+	}{
+		{"5 pa", "Pa or pA"},
+		{"3 MM", "Mm or mm"},
+		{"1,013 MBAR", "Mbar or mbar"},
+		{"100 KM/H", "H or h"},
+		{"1 KM/S", "S or s"},
+		{"1 mw", "MW or mW"},
+	}
+	for _, d := range ambiguous {
+		if _, err := ParseLenient(d.input); !errors.Is(err, ErrSyntax) || !strings.Contains(err.Error(), d.readings) {
+			t.Error(d.input, "expected ambiguous unit error with:", d.readings, "actual:", err)
+		}
+	}
+	if _, err := ParseLenient("5 ZORP"); !errors.Is(err, ErrUnknownUnit) {
+		t.Error("expected ErrUnknownUnit, actual:", err)
+	}
+	if _, err := Parse("100 KM/H"); err == nil {
+		t.Error("expected Parse to be strict")
+	}
+}
+
+func TestParseMarks(t *testing.T) {
+	data := []struct {
+		input, expected string
+	}{
+		{"6'", "6.0000 ft"},
+		{"11\"", "11.0000 in"},
+		{"12''", "12.0000 in"},
+		{"5'6\"", "5.5000 ft"},
+		{"5′ 6″", "5.5000 ft"},
+		{"-5’ 6”", "-5.5000 ft"},
+		{"45°", "45.0000 deg"},
+		{"90 °/s", "90.0000 deg/s"},
+		{"20°C", "20.0000 °C"},
+		{"20 ° C", "20.0000 °C"},
+		{"68°F", "68.0000 °F"},
+		{"5'6", "5.5000 ft"},
+	}
+	for _, d := range data {
+		q, err := Parse(d.input)
+		if err != nil || q.String() != d.expected {
+			t.Error(d.input, "expected:", d.expected, "actual:", q, err)
+		}
+	}
+	if q, _ := Parse("5'6\""); q.In("cm").Format("%.2f %s") != "167.64 cm" {
+		t.Error("expected: 167.64 cm, actual:", q.In("cm"))
+	}
+	for _, s := range []string{"5'13\"", "5'6'", "5\"6'"} {
+		if _, err := Parse(s); err == nil {
+			t.Error("expected an error for", s)
+		}
+	}
+	for _, s := range []string{"11″", "30′", "5 ′/s"} {
+		if _, err := Parse(s); !errors.Is(err, ErrSyntax) || !strings.Contains(err.Error(), "ambiguous") {
+			t.Error(s, "expected ambiguous unit error, actual:", err)
+		}
+	}
+}
+
+func TestUnitVectors(t *testing.T) {
+	x, f, err := DimensionVector("km/h")
+	if err != nil || x != [nBaseUnits]int8{1, 0, 0, 0, 0, 0, 0, 0, 0, 0, -1} || math.Abs(f-1/3.6) > 1e-15 {
+		t.Error("expected: m.s-1 and 1/3.6, actual:", x, f, err)
+	}
+	if _, _, err := DimensionVector("zorp"); !errors.Is(err, ErrUnknownUnit) {
+		t.Error("expected ErrUnknownUnit, actual:", err)
+	}
+	vectors := UnitVectors()
+	if len(vectors) != len(Symbols()) {
+		t.Error("expected a vector per symbol, actual:", len(vectors), len(Symbols()))
+	}
+	var b bytes.Buffer
+	if err := WriteUnitVectors(&b); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(b.String(), "\n")
+	if lines[0] != "symbol,factor,m,kg,K,A,cd,mol,rad,sr,¤,byte,s,count" {
+		t.Error("unexpected header:", lines[0])
+	}
+	if strings.Contains(b.String(), "\nkm/h,") || !strings.Contains(b.String(), "\nmi,1609.344,1,0,0,0,0,0,0,0,0,0,0,0\n") {
+		t.Error("expected registered units only, actual:", b.String())
+	}
+	if len(lines) != len(vectors)+2 {
+		t.Error("expected a line per unit, actual:", len(lines))
+	}
+}
+
+func TestBaseUnits(t *testing.T) {
+	// the order is part of the API, see BaseUnitsVersion
+	expected := []string{"m", "kg", "K", "A", "cd", "mol", "rad", "sr", "¤", "byte", "s", "count"}
+	b := BaseUnits()
+	if strings.Join(b, " ") != strings.Join(expected, " ") || len(b) != NumBaseUnits || BaseUnitsVersion != 1 {
+		t.Error("expected:", expected, "actual:", b, NumBaseUnits, BaseUnitsVersion)
+	}
+	b[0] = "x"
+	if BaseUnits()[0] != "m" {
+		t.Error("BaseUnits returned the internal array")
+	}
+	for i, symbol := range expected {
+		x, _, _ := DimensionVector(symbol)
+		if x[i] != 1 {
+			t.Error(symbol, "expected exponent 1 at index", i, "actual:", x)
+		}
+	}
+}
+
+func TestParseOptions(t *testing.T) {
+	strict := NewParseOptions(Strict())
+	for _, s := range []string{"9.81 m/s2", "-1,500 N.m/s2", "3 us gal", "20 °C", "5m"} {
+		if _, err := strict.Parse(s); err != nil {
+			t.Error(s, err)
+		}
+	}
+	for _, s := range []string{"9.81 m / s2", "9.81 m/s²", "2 N·m", "2 N*m", "3 us  gal", "5'11\"", "45°", "3 ± 1 m", "3 s^2"} {
+		if _, err := strict.Parse(s); !errors.Is(err, ErrSyntax) {
+			t.Error(s, "expected ErrSyntax, actual:", err)
+		}
+		if _, err := Parse(s); err != nil {
+			t.Error(s, err)
+		}
+	}
+	if _, err := ParseWith("9.81 m / s2", AllowUnicode(false)); err != nil {
+		t.Error(err)
+	}
+	if _, err := ParseSymbolWith("m / s²", AllowSpaces(false)); !errors.Is(err, ErrSyntax) {
+		t.Error("expected ErrSyntax, actual:", err)
+	}
+	if q, err := ParseWith("1.234,5 km", WithNumberFormat(CommaDecimal), Strict()); err != nil || q.Value() != 1234.5 {
+		t.Error("expected: 1234.5 km, actual:", q, err)
+	}
+	if _, err := ParseWith("1 km", WithNumberFormat(NumberFormat{".", "."})); err == nil {
+		t.Error("expected an error for an invalid number format")
+	}
+
+	// a registry of one tenant does not see the units of another
+	snap := SnapshotRegistry()
+	defer RestoreRegistry(snap)
+	Define("zorp", 3, "m")
+	tenant := NewParseOptions(WithRegistry(snap))
+	if _, err := tenant.Parse("2 zorp"); !errors.Is(err, ErrUnknownUnit) {
+		t.Error("expected ErrUnknownUnit, actual:", err)
+	}
+	if q, err := tenant.Parse("2 km/h"); err != nil || q.ToSI().Format("%.4f %s") != "0.5556 m.s-1" {
+		t.Error("expected: 2 km/h, actual:", q, err)
+	}
+	if q, err := Parse("2 zorp"); err != nil || q.ToSI().Value() != 6 {
+		t.Error("expected: 2 zorp, actual:", q, err)
+	}
+}
+
+func TestCountUnits(t *testing.T) {
+	data := []struct {
+		input, to string
+		expected  float64
+	}{
+		{"3 dozen", "count", 36},
+		{"2 gross", "dozen", 24},
+		{"4 score", "count", 80},
+		{"1 gross/h", "count/min", 2.4},
+		{"150 bp", "", 0.015},
+	}
+	for _, d := range data {
+		q, err := Parse(d.input)
+		if err != nil {
+			t.Error(d.input, err)
+			continue
+		}
+		if c, ok := q.ConvertTo(d.to); !ok || math.Abs(c.Value()-d.expected) > 1e-12 {
+			t.Error(d.input, "expected:", d.expected, d.to, "actual:", c, ok)
+		}
+	}
+	if AreCompatible(Q(1, "dozen"), Q(1, "bp")) {
+		t.Error("counts must not be compatible with ratios")
+	}
+}
+
+func TestQuantize(t *testing.T) {
+	data := []struct {
+		q, step  Quantity
+		mode     RoundMode
+		expected string
+	}{
+		{Q(47, "min"), Q(15, "min"), RoundUp, "60.0000 min"},
+		{Q(47, "min"), Q(15, "min"), RoundDown, "45.0000 min"},
+		{Q(47, "min"), Q(15, "min"), RoundNearest, "45.0000 min"},
+		{Q(0.3, "kWh"), Q(0.1, "kWh"), RoundDown, "0.3000 kWh"},
+		{Q(0.3, "kWh"), Q(0.1, "kWh"), RoundUp, "0.3000 kWh"},
+		{Q(1.26, "kWh"), Q(0.1, "kWh"), RoundUp, "1.3000 kWh"},
+		{Q(0.78, "h"), Q(15, "min"), RoundUp, "1.0000 h"},
+		{Q(2.5, "m"), Q(1, "m"), RoundNearest, "3.0000 m"},
+		{Q(2.5, "m"), Q(1, "m"), RoundHalfEven, "2.0000 m"},
+		{Q(-2.5, "m"), Q(1, "m"), RoundTowardZero, "-2.0000 m"},
+		{Q(-2.5, "m"), Q(1, "m"), RoundDown, "-3.0000 m"},
+		{Q(100, "cm"), Q(1, "ft"), RoundUp, "121.9200 cm"},
+	}
+	for _, d := range data {
+		r, err := Quantize(d.q, d.step, d.mode)
+		if err != nil || r.String() != d.expected {
+			t.Error(d.q, d.step, d.mode, "expected:", d.expected, "actual:", r, err)
+		}
+	}
+	// a fraction of a step is not rounding noise, also for billions of steps
+	large := []struct {
+		value    float64
+		mode     RoundMode
+		expected float64
+	}{
+		{2000000000.3, RoundUp, 2000000001},
+		{2000000000.7, RoundDown, 2000000000},
+		{2000000000.7, RoundTowardZero, 2000000000},
+		{-2000000000.3, RoundDown, -2000000001},
+		{1e12 + 0.5, RoundUp, 1e12 + 1},
+		{2000000000, RoundUp, 2000000000},
+	}
+	for _, d := range large {
+		if r, err := Quantize(Q(d.value, "byte"), Q(1, "byte"), d.mode); err != nil || r.Value() != d.expected {
+			t.Error(d.value, d.mode, "expected:", d.expected, "actual:", r.Value(), err)
+		}
+	}
+	for _, step := range []Quantity{Q(0, "min"), Q(-1, "min"), Q(math.Inf(1), "min"), Q(1, "m")} {
+		if _, err := Quantize(Q(1, "h"), step, RoundUp); err == nil {
+			t.Error("expected an error for step", step)
+		}
+	}
+	if _, err := Quantize(Q(1, "h"), Q(1, "min"), RoundMode(-1)); err == nil {
+		t.Error("expected an error for an invalid mode")
+	}
+}
+
+func TestFreezeRegistry(t *testing.T) {
+	FreezeRegistry()
+	defer unfreezeRegistry()
+
+	if _, err := Define("zorp", 3, "m"); !errors.Is(err, ErrRegistryFrozen) {
+		t.Error("expected:", ErrRegistryFrozen, "actual:", err)
+	}
+	if err := DefineAll(map[string]Definition{"zorp": {3, "m"}}); !errors.Is(err, ErrRegistryFrozen) {
+		t.Error("expected:", ErrRegistryFrozen, "actual:", err)
+	}
+	if err := LoadDefinitions(strings.NewReader(`{"zorp": {"factor": 3, "base": "m"}}`)); !errors.Is(err, ErrRegistryFrozen) {
+		t.Error("expected:", ErrRegistryFrozen, "actual:", err)
+	}
+	for _, err := range []error{
+		DefineISOCurrencies(),
+		RegisterScale(OrdinalScale{"zorp scale", "m", 0, []float64{1}}),
+		UnregisterScale(Beaufort.Name),
+	} {
+		if !errors.Is(err, ErrRegistryFrozen) {
+			t.Error("expected:", ErrRegistryFrozen, "actual:", err)
+		}
+	}
+	func() {
+		defer func() {
+			if r := recover(); r != ErrRegistryFrozen {
+				t.Error("expected:", ErrRegistryFrozen, "actual:", r)
+			}
+		}()
+		RestoreRegistry(SnapshotRegistry())
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				q, err := Parse(fmt.Sprintf("%d km/h", i))
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if v := q.In("m/s").Value(); math.Abs(v-float64(i)/3.6) > 1e-12 {
+					t.Error("expected:", float64(i)/3.6, "actual:", v)
+					return
+				}
+				if u := UnitFor("zorp/h"); u != &UndefinedUnit {
+					t.Error("expected undefined unit, actual:", u.Symbol())
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	if UnitFor("km/h") != UnitFor("km/h") {
+		t.Error("expected cached unit for km/h")
+	}
+}
+
+func TestJSONSchema(t *testing.T) {
+	schema, err := JSONSchema("km/h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := json.Marshal(schema); err != nil {
+		t.Error(err)
+	}
+	oneOf := schema["oneOf"].([]any)
+	symbols := oneOf[0].(map[string]any)["properties"].(map[string]any)["unit"].(map[string]any)["enum"].([]string)
+	for _, expected := range []string{"km/h", "kph", "mph", "kn"} {
+		if i := sort.SearchStrings(symbols, expected); i == len(symbols) || symbols[i] != expected {
+			t.Error("expected unit:", expected, "actual:", symbols)
+		}
+	}
+	rx := regexp.MustCompile(oneOf[1].(map[string]any)["pattern"].(string))
+	for _, s := range symbols {
+		if q, err := Parse("12.5 " + s); err != nil || !rx.MatchString(q.String()) {
+			t.Error("expected match:", s, "actual:", q, err)
+		}
+	}
+	for _, s := range []string{"12 kg", "12", "km/h", "12 km/h/h"} {
+		if rx.MatchString(s) {
+			t.Error("unexpected match:", s)
+		}
+	}
+	if _, err := JSONSchema("zorp"); !errors.Is(err, ErrUnknownUnit) {
+		t.Error("expected:", ErrUnknownUnit, "actual:", err)
+	}
+
+	// prefixed units and the output of String, also in scientific notation, round-trip
+	schema, err = JSONSchema("m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oneOf = schema["oneOf"].([]any)
+	symbols = oneOf[0].(map[string]any)["properties"].(map[string]any)["unit"].(map[string]any)["enum"].([]string)
+	for _, expected := range []string{"cm", "mm", "um", "dam"} {
+		if i := sort.SearchStrings(symbols, expected); i == len(symbols) || symbols[i] != expected {
+			t.Error("expected unit:", expected, "actual:", symbols)
+		}
+	}
+	rx = regexp.MustCompile(oneOf[1].(map[string]any)["pattern"].(string))
+	for _, s := range symbols {
+		for _, v := range []float64{12.5, -1.2e-9, 3e12} {
+			str := Q(v, s).String()
+			var q Quantity
+			if err := json.Unmarshal([]byte(strconv.Quote(str)), &q); err != nil || !rx.MatchString(str) || q.Value() != Q(v, s).Value() {
+				t.Error("expected round trip:", str, "actual:", q, err, rx.MatchString(str))
+			}
+		}
+	}
+	for _, s := range []string{"1.5 cm", "2 mm", "1,013.25 m", ".5 km", "1.5e3 m", "1E-3 um"} {
+		if _, err := Parse(s); err != nil || !rx.MatchString(s) {
+			t.Error("expected match:", s, "actual:", err)
+		}
+	}
+	for _, s := range []string{",,. m", "1.5e3m", "1.5 m/s", "1.5 kkm"} {
+		if rx.MatchString(s) {
+			t.Error("unexpected match:", s)
+		}
+	}
+}
+
+func TestIsExact(t *testing.T) {
+	data := []struct {
+		from, to string
+		expected bool
+	}{
+		{"ft", "in", true},
+		{"mi/h", "km/h", true},
+		{"deg", "rad", true},
+		{"deg/s", "rpm", true},
+		{"psi", "psi", true},
+		{"psi", "Pa", false},
+		{"psi", "bar", false},
+		{"long ton", "kg", false},
+		{"hp", "W", false},
+		{"m", "kg", false},
+		{"m", "zorp", false},
+	}
+	for _, d := range data {
+		if actual := IsExact(d.from, d.to); actual != d.expected {
+			t.Error("expected:", d.expected, "actual:", actual, d.from, d.to)
+		}
+	}
+}
+
+func TestConvertUncertain(t *testing.T) {
+	u, err := ConvertUncertain(QU(10, 0.5, "ft"), "in")
+	if err != nil || u.String() != "120.0000 ± 6.0000 in" {
+		t.Error("expected: 120.0000 ± 6.0000 in, actual:", u, err)
+	}
+	// psi is 6894.75729 Pa, accurate to 5e-6 Pa
+	u, err = ConvertUncertain(QU(1000, 0, "psi"), "Pa")
+	if expected := 1000 * 5e-6; err != nil || math.Abs(u.Uncertainty().Value()-expected) > 1e-12 {
+		t.Error("expected:", expected, "actual:", u.Uncertainty(), err)
+	}
+	if _, err = ConvertUncertain(QU(1, 0, "psi"), "m"); !errors.Is(err, ErrIncompatibleUnits) {
+		t.Error("expected:", ErrIncompatibleUnits, "actual:", err)
+	}
+	sum := AddUncertain(QU(1000, 0, "psi"), QU(1, 0, "Pa"))
+	if expected := 1000 * 5e-6; math.Abs(sum.Uncertainty().Value()-expected) > 1e-12 {
+		t.Error("expected:", expected, "actual:", sum.Uncertainty())
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	data := []struct {
+		q        Quantity
+		expected string
+	}{
+		{Q(1, "psi"), "6894.75729|m-1.kg.s-2"},
+		{Q(1, "km"), "1000|m"},
+		{Q(1000, "m"), "1000|m"},
+		{Q(0.1, "m"), "0.1|m"},
+		{Q(math.Copysign(0, -1), "m"), "0|m"},
+		{Q(2, "dozen"), "24|count"},
+		{Q(0.5, ""), "0.5|"},
+	}
+	for _, d := range data {
+		if actual := d.q.CacheKey(); actual != d.expected {
+			t.Error("expected:", d.expected, "actual:", actual)
+		}
+	}
+	if Q(1, "m").CacheKey() == Q(1, "kg").CacheKey() || Q(0.1, "m").CacheKey() == Q(math.Nextafter(0.1, 1), "m").CacheKey() {
+		t.Error("expected different keys")
+	}
+}
+
+func TestHaversineDistance(t *testing.T) {
+	// London to Paris, with the latitudes in radians
+	d, err := HaversineDistance(Q(51.5074*math.Pi/180, "rad"), Q(-0.1278, "deg"), Q(48.8566*math.Pi/180, "rad"), Q(2.3522, "deg"))
+	if err != nil || d.Symbol() != "m" || math.Abs(d.In("km").Value()-343.56) > 0.01 {
+		t.Error("expected: 343.56 km, actual:", d.In("km"), err)
+	}
+	d, err = HaversineDistance(Q(0, "deg"), Q(0, "deg"), Q(0, "deg"), Q(180, "deg"))
+	if err != nil || math.Abs(d.Value()-math.Pi*6371008.8) > 1e-6 {
+		t.Error("expected: half the circumference, actual:", d, err)
+	}
+	if _, err = HaversineDistance(Q(51.5, "m"), Q(0, "deg"), Q(0, "deg"), Q(0, "deg")); !errors.Is(err, ErrIncompatibleUnits) {
+		t.Error("expected:", ErrIncompatibleUnits, "actual:", err)
+	}
+	if _, err = HaversineDistance(Q(0, "deg"), Q(0, "deg"), Q(0, "deg"), Q(3.5, "rad")); err == nil {
+		t.Error("expected error for longitude out of range")
+	}
+}
+
+func TestMassUnits(t *testing.T) {
+	data := []struct {
+		input, unit string
+		expected    float64
+	}{
+		{"7000 grain", "lb", 1},
+		{"1 ct", "mg", 200},
+		{"12 oz t", "g", 373.2417216},
+		{"1 slug", "kg", 14.593902937206364},
+		{"1 slug", "lbf.s2/ft", 1},
+		{"14 lb", "st", 1},
+	}
+	for _, d := range data {
+		q, err := Parse(d.input)
+		if err != nil {
+			t.Error(err)
+		} else if actual := q.In(d.unit).Value(); math.Abs(actual-d.expected) > 1e-12*d.expected {
+			t.Error("expected:", d.expected, d.unit, "actual:", actual, d.input)
+		}
+	}
+	if num, den, ok := UnitFor("slug").ExactFactor(); !ok || num != 8896443230521 || den != 609600000000 {
+		t.Error("expected: 8896443230521/609600000000, actual:", num, den, ok)
+	}
+	if q, err := ParseWords("2 troy ounces"); err != nil || q.Symbol() != "oz t" {
+		t.Error("expected: 2 oz t, actual:", q, err)
+	}
+	_, err := Parse("500 gr")
+	if !errors.Is(err, ErrSyntax) || !strings.Contains(err.Error(), "grain or g") {
+		t.Error("expected ambiguous unit error, actual:", err)
+	}
+	defer RestoreRegistry(SnapshotRegistry())
+	if _, err = Define("gr", 1, "g"); err != nil {
+		t.Fatal(err)
+	}
+	if q, err := Parse("500 gr"); err != nil || q.In("kg").Value() != 0.5 {
+		t.Error("expected: 0.5 kg, actual:", q, err)
+	}
+}
+
+func TestSetCurrencyRate(t *testing.T) {
+	defer RestoreRegistry(SnapshotRegistry())
+	DefineISOCurrencies()
+	tariff := UnitFor("NZD/kWh")
+	if _, err := RegistryRates.Rate("USD", "NZD"); ratedUnit("NZD") != nil || err == nil {
+		t.Error("expected: no built-in rate of NZD, actual:", Q(1, "NZD").In("USD"), err)
+	}
+	if err := SetCurrencyRate("NZD", 1.6); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetCurrencyRate("EUR", 0.9); err != nil {
+		t.Fatal(err)
+	}
+	if q := Q(16, "NZD").In("USD"); q.Value() != 10 {
+		t.Error("expected: 10 USD, actual:", q)
+	}
+	if q := Q(16, "NZD").In("EUR"); math.Abs(q.Value()-9) > 1e-12 {
+		t.Error("expected: 9 EUR, actual:", q)
+	}
+	if u := UnitFor("NZD/kWh"); u == tariff || math.Abs(u.factor-1/1.6/3.6e6) > 1e-20 {
+		t.Error("expected recalculated NZD/kWh, actual:", u.factor)
+	}
+	if r, err := RegistryRates.Rate("USD", "NZD"); err != nil || r != 1.6 {
+		t.Error("expected: 1.6, actual:", r, err)
+	}
+	if IsExact("NZD", "EUR") || IsExact("NZD", "USD") {
+		t.Error("expected: rates are not exact")
+	}
+	// currencies without a rate have a placeholder factor, not an exact one
+	if IsExact("JPY", "GBP") || IsExact("JPY", "USD") || !IsExact("$", "USD") || !IsExact("JPY", "JPY") {
+		t.Error("expected: currencies without a rate are not exact")
+	}
+	if u, err := ConvertUncertain(QU(100, 0, "JPY"), "GBP"); err != nil || !math.IsInf(u.uncertainty, 1) {
+		t.Error("expected: unknown uncertainty without a rate, actual:", u, err)
+	}
+	if _, _, ok := UnitFor("JPY").ExactFactor(); ok {
+		t.Error("expected: no exact factor of JPY without a rate")
+	}
+	if _, err := RegistryRates.Rate("JPY", "USD"); err == nil || !strings.Contains(err.Error(), "no rate for JPY") {
+		t.Error("expected: no rate error, actual:", err)
+	}
+	if u, err := ConvertUncertain(QU(16, 0, "NZD"), "USD"); err != nil || u.uncertainty == 0 {
+		t.Error("expected: uncertainty of the rate, actual:", u, err)
+	}
+	old := Q(1, "NZD")
+	SetCurrencyRate("NZD", 2)
+	if old.In("USD").Value() != 0.625 || Q(1, "NZD").In("USD").Value() != 0.5 {
+		t.Error("expected old quantities to keep their rate")
+	}
+
+	data := []struct {
+		code   string
+		perUSD float64
+	}{
+		{"USD", 1},
+		{"¤", 2},
+		{"kg", 2},
+		{"XXX", 2},
+		{"NZD", 0},
+		{"NZD", -1},
+		{"NZD", math.Inf(1)},
+		{"NZD", math.NaN()},
+	}
+	for _, d := range data {
+		if err := SetCurrencyRate(d.code, d.perUSD); err == nil {
+			t.Error("expected error for", d.code, d.perUSD)
+		}
+	}
+
+	// rates are refreshed while other goroutines convert, also with a frozen registry
+	FreezeRegistry()
+	defer unfreezeRegistry()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if v := Q(4, "NZD").In("USD").Value(); v != 2 && v != 2.5 {
+					t.Error("expected: 2 or 2.5 USD, actual:", v)
+				}
+				if q, err := Parse("4 NZD/kWh"); err != nil || q.In("USD/kWh").Value() > 2.5 {
+					t.Error("expected: at most 2.5 USD/kWh, actual:", q, err)
+				}
+			}
+		}()
+	}
+	for j := 0; j < 100; j++ {
+		if err := SetCurrencyRate("NZD", 1.6+0.4*float64(j%2)); err != nil {
+			t.Error(err)
+		}
+	}
+	wg.Wait()
+	if err := SetCurrencyRate("NZD", 1.6); err != nil || UnitFor("NZD/kWh").factor != 1/1.6/3.6e6 {
+		t.Error("expected: rate set after FreezeRegistry, actual:", UnitFor("NZD/kWh").factor, err)
+	}
+}
+
+func TestScaleOf(t *testing.T) {
+	data := []struct {
+		q        Quantity
+		scale    OrdinalScale
+		expected int
+	}{
+		{Q(0.2, "m/s"), Beaufort, 0},
+		{Q(25, "kn"), Beaufort, 6},
+		{Q(110, "kph"), Beaufort, 11},
+		{Q(200, "kph"), Beaufort, 12},
+		{Q(50, "kn"), SaffirSimpson, 0},
+		{Q(100, "mph"), SaffirSimpson, 2},
+		{Q(0.2, "W/m2"), UVIndex, 8},
+	}
+	for _, d := range data {
+		if level, err := ScaleOf(d.q, d.scale); err != nil || level != d.expected {
+			t.Error(d.scale.Name, d.q, "expected:", d.expected, "actual:", level, err)
+		}
+	}
+	if _, err := ScaleOf(Q(1, "m"), Beaufort); !errors.Is(err, ErrIncompatibleUnits) {
+		t.Error("expected: incompatible units error, actual:", err)
+	}
+	fujita := OrdinalScale{"enhanced fujita", "mph", 0, []float64{86, 111, 136, 166, 201}}
+	if err := RegisterScale(fujita); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { UnregisterScale(fujita.Name) })
+	if s, ok := LookupScale("enhanced fujita"); !ok || len(s.Bounds) != 5 {
+		t.Error("registered scale not found")
+	}
+	for _, s := range []OrdinalScale{fujita, {"x", "furlong", 0, nil}, {"y", "m", 0, []float64{2, 1}}} {
+		if err := RegisterScale(s); err == nil {
+			t.Error("invalid scale registered:", s)
+		}
+	}
+	if UnregisterScale(Beaufort.Name) == nil || UnregisterScale("x") == nil {
+		t.Error("expected: built-in and unknown scales not removed")
+	}
+}
+
+func TestConvertAll(t *testing.T) {
+	qs := []Quantity{Q(1, "km"), Q(1, "mi"), Q(3, "ft")}
+	if err := ConvertAll(qs, qs, "m"); err != nil {
+		t.Fatal(err)
+	}
+	if s := fmt.Sprint(qs); s != "[1000.0000 m 1609.3440 m 0.9144 m]" {
+		t.Error("unexpected conversion:", s)
+	}
+	if err := ConvertAll(qs, append(qs, Q(1, "kg")), "m"); err == nil {
+		t.Error("short destination accepted")
+	}
+	if err := ConvertAll(make([]Quantity, 2), []Quantity{Q(1, "m"), Q(1, "kg")}, "ft"); err == nil {
+		t.Error("incompatible unit accepted")
+	}
+	src := []Quantity{Q(1, "mph"), Q(2, "kn")}
+	allocs := testing.AllocsPerRun(100, func() {
+		ConvertAll(src, src, "m/s")
+		NormalizeAll(src)
+	})
+	if allocs != 0 {
+		t.Error("expected no allocations, actual:", allocs)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	_, err := Parse("5 chickens")
+	var unknown *UnknownUnitError
+	if !errors.As(err, &unknown) || unknown.Symbol != "chickens" || !errors.Is(err, ErrUnknownUnit) {
+		t.Error("expected UnknownUnitError, actual:", err)
+	}
+	_, err = FactorBetween("m", "kg")
+	var incompatible *IncompatibleUnitsError
+	if !errors.As(err, &incompatible) || incompatible.A != "m" || incompatible.B != "kg" {
+		t.Error("expected IncompatibleUnitsError, actual:", err)
+	}
+	if _, err = Parse("5.5.6 m"); !errors.Is(err, ErrSyntax) {
+		t.Error("expected ErrSyntax, actual:", err)
+	}
+	if _, err = Define("m", 1, "m"); !errors.Is(err, ErrDuplicateSymbol) {
+		t.Error("expected ErrDuplicateSymbol, actual:", err)
+	}
+	if err = ConvertAll(make([]Quantity, 1), []Quantity{Q(1, "s")}, "m"); !errors.Is(err, ErrIncompatibleUnits) {
+		t.Error("expected ErrIncompatibleUnits, actual:", err)
+	}
+	if _, err = AsFloat(Q(1, "m")); !errors.Is(err, ErrNotDimensionless) {
+		t.Error("expected ErrNotDimensionless, actual:", err)
+	}
+	if _, err = KtoC(Q(1, "m")); !errors.Is(err, ErrIncompatibleUnits) {
+		t.Error("expected ErrIncompatibleUnits, actual:", err)
+	}
+}
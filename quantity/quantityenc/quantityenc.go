@@ -0,0 +1,261 @@
+// Package quantityenc encodes values with quantities to JSON in fixed units, set with
+// struct tags, so API responses use the same units whatever the internal representation.
+//
+// A tag `unit:"kPa,prec=2"` on a field of type quantity.Quantity, or a slice, array, map or
+// pointer of them, converts each quantity to kPa and rounds the value to 2 decimals. The
+// option "string" writes "101.33 kPa" instead of {"value":101.33,"unit":"kPa"}. Without a
+// unit, e.g. `unit:",prec=1"`, quantities keep their own unit.
+package quantityenc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	us "github.com/imhotep-nb/units/quantity"
+)
+
+var (
+	quantityType  = reflect.TypeOf(us.Quantity{})
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// Marshal returns the JSON encoding of v like json.Marshal, but with the quantities in
+// fields with a unit tag converted and formatted as the tag says. The json tags of fields
+// are honored for names, "-" and omitempty. Quantities with an undefined unit are written
+// as null. An error is returned if a quantity cannot be converted to the unit of its tag.
+func Marshal(v interface{}) ([]byte, error) {
+	var b bytes.Buffer
+	if err := encode(&b, reflect.ValueOf(v), nil); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// options holds a parsed unit tag.
+type options struct {
+	unit     string
+	prec     int // -1 for the shortest representation
+	asString bool
+}
+
+func parseTag(tag string) (*options, error) {
+	parts := strings.Split(tag, ",")
+	o := &options{unit: strings.TrimSpace(parts[0]), prec: -1}
+	for _, p := range parts[1:] {
+		switch {
+		case p == "string":
+			o.asString = true
+		case strings.HasPrefix(p, "prec="):
+			n, err := strconv.Atoi(p[len("prec="):])
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid precision in unit tag %q", tag)
+			}
+			o.prec = n
+		default:
+			return nil, fmt.Errorf("invalid option %q in unit tag %q", p, tag)
+		}
+	}
+	return o, nil
+}
+
+func encode(b *bytes.Buffer, v reflect.Value, o *options) error {
+	if !v.IsValid() {
+		b.WriteString("null")
+		return nil
+	}
+	if v.Type() == quantityType {
+		return encodeQuantity(b, v.Interface().(us.Quantity), o)
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			b.WriteString("null")
+			return nil
+		}
+		if v.Kind() == reflect.Ptr && !isQuantity(v.Type()) && v.Type().Implements(marshalerType) {
+			return encodeJSON(b, v)
+		}
+		return encode(b, v.Elem(), o)
+	case reflect.Struct:
+		if v.Type().Implements(marshalerType) {
+			return encodeJSON(b, v)
+		}
+		b.WriteByte('{')
+		if _, err := encodeFields(b, v, true); err != nil {
+			return err
+		}
+		b.WriteByte('}')
+		return nil
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			b.WriteString("null")
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 || v.Type().Implements(marshalerType) && !isQuantity(v.Type().Elem()) {
+			return encodeJSON(b, v)
+		}
+		b.WriteByte('[')
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			if err := encode(b, v.Index(i), o); err != nil {
+				return fmt.Errorf("element %d: %w", i, err)
+			}
+		}
+		b.WriteByte(']')
+		return nil
+	case reflect.Map:
+		if v.IsNil() {
+			b.WriteString("null")
+			return nil
+		}
+		if v.Type().Key().Kind() != reflect.String || v.Type().Implements(marshalerType) {
+			return encodeJSON(b, v)
+		}
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		b.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			writeKey(b, k.String())
+			if err := encode(b, v.MapIndex(k), o); err != nil {
+				return fmt.Errorf("key %s: %w", k.String(), err)
+			}
+		}
+		b.WriteByte('}')
+		return nil
+	}
+	return encodeJSON(b, v)
+}
+
+// isQuantity reports whether t is a Quantity or a pointer to one, which are encoded as the
+// unit tag says even in a type that implements json.Marshaler, e.g. quantity.Quantities.
+func isQuantity(t reflect.Type) bool {
+	return t == quantityType || t.Kind() == reflect.Ptr && t.Elem() == quantityType
+}
+
+// encodeFields writes the fields of struct v, flattening embedded structs like
+// encoding/json. It returns false if no field was written.
+func encodeFields(b *bytes.Buffer, v reflect.Value, first bool) (bool, error) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue // unexported
+		}
+		name, omitEmpty := f.Name, false
+		if tag, ok := f.Tag.Lookup("json"); ok {
+			if tag == "-" {
+				continue
+			}
+			parts := strings.Split(tag, ",")
+			if parts[0] != "" {
+				name = parts[0]
+			}
+			for _, p := range parts[1:] {
+				omitEmpty = omitEmpty || p == "omitempty"
+			}
+		}
+		fv := v.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct && f.Type != quantityType && name == f.Name {
+			wrote, err := encodeFields(b, fv, first)
+			if err != nil {
+				return false, err
+			}
+			first = first && !wrote
+			continue
+		}
+		if f.PkgPath != "" || omitEmpty && isEmpty(fv) {
+			continue
+		}
+		var o *options
+		if tag, ok := f.Tag.Lookup("unit"); ok {
+			var err error
+			if o, err = parseTag(tag); err != nil {
+				return false, fmt.Errorf("field %s: %w", f.Name, err)
+			}
+		}
+		if !first {
+			b.WriteByte(',')
+		}
+		first = false
+		writeKey(b, name)
+		if err := encode(b, fv, o); err != nil {
+			return false, fmt.Errorf("field %s: %w", f.Name, err)
+		}
+	}
+	return !first, nil
+}
+
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+func writeKey(b *bytes.Buffer, key string) {
+	k, _ := json.Marshal(key)
+	b.Write(k)
+	b.WriteByte(':')
+}
+
+func encodeJSON(b *bytes.Buffer, v reflect.Value) error {
+	data, err := json.Marshal(v.Interface())
+	if err != nil {
+		return err
+	}
+	b.Write(data)
+	return nil
+}
+
+func encodeQuantity(b *bytes.Buffer, q us.Quantity, o *options) error {
+	if q.Invalid() {
+		b.WriteString("null")
+		return nil
+	}
+	if o == nil {
+		return encodeJSON(b, reflect.ValueOf(q))
+	}
+	if o.unit != "" {
+		c, ok := q.ConvertTo(o.unit)
+		if !ok {
+			return fmt.Errorf("cannot convert %s to %s", q, o.unit)
+		}
+		q = c
+	}
+	value, unit := q.Split()
+	if o.prec >= 0 {
+		p := math.Pow10(o.prec)
+		value = math.Round(value*p) / p
+	}
+	if o.asString {
+		data, _ := json.Marshal(strings.TrimSpace(strconv.FormatFloat(value, 'f', o.prec, 64) + " " + unit))
+		b.Write(data)
+		return nil
+	}
+	return encodeJSON(b, reflect.ValueOf(struct {
+		Value float64 `json:"value"`
+		Unit  string  `json:"unit"`
+	}{value, unit}))
+}
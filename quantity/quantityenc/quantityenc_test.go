@@ -0,0 +1,65 @@
+package quantityenc
+
+import (
+	"testing"
+	"time"
+
+	us "github.com/imhotep-nb/units/quantity"
+)
+
+type Base struct {
+	ID string `json:"id"`
+}
+
+type Reading struct {
+	Base
+	Pressure    us.Quantity            `json:"pressure" unit:"kPa,prec=2"`
+	Temperature us.Quantity            `json:"temp" unit:"degC,prec=1,string"`
+	Depths      []us.Quantity          `json:"depths" unit:"m,prec=1"`
+	Flows       map[string]us.Quantity `json:"flows" unit:"L/min,prec=0"`
+	Raw         us.Quantity            `json:"raw"`
+	Optional    *us.Quantity           `json:"optional,omitempty" unit:"kPa"`
+	Peaks       []*us.Quantity         `json:"peaks" unit:"kPa,prec=1"`
+	Missing     us.Quantity            `json:"missing"`
+	At          time.Time              `json:"at"`
+	Ignored     string                 `json:"-"`
+	note        string
+}
+
+func TestMarshal(t *testing.T) {
+	bar, psi := us.Q(1, "bar"), us.Q(14.7, "psi")
+	r := Reading{
+		Base:        Base{"p1"},
+		Pressure:    us.Q(14.7, "psi"),
+		Temperature: us.Q(21.456, "degC"),
+		Depths:      []us.Quantity{us.Q(10, "ft"), us.Q(3, "m")},
+		Flows:       map[string]us.Quantity{"in": us.Q(1, "m3/h"), "out": us.Q(2, "GPM")},
+		Raw:         us.Q(1.5, "km"),
+		Optional:    &bar,
+		Peaks:       []*us.Quantity{&psi, nil},
+		At:          time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Ignored:     "x",
+		note:        "y",
+	}
+	data, err := Marshal(r)
+	expected := `{"id":"p1","pressure":{"value":101.35,"unit":"kPa"},"temp":"21.5 degC",` +
+		`"depths":[{"value":3,"unit":"m"},{"value":3,"unit":"m"}],` +
+		`"flows":{"in":{"value":17,"unit":"L/min"},"out":{"value":8,"unit":"L/min"}},` +
+		`"raw":{"value":1.5,"unit":"km"},"optional":{"value":100,"unit":"kPa"},` +
+		`"peaks":[{"value":101.4,"unit":"kPa"},null],"missing":null,"at":"2020-01-02T03:04:05Z"}`
+	if err != nil || string(data) != expected {
+		t.Errorf("expected:\n%s\nactual:\n%s %v", expected, data, err)
+	}
+	bad := struct {
+		Q us.Quantity `unit:"kPa"`
+	}{us.Q(1, "m")}
+	if _, err := Marshal(bad); err == nil {
+		t.Error("conversion of m to kPa accepted")
+	}
+	badTag := struct {
+		Q us.Quantity `unit:"kPa,prec=x"`
+	}{us.Q(1, "Pa")}
+	if _, err := Marshal(badTag); err == nil {
+		t.Error("invalid tag accepted")
+	}
+}
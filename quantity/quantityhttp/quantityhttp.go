@@ -0,0 +1,92 @@
+// Package quantityhttp reads quantities from the query and form parameters of HTTP requests,
+// e.g. quantityhttp.Param(r, "speed", "m/s") for "?speed=30 km/h", with errors whose messages
+// can be returned to the client with status 400 Bad Request.
+package quantityhttp
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	us "github.com/imhotep-nb/units/quantity"
+)
+
+// ErrMissing is returned when a required parameter is not in the request.
+var ErrMissing = errors.New("missing parameter")
+
+// ParamError reports a parameter that is missing, cannot be parsed or does not have the
+// expected dimension. The message is meant for the client, e.g. `invalid parameter "speed":
+// got mass (kg), want speed (m·s⁻¹)`.
+type ParamError struct {
+	Name  string // name of the parameter
+	Value string // value of the parameter, "" if missing
+	Err   error  // ErrMissing, or the error of parsing or checking the value
+}
+
+func (e *ParamError) Error() string {
+	if errors.Is(e.Err, ErrMissing) {
+		return fmt.Sprintf("missing parameter %q", e.Name)
+	}
+	return fmt.Sprintf("invalid parameter %q: %v", e.Name, e.Err)
+}
+
+// Unwrap returns the underlying error, e.g. for errors.Is(err, quantity.ErrSyntax).
+func (e *ParamError) Unwrap() error {
+	return e.Err
+}
+
+// StatusCode returns http.StatusBadRequest.
+func (e *ParamError) StatusCode() int {
+	return http.StatusBadRequest
+}
+
+// Param returns the query or form parameter with the name as a quantity converted to unit,
+// see http.Request.FormValue. A number without a unit is taken to be in the unit, e.g.
+// "speed=30" is 30 m/s for the unit "m/s". A *ParamError is returned if the parameter is
+// missing, cannot be parsed or is not compatible with the unit.
+func Param(r *http.Request, name, unit string) (us.Quantity, error) {
+	s := strings.TrimSpace(r.FormValue(name))
+	if s == "" {
+		return us.Quantity{}, &ParamError{name, s, ErrMissing}
+	}
+	return parse(name, s, unit)
+}
+
+// ParamDefault is Param for an optional parameter: if it is missing or empty, def is
+// returned, converted to unit.
+func ParamDefault(r *http.Request, name, unit string, def us.Quantity) (us.Quantity, error) {
+	s := strings.TrimSpace(r.FormValue(name))
+	if s == "" {
+		return def.In(unit), nil
+	}
+	return parse(name, s, unit)
+}
+
+// WriteError replies to the request with the message of err and status 400 Bad Request if
+// it is a *ParamError, or 500 Internal Server Error otherwise.
+func WriteError(w http.ResponseWriter, err error) {
+	var pe *ParamError
+	if errors.As(err, &pe) {
+		http.Error(w, pe.Error(), pe.StatusCode())
+		return
+	}
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
+
+// parse parses the value s of the parameter with the name and converts it to unit.
+func parse(name, s, unit string) (us.Quantity, error) {
+	input := s
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		input += " " + unit
+	}
+	q, err := us.Parse(input)
+	if err == nil {
+		err = us.ExpectDimension(q, unit)
+	}
+	if err != nil {
+		return us.Quantity{}, &ParamError{name, s, err}
+	}
+	return q.In(unit), nil
+}
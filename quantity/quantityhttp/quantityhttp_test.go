@@ -0,0 +1,76 @@
+package quantityhttp
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	us "github.com/imhotep-nb/units/quantity"
+)
+
+func TestParam(t *testing.T) {
+	r := httptest.NewRequest("GET", "/?"+url.Values{
+		"speed":  {"36 km/h"},
+		"plain":  {"12"},
+		"mass":   {"2 kg"},
+		"broken": {"3 zorp"},
+		"nan":    {"NaN"},
+	}.Encode(), nil)
+	tests := []struct {
+		name     string
+		expected float64
+		err      error
+	}{
+		{"speed", 10, nil},
+		{"plain", 12, nil},
+		{"mass", 0, us.ErrIncompatibleUnits},
+		{"broken", 0, us.ErrUnknownUnit},
+		{"nan", 0, us.ErrSyntax},
+		{"missing", 0, ErrMissing},
+	}
+	for _, test := range tests {
+		q, err := Param(r, test.name, "m/s")
+		if test.err != nil {
+			var pe *ParamError
+			if !errors.Is(err, test.err) || !errors.As(err, &pe) || pe.Name != test.name || pe.Value != r.FormValue(test.name) {
+				t.Error("expected:", test.err, "actual:", err)
+			}
+			continue
+		}
+		if err != nil || q.Symbol() != "m/s" || math.Abs(q.Value()-test.expected) > 1e-12 {
+			t.Error("expected:", test.expected, "m/s actual:", q, err)
+		}
+	}
+	q, err := ParamDefault(r, "missing", "km/h", us.Q(5, "m/s"))
+	if err != nil || q.Symbol() != "km/h" || math.Abs(q.Value()-18) > 1e-12 {
+		t.Error("expected: 18 km/h actual:", q, err)
+	}
+}
+
+func TestParamForm(t *testing.T) {
+	r := httptest.NewRequest("POST", "/", strings.NewReader("pressure=14.7+psi"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	q, err := Param(r, "pressure", "kPa")
+	if err != nil || math.Abs(q.Value()-101.35) > 0.01 {
+		t.Error("expected: 101.35 kPa actual:", q, err)
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	r := httptest.NewRequest("GET", "/?speed=2+kg", nil)
+	_, err := Param(r, "speed", "m/s")
+	w := httptest.NewRecorder()
+	WriteError(w, err)
+	if w.Code != http.StatusBadRequest || !strings.HasPrefix(w.Body.String(), `invalid parameter "speed": `) {
+		t.Error("expected: 400 invalid parameter, actual:", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	WriteError(w, errors.New("database down"))
+	if w.Code != http.StatusInternalServerError || strings.Contains(w.Body.String(), "database") {
+		t.Error("expected: 500, actual:", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,84 @@
+// Package quantitytest provides generators of random quantities and unit strings for
+// property based tests and fuzzing of code that uses the quantity package.
+package quantitytest
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+
+	us "github.com/imhotep-nb/units/quantity"
+)
+
+// Value returns a random value with a random sign and a magnitude between 1e-6 and 1e6,
+// distributed evenly over the orders of magnitude.
+func Value(r *rand.Rand) float64 {
+	v := math.Pow(10, r.Float64()*12-6)
+	if r.Intn(2) == 0 {
+		return -v
+	}
+	return v
+}
+
+// Units returns the registered units that are compatible with the given unit symbol, e.g.
+// all units of length for "m". The result is empty if the symbol is not a unit.
+func Units(dimension string) []string {
+	return us.CompatibleUnits(dimension)
+}
+
+// Quantity returns a Quantity with a random value, see Value, in a random registered unit
+// compatible with the given unit symbol, e.g. "2.5 mi" for "m". It panics if there is no
+// such unit.
+func Quantity(r *rand.Rand, dimension string) us.Quantity {
+	symbols := Units(dimension)
+	if len(symbols) == 0 {
+		panic("quantitytest: no units compatible with " + dimension)
+	}
+	return us.Q(Value(r), symbols[r.Intn(len(symbols))])
+}
+
+// Pair returns two quantities with random values in random registered units of the same,
+// random dimension, e.g. "3.1 psi" and "0.02 bar".
+func Pair(r *rand.Rand) (us.Quantity, us.Quantity) {
+	symbols := us.Symbols()
+	dimension := symbols[r.Intn(len(symbols))]
+	return Quantity(r, dimension), Quantity(r, dimension)
+}
+
+// adversarial holds fragments that are hard to parse: separators, signs, digits, spaces,
+// prefixes, confusable and invalid characters.
+var adversarial = []string{
+	".", "/", "*", "^", "-", "--", "0", "1", "-1", "127", "128", "-129", "99999999999", " ",
+	"\t", "", "µ", "μ", "u", "da", "k", "Y", "y", "²", "·", "±", "(", ")", "\xff", "\u00a0",
+}
+
+// UnitString returns a random unit string for parser tests. About half of the strings are
+// valid compound units of registered symbols, such as "kg.m2/s2"; the others mix in
+// adversarial fragments such as repeated separators, huge exponents and invalid UTF-8.
+func UnitString(r *rand.Rand) string {
+	var symbols []string
+	for _, symbol := range us.Symbols() {
+		if !strings.ContainsAny(symbol, "/.0123456789") {
+			symbols = append(symbols, symbol)
+		}
+	}
+	valid := r.Intn(2) == 0
+	var b strings.Builder
+	for i, n := 0, 1+r.Intn(4); i < n; i++ {
+		if i > 0 {
+			if i == n-1 && r.Intn(2) == 0 {
+				b.WriteString("/")
+			} else {
+				b.WriteString(".")
+			}
+		}
+		b.WriteString(symbols[r.Intn(len(symbols))])
+		if r.Intn(3) == 0 {
+			b.WriteString([]string{"2", "3", "4"}[r.Intn(3)])
+		}
+		if !valid {
+			b.WriteString(adversarial[r.Intn(len(adversarial))])
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,47 @@
+package quantitytest
+
+import (
+	"math/rand"
+	"testing"
+
+	us "github.com/imhotep-nb/units/quantity"
+)
+
+func TestQuantity(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		q := Quantity(r, "Pa")
+		if !q.HasCompatibleUnit("Pa") {
+			t.Fatal("expected: pressure, actual:", q)
+		}
+	}
+	if len(Units("m")) < 5 || Units("furlong") != nil {
+		t.Error("expected: units of length, actual:", Units("m"), Units("furlong"))
+	}
+}
+
+func TestPair(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		a, b := Pair(r)
+		if !us.AreCompatible(a, b) {
+			t.Fatal("not compatible:", a, b)
+		}
+		if c, ok := a.ConvertTo(b.Symbol()); !ok || !us.Equal(c, a, us.Abs(us.MultFac(a, 1e-9))) {
+			t.Error("conversion of", a, "to", b.Symbol(), "failed:", c)
+		}
+	}
+}
+
+func TestUnitString(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	var parsed int
+	for i := 0; i < 1000; i++ {
+		if _, err := us.ParseSymbol(UnitString(r)); err == nil {
+			parsed++
+		}
+	}
+	if parsed < 400 || parsed > 900 {
+		t.Error("expected: about half of the unit strings valid, actual:", parsed, "of 1000")
+	}
+}
@@ -0,0 +1,58 @@
+package quantity
+
+import (
+	"errors"
+	"math"
+)
+
+// RoundMode sets how Quantize rounds to a multiple of the step.
+type RoundMode int
+
+// Round modes: RoundNearest rounds halves away from zero, RoundHalfEven to the even multiple,
+// RoundUp towards +∞, RoundDown towards -∞ and RoundTowardZero truncates.
+const (
+	RoundNearest RoundMode = iota
+	RoundHalfEven
+	RoundUp
+	RoundDown
+	RoundTowardZero
+)
+
+// quantizeULPs is the number of units in the last place of the number of steps by which it
+// may differ from a whole number as rounding noise, so that e.g. 0.3 kWh is 3 steps of
+// 0.1 kWh, not 2.9999999999999996. It is relative to the precision of float64, so a
+// fraction of a step is never noise, even for billions of steps.
+const quantizeULPs = 4
+// Quantize rounds q to a whole number of steps, in the unit of q, e.g. Quantize(Q(47, "min"),
+// Q(15, "min"), RoundUp) is 60 min, for billing in 15-minute increments or 0.1 kWh steps. An
+// error is returned if the units are not compatible or the step is not a positive, finite
+// quantity.
+func Quantize(q, step Quantity, mode RoundMode) (Quantity, error) {
+	undef := Quantity{0, &UndefinedUnit}
+	if err := compatible(q, step); err != nil {
+		return undef, err
+	}
+	if !(step.value > 0) || math.IsInf(step.value, 0) {
+		return undef, errors.New("step not positive: " + step.String())
+	}
+	n := q.Convert(step.Unit).value / step.value
+	m := math.Max(1, math.Abs(n))
+	if r := math.Round(n); math.Abs(n-r) <= quantizeULPs*(math.Nextafter(m, math.Inf(1))-m) {
+		n = r
+	}
+	switch mode {
+	case RoundNearest:
+		n = math.Round(n)
+	case RoundHalfEven:
+		n = math.RoundToEven(n)
+	case RoundUp:
+		n = math.Ceil(n)
+	case RoundDown:
+		n = math.Floor(n)
+	case RoundTowardZero:
+		n = math.Trunc(n)
+	default:
+		return undef, errors.New("invalid round mode")
+	}
+	return Quantity{n * step.value, step.Unit}.Convert(q.Unit), nil
+}
@@ -0,0 +1,45 @@
+package quantity
+
+import (
+	"errors"
+	"math/rand"
+)
+
+// RandomBetween returns a Quantity with a value drawn uniformly from the range min <= value
+// < max, in the unit of min. A nil r uses the default source of math/rand. An error is
+// returned if the units are not compatible or max is less than min.
+func RandomBetween(min, max Quantity, r *rand.Rand) (Quantity, error) {
+	if err := compatible(min, max); err != nil {
+		return Quantity{}, err
+	}
+	hi := max.Convert(min.Unit).value
+	if hi < min.value {
+		return Quantity{}, errors.New("max less than min: " + max.String() + " < " + min.String())
+	}
+	return Quantity{min.value + (hi-min.value)*float64Of(r), min.Unit}, nil
+}
+
+// RandomNormal returns a Quantity with a value drawn from the normal distribution with the
+// given mean and standard deviation, in the unit of mean. A nil r uses the default source
+// of math/rand. An error is returned if the units are not compatible or stddev is negative.
+func RandomNormal(mean, stddev Quantity, r *rand.Rand) (Quantity, error) {
+	if err := compatible(mean, stddev); err != nil {
+		return Quantity{}, err
+	}
+	sd := stddev.Convert(mean.Unit).value
+	if sd < 0 {
+		return Quantity{}, errors.New("negative standard deviation: " + stddev.String())
+	}
+	n := rand.NormFloat64()
+	if r != nil {
+		n = r.NormFloat64()
+	}
+	return Quantity{mean.value + sd*n, mean.Unit}, nil
+}
+
+func float64Of(r *rand.Rand) float64 {
+	if r == nil {
+		return rand.Float64()
+	}
+	return r.Float64()
+}
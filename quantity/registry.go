@@ -0,0 +1,269 @@
+package quantity
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Conflict describes a possible problem in the unit table, see CheckRegistry.
+type Conflict struct {
+	Symbols []string // the symbols involved
+	Reason  string
+}
+
+func (c Conflict) String() string {
+	return fmt.Sprintf("%s: %s", strings.Join(c.Symbols, ", "), c.Reason)
+}
+
+// confusables maps glyphs that are easily confused to one representative.
+var confusables = strings.NewReplacer(
+	"µ", "u", // micro sign
+	"μ", "u", // greek small letter mu
+	"Ω", "Ω", // ohm sign -> greek capital omega
+	"º", "°", // masculine ordinal indicator
+	"˚", "°", // ring above
+	"ℓ", "l", // script small l
+	"’", "'",
+	"″", "\"",
+	"′", "'",
+)
+
+// mojibake lists character sequences that show up when UTF-8 text is decoded as Latin-1.
+var mojibake = []string{"Â", "Ã", "â€", "Î©"}
+
+// describe returns the definition of the unit, e.g. "1000 m (length)".
+func (u Unit) describe() string {
+	if name := u.DimensionName(); name != "" {
+		return fmt.Sprintf("%g %s (%s)", u.factor, makeSymbol(u.exponents), name)
+	}
+	return fmt.Sprintf("%g %s", u.factor, makeSymbol(u.exponents))
+}
+
+// CheckRegistry reports symbols in the unit table that are easily confused with each
+// other: symbols that differ only in case (e.g. "m" and "M") or in similar looking glyphs
+// (e.g. micro sign and mu), and symbols that look like mojibake (e.g. "Â¤" for "¤").
+// Such symbols are not errors, but user input may pick the wrong one.
+func CheckRegistry() []Conflict {
+	groups := make(map[string][]string)
+	var conflicts []Conflict
+	for symbol := range units {
+		key := strings.ToLower(confusables.Replace(symbol))
+		groups[key] = append(groups[key], symbol)
+		for _, m := range mojibake {
+			if strings.Contains(symbol, m) {
+				conflicts = append(conflicts, Conflict{[]string{symbol}, "looks like mojibake: " + units[symbol].describe()})
+				break
+			}
+		}
+	}
+	for _, symbols := range groups {
+		if len(symbols) < 2 {
+			continue
+		}
+		sort.Strings(symbols)
+		var defs []string
+		for _, symbol := range symbols {
+			defs = append(defs, symbol+" = "+units[symbol].describe())
+		}
+		conflicts = append(conflicts, Conflict{symbols, "differ only in case or similar glyphs: " + strings.Join(defs, "; ")})
+	}
+	sort.Slice(conflicts, func(i, j int) bool {
+		return conflicts[i].Symbols[0] < conflicts[j].Symbols[0]
+	})
+	return conflicts
+}
+
+// RegistrySnapshot holds the contents of the unit table, see SnapshotRegistry.
+type RegistrySnapshot struct {
+	units         map[string]*Unit
+	exactDecimals map[string]string
+	scales        map[string]OrdinalScale
+	rates         *rateTable
+}
+
+// SnapshotRegistry returns a copy of the unit table, with the exact factors of the units,
+// the ordinal scales and the currency rates, so tests that define units, scales or rates
+// can restore them with RestoreRegistry, e.g. in a deferred call.
+func SnapshotRegistry() RegistrySnapshot {
+	s := RegistrySnapshot{
+		units:         make(map[string]*Unit, len(units)),
+		exactDecimals: make(map[string]string, len(exactDecimals)),
+		scales:        make(map[string]OrdinalScale, len(scales)),
+		rates:         loadRates(),
+	}
+	for symbol, u := range units {
+		s.units[symbol] = u
+	}
+	exactCacheMu.RLock()
+	for symbol, d := range exactDecimals {
+		s.exactDecimals[symbol] = d
+	}
+	exactCacheMu.RUnlock()
+	for name, scale := range scales {
+		s.scales[name] = scale
+	}
+	return s
+}
+
+// RestoreRegistry sets the unit table, the exact factors, the ordinal scales and the
+// currency rates to the contents of the snapshot: units, scales and rates defined after the
+// snapshot are removed. The caches of units calculated by UnitFor and of exact factors are
+// cleared, as these may depend on removed units. It panics after FreezeRegistry.
+func RestoreRegistry(s RegistrySnapshot) {
+	if registryFrozen() {
+		panic(ErrRegistryFrozen)
+	}
+	units = make(map[string]*Unit, len(s.units))
+	for symbol, u := range s.units {
+		units[symbol] = u
+	}
+	parsedUnits = make(map[string]*Unit)
+	exactCacheMu.Lock()
+	exactDecimals = make(map[string]string, len(s.exactDecimals))
+	for symbol, d := range s.exactDecimals {
+		exactDecimals[symbol] = d
+	}
+	exactCache = make(map[exactKey]*big.Rat)
+	exactCacheMu.Unlock()
+	scales = make(map[string]OrdinalScale, len(s.scales))
+	for name, scale := range s.scales {
+		scales[name] = scale
+	}
+	ratesMu.Lock()
+	rates.Store(s.rates)
+	ratesMu.Unlock()
+	ratedParsed.Range(func(symbol, _ interface{}) bool {
+		ratedParsed.Delete(symbol)
+		return true
+	})
+}
+
+// frozen is 1 after FreezeRegistry; frozenParsed then caches the units calculated by UnitFor
+// instead of parsedUnits.
+var (
+	frozen       int32
+	frozenParsed sync.Map
+)
+
+// FreezeRegistry makes the unit table read-only, for servers that define all units at
+// startup: afterwards Define, DefineAll, LoadDefinitions, DefineISOCurrencies, RegisterScale
+// and UnregisterScale return ErrRegistryFrozen, and UnitFor, Parse and Convert read the table
+// without locks and cache calculated units in a concurrent map, so they can be used from any
+// number of goroutines at full speed. The registry cannot be unfrozen.
+func FreezeRegistry() {
+	if !atomic.CompareAndSwapInt32(&frozen, 0, 1) {
+		return
+	}
+	for symbol, u := range parsedUnits {
+		frozenParsed.Store(symbol, u)
+	}
+}
+
+// registryFrozen reports whether FreezeRegistry has been called.
+func registryFrozen() bool {
+	return atomic.LoadInt32(&frozen) == 1
+}
+
+// frozenUnitFor is UnitFor for a frozen registry.
+func frozenUnitFor(symbol string) *Unit {
+	if u := ratedUnit(symbol); u != nil {
+		return u
+	}
+	if u := units[symbol]; u != nil {
+		return u
+	}
+	if u, found := frozenParsed.Load(symbol); found {
+		return u.(*Unit)
+	}
+	return ratedUnitFor(symbol, func(u *Unit) *Unit {
+		cached, _ := frozenParsed.LoadOrStore(u.symbol, u)
+		return cached.(*Unit)
+	})
+}
+
+// Anomaly describes a unit table entry that is probably wrong, see Audit.
+type Anomaly struct {
+	Symbols []string // the symbols involved
+	Reason  string
+}
+
+func (a Anomaly) String() string {
+	return fmt.Sprintf("%s: %s", strings.Join(a.Symbols, ", "), a.Reason)
+}
+
+// dimensionlessSymbols are symbols of units that must be dimensionless.
+var dimensionlessSymbols = []string{"", "%", "‰", "bp", "ppm", "ppb", "ppt"}
+
+// Audit checks the definitions in the unit table and reports:
+//   - units with a factor that differs from the one derived from their symbol, e.g. "cu ft"
+//     with 35.31 instead of 0.0283 m3, which is inverted;
+//   - dimensionless units such as "%" with nonzero exponents;
+//   - units with the same definition under different symbols. These are often intended
+//     aliases, e.g. "h" and "hr", but may be a copied line.
+//
+// See VerifyFactors for a check against reference values.
+func Audit() []Anomaly {
+	var anomalies []Anomaly
+	groups := make(map[string][]string)
+	for symbol, u := range units {
+		if expected, ok := derivedFactor(symbol); ok && math.Abs(u.factor-expected) > 1e-9*expected {
+			reason := fmt.Sprintf("factor %g differs from %g derived from the symbol", u.factor, expected)
+			if math.Abs(u.factor*expected-1) < 1e-6 {
+				reason = fmt.Sprintf("factor %g looks inverted, derived from the symbol: %g", u.factor, expected)
+			}
+			anomalies = append(anomalies, Anomaly{[]string{symbol}, reason})
+		}
+		key := fmt.Sprintf("%.12g %v", u.factor, u.exponents)
+		groups[key] = append(groups[key], symbol)
+	}
+	for _, symbol := range dimensionlessSymbols {
+		if u, found := units[symbol]; found && !isDimensionless(u.exponents) {
+			anomalies = append(anomalies, Anomaly{[]string{symbol}, "dimensionless unit with exponents: " + u.describe()})
+		}
+	}
+	for _, symbols := range groups {
+		if len(symbols) > 1 {
+			sort.Strings(symbols)
+			anomalies = append(anomalies, Anomaly{symbols, "same definition: " + units[symbols[0]].describe()})
+		}
+	}
+	sort.Slice(anomalies, func(i, j int) bool {
+		return anomalies[i].Symbols[0] < anomalies[j].Symbols[0]
+	})
+	return anomalies
+}
+
+// derivedFactor returns the factor of a registered symbol calculated from its parts, e.g.
+// from "ft" for "cu ft" or from "lb" and "in" for "lb/sq in", and false if the symbol is
+// not made of other units.
+func derivedFactor(symbol string) (float64, bool) {
+	s := symbol
+	for word, exponent := range map[string]string{"sq ": "2", "cu ": "3"} {
+		if rest := strings.TrimPrefix(s, word); rest != s && !strings.ContainsAny(rest, "./ ") {
+			s = rest + exponent
+		}
+	}
+	if s == symbol && !strings.ContainsAny(s, "./") {
+		return 0, false
+	}
+	// parse a spelling that is not registered, e.g. "(lb)/sq in", so the symbol is not found as
+	// itself
+	parts := strings.Split(s, "/")
+	for i, part := range parts {
+		if !strings.HasPrefix(part, "(") {
+			parts[i] = "(" + part + ")"
+			break
+		}
+	}
+	u := units[symbol]
+	q, err := ParseSymbol(strings.Join(parts, "/"))
+	if err != nil || !haveSameExponents(q.exponents, u.exponents) {
+		return 0, false
+	}
+	return q.factor, true
+}
@@ -0,0 +1,102 @@
+package quantity
+
+import (
+	"errors"
+	"fmt"
+)
+
+// OrdinalScale maps ranges of a quantity to the levels of a scale, e.g. wind speeds to the
+// Beaufort wind force. Level First applies below Bounds[0], level First+i+1 from Bounds[i]
+// up to Bounds[i+1].
+type OrdinalScale struct {
+	Name   string
+	Unit   string    // unit of Bounds
+	First  int       // lowest level
+	Bounds []float64 // ascending lower bounds of the levels above First
+}
+
+// Built-in ordinal scales, see ScaleOf.
+var (
+	// Beaufort is the Beaufort wind force, 0 (calm) to 12 (hurricane force), from the wind
+	// speed at 10 m.
+	Beaufort = OrdinalScale{"beaufort", "m/s", 0,
+		[]float64{0.5, 1.6, 3.4, 5.5, 8.0, 10.8, 13.9, 17.2, 20.8, 24.5, 28.5, 32.7}}
+	// SaffirSimpson is the hurricane category, 1 to 5, from the sustained wind speed; 0 is
+	// below hurricane strength.
+	SaffirSimpson = OrdinalScale{"saffir-simpson", "kn", 0, []float64{64, 83, 96, 113, 137}}
+	// UVIndex is the UV index, 0 to 11 and above, from the erythemally weighted irradiance:
+	// 1 per 25 mW/m2.
+	UVIndex = OrdinalScale{"uv index", "W/m2", 0,
+		[]float64{0.025, 0.05, 0.075, 0.1, 0.125, 0.15, 0.175, 0.2, 0.225, 0.25, 0.275}}
+)
+
+var scales = map[string]OrdinalScale{
+	Beaufort.Name:      Beaufort,
+	SaffirSimpson.Name: SaffirSimpson,
+	UVIndex.Name:       UVIndex,
+}
+
+// ScaleOf returns the level of the quantity on the scale, e.g. 6 on the Beaufort scale for
+// 25 knots. An error is returned if the quantity is not compatible with the unit of the
+// scale.
+func ScaleOf(q Quantity, s OrdinalScale) (int, error) {
+	if err := ExpectDimension(q, s.Unit); err != nil {
+		return 0, err
+	}
+	v, _ := q.ConvertTo(s.Unit)
+	level := s.First
+	for _, b := range s.Bounds {
+		if v.value < b {
+			break
+		}
+		level++
+	}
+	return level, nil
+}
+
+// RegisterScale adds a custom scale for LookupScale. The name must be unique, the unit
+// must exist and the bounds must be ascending. After FreezeRegistry it returns
+// ErrRegistryFrozen.
+func RegisterScale(s OrdinalScale) error {
+	if registryFrozen() {
+		return fmt.Errorf("%w: cannot register scale %s", ErrRegistryFrozen, s.Name)
+	}
+	if _, found := scales[s.Name]; found {
+		return errors.New("duplicate scale: " + s.Name)
+	}
+	if UnitFor(s.Unit) == &UndefinedUnit {
+		return &UnknownUnitError{s.Unit}
+	}
+	for i := 1; i < len(s.Bounds); i++ {
+		if s.Bounds[i] <= s.Bounds[i-1] {
+			return fmt.Errorf("bounds of scale %s not ascending: %v", s.Name, s.Bounds)
+		}
+	}
+	scales[s.Name] = s
+	return nil
+}
+
+// UnregisterScale removes a custom scale added with RegisterScale, e.g. in a test cleanup.
+// It returns an error if no scale with the name is registered or if it is a built-in scale,
+// and ErrRegistryFrozen after FreezeRegistry.
+func UnregisterScale(name string) error {
+	if registryFrozen() {
+		return fmt.Errorf("%w: cannot unregister scale %s", ErrRegistryFrozen, name)
+	}
+	s, found := scales[name]
+	if !found {
+		return errors.New("unknown scale: " + name)
+	}
+	if s.Name == Beaufort.Name || s.Name == SaffirSimpson.Name || s.Name == UVIndex.Name {
+		return errors.New("built-in scale: " + name)
+	}
+	delete(scales, name)
+	return nil
+}
+
+// LookupScale returns the built-in or registered scale with the given name, e.g.
+// "beaufort". The result is false if there is no such scale.
+func LookupScale(name string) (OrdinalScale, bool) {
+	s, found := scales[name]
+	return s, found
+}
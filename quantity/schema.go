@@ -0,0 +1,66 @@
+package quantity
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// numberPattern is the number of Parse and the white space after it as a regular expression,
+// without the digit groups and decimal points that Parse would accept in any place, e.g.
+// ",,.". An exponent must be followed by white space, see Parse.
+const numberPattern = `-?(?:[0-9][0-9,]*(?:\.[0-9]*)?|\.[0-9]+)(?:[eE][-+]?[0-9]+\s+|\s*)`
+
+// JSONSchema returns a JSON Schema for a Quantity with the dimension of the unit, for API
+// specs such as OpenAPI: one of the object written by MarshalJSON, e.g.
+// {"value":1.5,"unit":"km"}, with an enum of the units of the dimension, and a "value unit"
+// string accepted by UnmarshalJSON, e.g. "1.5 km" or "1.200000e-09 m" as written by String,
+// with a pattern of the same units. The units are the registered units of the dimension and
+// their prefixed forms, e.g. "cm" for "m", and the given unit; other compound symbols that
+// Parse accepts, e.g. "m.s-1" for "km/h", are not included. As the units are taken from the
+// unit table, units defined later are not included either. Encode the result with
+// json.Marshal. An UnknownUnitError is returned if the unit is unknown.
+func JSONSchema(symbol string) (map[string]any, error) {
+	u := UnitFor(symbol)
+	if u == &UndefinedUnit {
+		return nil, &UnknownUnitError{symbol}
+	}
+	var symbols []string
+	for _, s := range CompatibleUnits(symbol) {
+		symbols = append(symbols, s)
+		if _, _, ok := prefix("k" + s); !ok || s == "kg" { // prefixes go on "g", see prefix
+			continue
+		}
+		for _, p := range append(strings.Split(prefixSymbols, ""), "da") {
+			if units[p+s] == nil {
+				symbols = append(symbols, p+s)
+			}
+		}
+	}
+	if _, found := units[u.symbol]; !found {
+		symbols = append(symbols, u.symbol)
+	}
+	sort.Strings(symbols)
+	quoted := make([]string, len(symbols))
+	for i, s := range symbols {
+		quoted[i] = regexp.QuoteMeta(s)
+	}
+	return map[string]any{
+		"description": describeDimension(u.exponents),
+		"oneOf": []any{
+			map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"value": map[string]any{"type": "number"},
+					"unit":  map[string]any{"type": "string", "enum": symbols},
+				},
+				"required":             []string{"value", "unit"},
+				"additionalProperties": false,
+			},
+			map[string]any{
+				"type":    "string",
+				"pattern": `^\s*` + numberPattern + `(` + strings.Join(quoted, "|") + `)\s*$`,
+			},
+		},
+	}, nil
+}
@@ -2,6 +2,8 @@ package quantity
 
 import (
 	"errors"
+	"fmt"
+	"math"
 )
 
 // -- temperature ------------------------------
@@ -10,16 +12,16 @@ const abszero = 273.15
 
 // KtoC converts Kelvin to Celsius
 func KtoC(q Quantity) (float64, error) {
-	if !q.HasCompatibleUnit("K") {
-		return 0, errors.New("not a temperature:" + q.String())
+	if err := ExpectDimension(q, "K"); err != nil {
+		return 0, err
 	}
 	return q.value - abszero, nil
 }
 
 // KtoF converts Kelvin to Fahrenheit
 func KtoF(q Quantity) (float64, error) {
-	if !q.HasCompatibleUnit("K") {
-		return 0, errors.New("not a temperature:" + q.String())
+	if err := ExpectDimension(q, "K"); err != nil {
+		return 0, err
 	}
 	return (q.value-abszero)*1.8 + 32, nil
 }
@@ -43,3 +45,169 @@ func CtoK(c float64) Quantity {
 func FtoK(f float64) Quantity {
 	return Q((f-32)/1.8+abszero, "K")
 }
+
+// -- weather ----------------------------------
+
+// The temperatures of the weather functions are absolute temperatures in a unit compatible
+// with K, see CtoK and FtoK; the results are in K. The relative temperature units degC, degF,
+// °C and °F are temperature differences and are rejected. Relative humidity is a percentage.
+
+// relativeTemperatures are the temperature units that have no offset from absolute zero.
+var relativeTemperatures = map[string]bool{"degC": true, "degF": true, "°C": true, "°F": true}
+
+func checkHumidity(rh float64) error {
+	if !(rh > 0 && rh <= 100) {
+		return fmt.Errorf("relative humidity not in range 0..100: %g", rh)
+	}
+	return nil
+}
+
+func celsius(t Quantity) (float64, error) {
+	if err := ExpectDimension(t, "K"); err != nil {
+		return 0, err
+	}
+	if relativeTemperatures[t.symbol] {
+		return 0, fmt.Errorf("%s is a temperature difference, see CtoK and FtoK", t)
+	}
+	return t.value*t.factor - abszero, nil
+}
+
+// WindChill returns the felt air temperature for the air temperature and the wind speed
+// at 10 m, by the formula of Environment Canada and the US National Weather Service. It
+// is the air temperature itself above 10 °C or for wind speeds up to 4.8 km/h, where the
+// formula does not apply.
+func WindChill(temp, wind Quantity) (Quantity, error) {
+	c, err := celsius(temp)
+	if err != nil {
+		return Quantity{}, err
+	}
+	if err := ExpectDimension(wind, "m/s"); err != nil {
+		return Quantity{}, err
+	}
+	v := wind.value * wind.factor * 3.6 // km/h
+	if c > 10 || v <= 4.8 {
+		return CtoK(c), nil
+	}
+	p := math.Pow(v, 0.16)
+	return CtoK(13.12 + 0.6215*c - 11.37*p + 0.3965*c*p), nil
+}
+
+// HeatIndex returns the felt air temperature for the air temperature and the relative
+// humidity, by the regression of the US National Weather Service.
+func HeatIndex(temp Quantity, rh float64) (Quantity, error) {
+	c, err := celsius(temp)
+	if err != nil {
+		return Quantity{}, err
+	}
+	if err := checkHumidity(rh); err != nil {
+		return Quantity{}, err
+	}
+	t := CtoF(c)
+	hi := 0.5 * (t + 61 + (t-68)*1.2 + rh*0.094)
+	if (hi+t)/2 >= 80 {
+		hi = -42.379 + 2.04901523*t + 10.14333127*rh - 0.22475541*t*rh - 6.83783e-3*t*t -
+			5.481717e-2*rh*rh + 1.22874e-3*t*t*rh + 8.5282e-4*t*rh*rh - 1.99e-6*t*t*rh*rh
+		switch {
+		case rh < 13 && t >= 80 && t <= 112:
+			hi -= (13 - rh) / 4 * math.Sqrt((17-math.Abs(t-95))/17)
+		case rh > 85 && t >= 80 && t <= 87:
+			hi += (rh - 85) / 10 * (87 - t) / 5
+		}
+	}
+	return FtoK(hi), nil
+}
+
+// DewPoint returns the temperature to which air of the given temperature and relative
+// humidity must be cooled to become saturated, by the Magnus formula.
+func DewPoint(temp Quantity, rh float64) (Quantity, error) {
+	c, err := celsius(temp)
+	if err != nil {
+		return Quantity{}, err
+	}
+	if err := checkHumidity(rh); err != nil {
+		return Quantity{}, err
+	}
+	const a, b = 17.62, 243.12
+	g := math.Log(rh/100) + a*c/(b+c)
+	return CtoK(b * g / (a - g)), nil
+}
+
+// -- gradients --------------------------------
+
+// Gradient returns the change q over the distance over, e.g. 11 hPa over 100 km. The result
+// is in the unit q.symbol/over.symbol, e.g. "hPa/km", or in SI units if that cannot be
+// calculated. An error is returned if over is not a length or is zero.
+func Gradient(q, over Quantity) (Quantity, error) {
+	if err := ExpectDimension(over, "m"); err != nil {
+		return Quantity{}, err
+	}
+	if over.value == 0 {
+		return Quantity{}, errors.New("gradient over zero distance")
+	}
+	if !q.defined() {
+		return Quantity{}, &UnknownUnitError{q.unitSymbol()}
+	}
+	g := Div(q, over)
+	if c, ok := g.ConvertTo(q.symbol + "/" + over.symbol); ok {
+		return c, nil
+	}
+	return g, nil
+}
+
+// PascalPerMeter returns a pressure gradient in Pa/m.
+func PascalPerMeter(v float64) Quantity {
+	return Q(v, "Pa/m")
+}
+
+// HectopascalPerKm returns a pressure gradient in hPa/km, as used in meteorology.
+func HectopascalPerKm(v float64) Quantity {
+	return Q(v, "hPa/km")
+}
+
+// KelvinPerKm returns a temperature gradient in K/km, e.g. a lapse rate.
+func KelvinPerKm(v float64) Quantity {
+	return Q(v, "K/km")
+}
+
+// VoltPerMeter returns an electric field strength in V/m.
+func VoltPerMeter(v float64) Quantity {
+	return Q(v, "V/m")
+}
+
+// -- thermal properties -----------------------
+
+// SpecificHeat returns a specific heat capacity in J/(kg·K), e.g. 4186 for water.
+func SpecificHeat(v float64) Quantity {
+	return Q(v, "J/(kg.K)")
+}
+
+// ThermalConductivity returns a thermal conductivity in W/(m·K), e.g. 0.04 for mineral wool.
+func ThermalConductivity(v float64) Quantity {
+	return Q(v, "W/(m.K)")
+}
+
+// HeatTransferCoefficient returns a heat transfer coefficient, or U-value, in W/(m²·K).
+func HeatTransferCoefficient(v float64) Quantity {
+	return Q(v, "W/(m2.K)")
+}
+
+// -- aerodynamics -----------------------------
+
+// StandardSoundSpeed is the speed of sound in m/s in the International Standard Atmosphere
+// at sea level, 15 °C, e.g. for Mach(v, Q(StandardSoundSpeed, "m/s")).
+const StandardSoundSpeed = 340.294
+
+// Mach returns the Mach number, the ratio of the speed to the speed of sound. An error is
+// returned if either is not a speed or the speed of sound is not positive.
+func Mach(speed, soundSpeed Quantity) (float64, error) {
+	if err := ExpectDimension(speed, "m/s"); err != nil {
+		return 0, err
+	}
+	if err := ExpectDimension(soundSpeed, "m/s"); err != nil {
+		return 0, err
+	}
+	if soundSpeed.value <= 0 {
+		return 0, errors.New("speed of sound must be positive")
+	}
+	return AsFloat(Div(speed, soundSpeed))
+}
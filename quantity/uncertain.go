@@ -0,0 +1,85 @@
+package quantity
+
+import (
+	"fmt"
+	"math"
+)
+
+// Uncertain is a Quantity with a standard uncertainty, expressed in the unit of the Quantity.
+type Uncertain struct {
+	Quantity
+	uncertainty float64
+}
+
+// QU returns an Uncertain quantity with the given value, uncertainty and unit.
+func QU(value, uncertainty float64, symbol string) Uncertain {
+	return Uncertain{Q(value, symbol), math.Abs(uncertainty)}
+}
+
+// ParseUncertain parses text input like Parse, but keeps the uncertainty. Both
+// "9.81 ± 0.02 m/s2" (or "+/-") and the concise "9.81(2) m/s2" notation are accepted;
+// the uncertainty is 0 if there is none. In EBNF, with quantity and number as given for
+// Parse:
+//
+//	uncertainty = ( "±" | "+/-" ) number | "(" digit { digit } ")" .
+func ParseUncertain(s string) (Uncertain, error) {
+	q, u, err := parse(s, NewParseOptions())
+	return Uncertain{q, u}, err
+}
+
+// Uncertainty returns the uncertainty as a Quantity in the unit of the value.
+func (u Uncertain) Uncertainty() Quantity {
+	return Quantity{u.uncertainty, u.Unit}
+}
+
+// RelativeUncertainty returns the uncertainty divided by the absolute value.
+func (u Uncertain) RelativeUncertainty() float64 {
+	return u.uncertainty / math.Abs(u.value)
+}
+
+// String returns the value and uncertainty, e.g. "9.8100 ± 0.0200 m/s2".
+func (u Uncertain) String() string {
+	return fmt.Sprintf("%.4f ± %.4f %s", u.value, u.uncertainty, u.symbol)
+}
+
+// AddUncertain adds two uncertain quantities with compatible units. The uncertainties
+// are assumed to be independent and are added in quadrature. The result has SI units; the
+// uncertainty includes that of conversion factors that are not exact, see ConvertUncertain.
+func AddUncertain(a, b Uncertain) Uncertain {
+	return Uncertain{Add(a.Quantity, b.Quantity), math.Hypot(a.siUncertainty(), b.siUncertainty())}
+}
+
+// MultUncertain multiplies two uncertain quantities. The relative uncertainties are
+// assumed to be independent and are added in quadrature. The result has SI units; the
+// uncertainty includes that of conversion factors that are not exact, see ConvertUncertain.
+func MultUncertain(a, b Uncertain) Uncertain {
+	q := Mult(a.Quantity, b.Quantity)
+	// the absolute uncertainties, so that a zero value does not divide by zero
+	ua := a.siUncertainty() * math.Abs(b.value*b.factor)
+	ub := b.siUncertainty() * math.Abs(a.value*a.factor)
+	return Uncertain{q, math.Hypot(ua, ub)}
+}
+
+// ConvertUncertain converts u to the unit with the symbol. If the conversion is not exact,
+// see IsExact, the uncertainty of the factors, taken as half a unit in the last decimal of
+// the registered factors, is added in quadrature, so that e.g. a pressure in psi converted
+// to Pa does not claim more precision than the factor 6894.75729 has. An error is returned
+// if the unit is unknown or not compatible.
+func ConvertUncertain(u Uncertain, symbol string) (Uncertain, error) {
+	if err := ExpectDimension(u.Quantity, symbol); err != nil {
+		return Uncertain{Quantity{0, &UndefinedUnit}, 0}, err
+	}
+	to := UnitFor(symbol)
+	q := u.Convert(to)
+	uncertainty := u.uncertainty * math.Abs(conversionRatio(u.Unit, to))
+	// the ratio of equal factors is exact, unless it is the placeholder of a missing rate
+	if r := factorUncertainty(u.symbol) + factorUncertainty(to.symbol); u.factor != to.factor || math.IsInf(r, 0) {
+		uncertainty = math.Hypot(uncertainty, r*math.Abs(q.value))
+	}
+	return Uncertain{q, uncertainty}, nil
+}
+
+// siUncertainty returns the uncertainty of u in SI units, including that of the factor.
+func (u Uncertain) siUncertainty() float64 {
+	return math.Hypot(u.uncertainty*u.factor, factorUncertainty(u.symbol)*math.Abs(u.value*u.factor))
+}
@@ -3,12 +3,16 @@
 package quantity
 
 import (
-	"errors"
 	"fmt"
+	"math"
+	"math/big"
 	"os"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"unicode/utf8"
 )
 
 const (
@@ -21,99 +25,187 @@ const (
 	radian
 	steradian
 	currency
-	byte
+	octet // byte, named octet to keep the builtin byte type usable
 	second
-	// when inserting a new base unit, then also update baseSymbols below
+	count // number of things, e.g. requests or cells
+	// when inserting a new base unit, then also update baseSymbols below and increment
+	// BaseUnitsVersion; add new base units at the end, the index of a base unit is part of
+	// encoded quantities and of the vectors of DimensionVector
 )
 
 const (
-	nBaseUnits = 11
+	nBaseUnits = 12
 )
 
+// Exponent vectors, see BaseUnits. NumBaseUnits is the length of the vectors. BaseUnitsVersion
+// is incremented when a base unit is added; the index of a base unit never changes, so a
+// vector of the current version is that of an older version with zeros appended.
 const (
-	yocto float64 = 1e-24
-	zepto         = 1e-21
-	atto          = 1e-18
-	femto         = 1e-15
-	pico          = 1e-12
-	nano          = 1e-9
-	micro         = 1e-6
-	milli         = 1e-3
-	centi         = 0.01
-	deci          = 0.1
-	deca          = 10
-	hecto         = 100
-	kilo          = 1e3
-	mega          = 1e6
-	giga          = 1e9
-	tera          = 1e12
-	peta          = 1e15
-	exa           = 1e18
-	zetta         = 1e21
-	yotta         = 1e24
+	NumBaseUnits     = nBaseUnits
+	BaseUnitsVersion = 1
 )
 
+// BaseUnits returns the symbols of the base units in the order of the exponents of
+// DimensionVector, UnitVector and the JSON encoding of RegistrySnapshot: "m", "kg", "K",
+// "A", "cd", "mol", "rad", "sr", "¤", "byte", "s" and "count". See BaseUnitsVersion.
+func BaseUnits() []string {
+	return append([]string(nil), baseSymbols[:]...)
+}
+
+// SI prefix factors, e.g. Q(25*Centi, "m") equals Q(25, "cm").
+const (
+	Yocto float64 = 1e-24
+	Zepto         = 1e-21
+	Atto          = 1e-18
+	Femto         = 1e-15
+	Pico          = 1e-12
+	Nano          = 1e-9
+	Micro         = 1e-6
+	Milli         = 1e-3
+	Centi         = 0.01
+	Deci          = 0.1
+	Deca          = 10
+	Hecto         = 100
+	Kilo          = 1e3
+	Mega          = 1e6
+	Giga          = 1e9
+	Tera          = 1e12
+	Peta          = 1e15
+	Exa           = 1e18
+	Zetta         = 1e21
+	Yotta         = 1e24
+)
+
+// Square returns the factor of a prefix for squared units, e.g. Square(Centi) for cm2.
+func Square(prefix float64) float64 {
+	return prefix * prefix
+}
+
+// Cubic returns the factor of a prefix for cubed units, e.g. Cubic(Deci) for dm3.
+func Cubic(prefix float64) float64 {
+	return prefix * prefix * prefix
+}
+
+// Pow returns the factor of a prefix for units raised to the power n, e.g. Pow(Milli, -2)
+// for mm-2.
+func Pow(prefix float64, n int) float64 {
+	return math.Pow(prefix, float64(n))
+}
+
 var (
-	// DefaultFormat is the default formatstring for Quantities
+	// DefaultFormat is the default formatstring for Quantities, see also Formatter
 	DefaultFormat = "%.4f %s"
+	// ScientificFormat is used by String instead of the default format for values outside
+	// ScientificMin to ScientificMax. Set ScientificMin to 0 and ScientificMax to +Inf to
+	// always use the default format.
+	ScientificFormat = "%e %s"
+	// ScientificMin is the smallest absolute value String formats with the default format
+	ScientificMin = 1e-4
+	// ScientificMax is the absolute value from which String uses ScientificFormat
+	ScientificMax = 1e15
 	// UndefinedUnit represents a unit that is unknown to the system
-	UndefinedUnit = Unit{"?", 0, emptyExponents()}
+	UndefinedUnit = Unit{"?", 0, [nBaseUnits]int8{}}
+	// DimensionlessSymbol is shown for dimensionless quantities, e.g. the ratio of two lengths
+	DimensionlessSymbol = ""
+	// UnknownSymbol is shown for quantities without a unit or with an undefined unit
+	UnknownSymbol = "?"
 	// PanicOnIncompatibleUnits panic if operation with incompatible units happens
 	PanicOnIncompatibleUnits = os.Getenv("GOUNITSPANIC") == "1"
+	// PanicOnExponentOverflow panic if Mult, Div, Reciprocal or Power gives a unit with an
+	// exponent outside the range -128..127; otherwise the result has the UndefinedUnit.
+	// Use CheckedMult, CheckedDiv or CheckedPower to get an error instead.
+	PanicOnExponentOverflow = os.Getenv("GOUNITSPANIC") == "1"
 
-	baseSymbols    = [nBaseUnits]string{"m", "kg", "K", "A", "cd", "mol", "rad", "sr", "¤", "byte", "s"}
-	prefixValues   = [...]float64{deci, centi, hecto, milli, kilo, micro, mega, nano, giga, pico, tera, femto, peta, atto, exa, zepto, zetta, yotta, yocto}
+	baseSymbols    = [nBaseUnits]string{"m", "kg", "K", "A", "cd", "mol", "rad", "sr", "¤", "byte", "s", "count"}
+	prefixValues   = [...]float64{Deci, Centi, Hecto, Milli, Kilo, Micro, Mega, Nano, Giga, Pico, Tera, Femto, Peta, Atto, Exa, Zepto, Zetta, Yocto, Yotta}
 	prefixSymbols  = "dchmkuMnGpTfPaEzZyY"
 	symbolRx, muRx *regexp.Regexp
+	uncertainRx    *regexp.Regexp
+	feetInchRx     *regexp.Regexp
 )
 
-// Unit represents a unit of measure.
+// Unit represents a unit of measure. A Unit is a value: the exponents are a fixed size
+// array, so copies do not share state.
 type Unit struct {
 	symbol    string
 	factor    float64
-	exponents []int8
+	exponents [nBaseUnits]int8
 }
 
-func def(dim *[nBaseUnits]int8) func(string, float64) *Unit {
+func def(name string, dim *[nBaseUnits]int8) func(string, float64) *Unit {
+	if _, found := dimensionNames[*dim]; !found {
+		dimensionNames[*dim] = name
+	}
 	return func(symbol string, factor float64) *Unit {
-		return &Unit{symbol, factor, dim[:]}
+		return &Unit{symbol, factor, *dim}
 	}
 }
 
-func mapexp(e []int8, f func(int8) int8) []int8 {
-	e1 := [nBaseUnits]int8{}
+func mapexp(e [nBaseUnits]int8, f func(int8) int8) (e1 [nBaseUnits]int8) {
 	for i := 0; i < nBaseUnits; i++ {
 		e1[i] = f(e[i])
 	}
-	return e1[:]
+	return
 }
 
 // Symbol gets the string that represents the unit
-func (u *Unit) Symbol() string {
+func (u Unit) Symbol() string {
 	return u.symbol
 }
 
-func addu(a, b *Unit) *Unit {
-	u := &Unit{"", a.factor * b.factor, addx(a.exponents, b.exponents)}
-	u.symbol = makeSymbol(u.exponents)
-	return u
+// combine returns the exponents of a plus k times those of b, and false if one of them is
+// out of the range of int8. A k outside ±255 always overflows a nonzero exponent of b and
+// is rejected before the multiplication, which could overflow int itself.
+func combine(a, b [nBaseUnits]int8, k int) (r [nBaseUnits]int8, ok bool) {
+	for i := 0; i < nBaseUnits; i++ {
+		if b[i] != 0 && (k > math.MaxUint8 || k < -math.MaxUint8) {
+			return r, false
+		}
+		e := int(a[i]) + k*int(b[i])
+		if e < math.MinInt8 || e > math.MaxInt8 {
+			return r, false
+		}
+		r[i] = int8(e)
+	}
+	return r, true
 }
 
-func subu(a, b *Unit) *Unit {
-	u := &Unit{"", a.factor / b.factor, addx(a.exponents, negx(b.exponents))}
-	u.symbol = makeSymbol(u.exponents)
+// siUnits caches the SI units created by calculations, one per exponent vector, so
+// their symbols are built only once.
+var (
+	siUnits   = make(map[[nBaseUnits]int8]*Unit)
+	siUnitsMu sync.RWMutex
+)
+
+// siUnit returns the SI unit with the given exponents. Dimensionless results get the
+// unitless unit from the unit table.
+func siUnit(exponents [nBaseUnits]int8) *Unit {
+	if isDimensionless(exponents) {
+		return units[""]
+	}
+	siUnitsMu.RLock()
+	u, found := siUnits[exponents]
+	siUnitsMu.RUnlock()
+	if found {
+		return u
+	}
+	u = &Unit{makeSymbol(exponents), 1, exponents}
+	siUnitsMu.Lock()
+	siUnits[exponents] = u
+	siUnitsMu.Unlock()
 	return u
 }
 
-func addx(a, b []int8) []int8 {
-	r := [nBaseUnits]int8{}
-	for i := 0; i < nBaseUnits; i++ {
-		r[i] = a[i] + b[i]
-	}
-	return r[:]
+func isDimensionless(exponents [nBaseUnits]int8) bool {
+	return exponents == [nBaseUnits]int8{}
+}
+
+// defined returns false for the zero Unit and UndefinedUnit.
+func (u Unit) defined() bool {
+	return u.factor != 0
 }
 
-func negx(a []int8) []int8 {
+func negx(a [nBaseUnits]int8) [nBaseUnits]int8 {
 	return mapexp(a, func(e int8) int8 { return -e })
 }
 
@@ -126,7 +218,7 @@ func (u *Unit) setSymbol() {
 	u.symbol = makeSymbol(u.exponents)
 }
 
-func makeSymbol(expon []int8) string {
+func makeSymbol(expon [nBaseUnits]int8) string {
 	var a []string
 	for i := 0; i < nBaseUnits; i++ {
 		e := expon[i]
@@ -138,36 +230,71 @@ func makeSymbol(expon []int8) string {
 		}
 	}
 	if len(a) == 0 {
-		return "?"
+		return ""
 	}
 	return strings.Join(a, "")[1:]
 }
 
-var units = make(map[string]*Unit)
+// units holds the registered units, parsedUnits caches the units UnitFor has calculated
+// from symbols such as "km/h".
+var (
+	units       = make(map[string]*Unit)
+	parsedUnits = make(map[string]*Unit)
+)
 
 // UnitFor looks up or construct a unit ref from a given symbol
 func UnitFor(symbol string) *Unit {
+	if registryFrozen() {
+		return frozenUnitFor(symbol)
+	}
+	if u := ratedUnit(symbol); u != nil {
+		return u
+	}
 	u := units[symbol]
+	if u == nil {
+		u = parsedUnits[symbol]
+	}
 	//fmt.Println("found in cache [", symbol, "] -> ", u)
 	if u == nil {
-		q, err := ParseSymbol(symbol)
-		if err != nil {
-			u = &UndefinedUnit
-		} else {
-			u = q.Unit
-			units[u.symbol] = u // cache it
-		}
+		u = ratedUnitFor(symbol, func(u *Unit) *Unit {
+			parsedUnits[u.symbol] = u // cache it
+			return u
+		})
 	}
 	return u
 }
 
+// Symbols returns the sorted symbols of all registered units, including those added with
+// Define, but not those calculated from other symbols, e.g. "km/h". The dimensionless
+// unit "" is not included.
+func Symbols() []string {
+	symbols := make([]string, 0, len(units))
+	for symbol := range units {
+		if symbol != "" {
+			symbols = append(symbols, symbol)
+		}
+	}
+	sort.Strings(symbols)
+	return symbols
+}
+
+// prefixable lists the units that accept SI prefixes even though their factor is not 1:
+// the liter and the CGS units, e.g. "mL", "cP" and "mGal". Other units only accept
+// prefixes if they are SI units (factor 1) without spaces in the symbol.
+var prefixable = map[string]bool{"L": true, "P": true, "St": true, "Gal": true, "dyn": true, "erg": true}
+
 func prefix(symbol string) (f float64, base string, ok bool) {
+	return prefixIn(units, symbol)
+}
+
+// prefixIn is prefix for the units of a RegistrySnapshot.
+func prefixIn(units map[string]*Unit, symbol string) (f float64, base string, ok bool) {
 	if len(symbol) < 2 {
 		return 0, "", false
 	}
 
 	if len(symbol) > 2 && symbol[:2] == "da" {
-		f = deca
+		f = Deca
 		base = symbol[2:]
 		ok = true
 	} else {
@@ -185,6 +312,7 @@ func prefix(symbol string) (f float64, base string, ok bool) {
 			case u.symbol == "g":
 				f /= 1000
 				base = "kg"
+			case prefixable[u.symbol]:
 			case u.factor != 1 || strings.Contains(u.symbol, " "):
 				ok = false
 			}
@@ -195,61 +323,195 @@ func prefix(symbol string) (f float64, base string, ok bool) {
 	return
 }
 
-func haveSameExponents(x, y []int8) bool {
-	for i := range x {
-		if x[i] != y[i] {
-			return false
+func haveSameExponents(x, y [nBaseUnits]int8) bool {
+	return x == y
+}
+
+func (u Unit) toSI() (factor float64, si *Unit) {
+	return u.factor, siUnit(u.exponents)
+}
+
+// symbolReplacer normalizes the alternative notations of unit symbols: "*" and "·" for
+// ".", "^" before and superscript digits for exponents.
+var symbolReplacer = strings.NewReplacer("*", ".", "·", ".", "^", "", "²", "2", "³", "3", "⁴", "4", "⁻", "-", "¹", "1")
+
+// ambiguousSymbols maps symbols that are not registered because they are commonly used for
+// different units to the registered symbols to use instead, e.g. "gr" for grains and grams.
+// Parse rejects them, rather than reading one where the other is meant, unless a unit with
+// the symbol is defined.
+var ambiguousSymbols = map[string]string{
+	"gr": "grain or g",
+	"′":  "ft for feet", // the primes also mark minutes and seconds of arc, see DMS
+	"″":  "in for inches",
+}
+
+// markSymbols maps the marks for feet, inches and degrees, often pasted from documents, to
+// unit symbols, e.g. "6'" and "11\"" for 6 ft and 11 in. The primes ′ and ″ are left out,
+// as they also mark minutes and seconds of arc; they are read as feet and inches only in a
+// length such as 5′ 11″, see Parse.
+var markSymbols = map[string]string{
+	"'": "ft", "’": "ft",
+	"\"": "in", "”": "in", "''": "in",
+	"°": "deg", "° C": "°C", "° F": "°F",
+}
+
+// unmark replaces the marks in the factors of a unit symbol by unit symbols, e.g. "°/s"
+// becomes "deg/s". The symbol must have been normalized by normalizeSpace.
+func unmark(s string) string {
+	if m, found := markSymbols[s]; found {
+		return m
+	}
+	if !strings.ContainsAny(s, "'\"′’″”°") {
+		return s
+	}
+	var b strings.Builder
+	start := 0
+	flush := func(end int) {
+		factor := s[start:end]
+		name := strings.TrimRight(factor, "-0123456789⁻¹²³⁴")
+		if m, found := markSymbols[name]; found {
+			factor = m + factor[len(name):]
+		}
+		b.WriteString(factor)
+	}
+	for i, r := range s {
+		if strings.ContainsRune("./()*·^", r) {
+			flush(i)
+			b.WriteRune(r)
+			start = i + utf8.RuneLen(r)
 		}
 	}
-	return true
+	flush(len(s))
+	return b.String()
 }
 
-func emptyExponents() []int8 {
-	x := [nBaseUnits]int8{}
-	return x[:]
+// normalizeSpace trims s, replaces runs of white space by one space and removes the white
+// space around separators, e.g. " us  gal / h" becomes "us gal/h".
+func normalizeSpace(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	for _, sep := range []string{".", "*", "·", "/", "^", "(", ")"} {
+		s = strings.ReplaceAll(s, " "+sep, sep)
+		s = strings.ReplaceAll(s, sep+" ", sep)
+	}
+	return s
 }
 
-func (u Unit) toSI() (factor float64, si Unit) {
-	si = Unit{"", 1, u.exponents}
-	si.setSymbol()
-	return u.factor, si
+// unparen removes the parentheses around a whole part of a unit, e.g. "(kg.K)".
+func unparen(part string) string {
+	if strings.HasPrefix(part, "(") && strings.HasSuffix(part, ")") {
+		return part[1 : len(part)-1]
+	}
+	return part
 }
 
+// MaxInputLength is the maximum length in bytes of the text accepted by Parse and ParseSymbol.
+const MaxInputLength = 256
+
 // ParseSymbol parses the given unit and returns a Quantity with the value set to 1.
+// The accepted grammar, in EBNF, is:
+//
+//	unit     = registered symbol | part [ "/" part ] .
+//	part     = product | "(" product ")" .
+//	product  = factor { ( "." | "*" | "·" ) factor } .
+//	factor   = symbol [ [ "^" ] exponent ] .
+//	symbol   = registered symbol | prefix registered symbol .
+//	exponent = [ "-" | "⁻" ] digit { digit } .
+//
+// A symbol is any text without digits, '-', '.', '*', '/' and '^', e.g. "sq in". The marks
+// ' and " for feet and inches and ° for degrees are read as "ft", "in" and "deg", and "° C"
+// as "°C", e.g. "°/s" is "deg/s". White space is ignored around separators and runs of it
+// count as one space, so "us  gal / h" is "us gal/h". The digits of an exponent may also be
+// the superscripts "¹²³⁴", e.g. "J/(kg·K)" or "BTU/(hr·ft²·°F)". Prefixes are those of the
+// SI, with "u" for micro, see prefix. Exponents after the '/' must be positive. The
+// exponents of the resulting unit must be in the range -128..127 and its factor must be a
+// finite, non-zero float64. The input must not be longer than MaxInputLength. See
+// ParseSymbolWith for other notations.
 func ParseSymbol(s string) (Quantity, error) {
-	s = strings.ReplaceAll(s, "*", ".")
-	s = strings.ReplaceAll(s, "^", "")
+	return parseSymbol(s, NewParseOptions())
+}
+
+func parseSymbol(s string, o ParseOptions) (Quantity, error) {
+	q, _, err := parseRated(s, o)
+	return q, err
+}
+
+// parseRated is parseSymbol that also reports whether the unit depends on a currency rate
+// set with SetCurrencyRate, so UnitFor does not cache it with the units that never change.
+func parseRated(s string, o ParseOptions) (q Quantity, rated bool, err error) {
 	resultSI := Quantity{1.0, units[""]}
+	if len(s) > MaxInputLength {
+		return resultSI, rated, &SyntaxError{abbreviate(s), "unit too long"}
+	}
+	s, err = o.normalize(s)
+	if err != nil {
+		return resultSI, rated, err
+	}
+	units := o.units()
+	lookup := func(symbol string) *Unit {
+		u := units[symbol]
+		if o.Registry == nil && u != nil && !referenceCurrencies[symbol] && u.exponents == dimOf("¤") {
+			rated = true // even if no rate is set yet
+			if r := ratedUnit(symbol); r != nil {
+				return r
+			}
+		}
+		return u
+	}
+	if u := lookup(s); u != nil && s != "" {
+		return Quantity{1, u}, rated, nil // registered symbols such as "L/100km" need not be valid compounds
+	}
+	s = symbolReplacer.Replace(s)
 	parts := strings.Split(s, "/")
 	if len(parts) > 2 {
-		return resultSI, errors.New("more than one '/' in unit")
+		return resultSI, rated, &SyntaxError{s, "more than one '/' in unit"}
 	}
+	var exponents [nBaseUnits]int // checks for int8 overflow
 
 	for i, part := range parts {
+		part = unparen(part)
 		for _, symbol := range strings.Split(part, ".") {
 			match := symbolRx.FindStringSubmatch(symbol)
 			//fmt.Println("match", match)
 			if len(match) != 3 {
-				return resultSI, errors.New("cannot parse unit [" + s + "]")
+				return resultSI, rated, &SyntaxError{s, "cannot parse unit"}
 			}
-			u := units[match[1]]
+			u := lookup(match[1])
 			var pf float64 = 1
 			if u == nil {
-				p, baseUnit, ok := prefix(match[1])
+				if use, found := ambiguousSymbols[match[1]]; found {
+					return resultSI, rated, &SyntaxError{s, fmt.Sprintf("ambiguous unit %q, use %s, in", match[1], use)}
+				}
+				p, baseUnit, ok := prefixIn(units, match[1])
 				if !ok {
-					return resultSI, errors.New("unknown symbol [" + match[1] + "]")
+					return resultSI, rated, &UnknownUnitError{match[1]}
 				}
-				u = units[baseUnit]
+				u = lookup(baseUnit)
 				pf = p
 			}
 			factor, uSI := u.toSI()
-			var x int
-			mSI := Quantity{pf * factor, &uSI}
+			x := 1
+			mSI := Quantity{pf * factor, uSI}
 			if match[2] != "" {
-				x, _ = strconv.Atoi(match[2])
+				x64, err := strconv.ParseInt(match[2], 10, 8)
+				if err != nil {
+					return resultSI, rated, &SyntaxError{s, "exponent out of range in"}
+				}
+				x = int(x64)
 				if i == 1 && x < 0 {
-					return resultSI, errors.New("invalid format: negative exponent after the '/'")
+					return resultSI, rated, &SyntaxError{s, "negative exponent after the '/' in"}
+				}
+			}
+			for j, e := range uSI.exponents {
+				if i == 1 {
+					exponents[j] -= int(e) * x
+				} else {
+					exponents[j] += int(e) * x
+				}
+				if exponents[j] < math.MinInt8 || exponents[j] > math.MaxInt8 {
+					return resultSI, rated, &SyntaxError{s, "exponent out of range in"}
 				}
+			}
+			if x != 1 {
 				mSI = Power(mSI, int8(x))
 				//fmt.Println("x", x, "q^x", mSI.Format("%f %s"))
 			}
@@ -261,18 +523,39 @@ func ParseSymbol(s string) (Quantity, error) {
 			//fmt.Println("result so far", resultSI.value, resultSI.factor, resultSI.symbol, resultSI.exponents)
 		}
 	}
-	resultSI.factor, resultSI.value = resultSI.value, resultSI.factor
-	resultSI.symbol = s
+	if resultSI.value == 0 || math.IsInf(resultSI.value, 0) || math.IsNaN(resultSI.value) {
+		return Quantity{1.0, siUnit([nBaseUnits]int8{})}, rated, &SyntaxError{s, "factor out of range in"}
+	}
+	u := *resultSI.Unit // may be shared, e.g. an interned SI unit
+	u.factor, u.symbol = resultSI.value, s
+	resultSI.value, resultSI.Unit = 1, &u
 	//fmt.Println("final result", resultSI.value, resultSI.factor, resultSI.symbol, resultSI.exponents)
-	return resultSI, nil
+	return resultSI, rated, nil
 }
 
 // Define can be used to add a new unit to the unit table.
 // The new unit symbol must be unique, the base symbol must either exist or be a calculation
 // based on other units, e.g. "kg.q/s2", but not necessarily SI. 1 new unit = factor * base unit.
+// The base is resolved to SI units immediately and the SI factor is returned, so units
+// defined on other defined units do not form chains that are evaluated later. A base that
+// refers to the new symbol itself is an ErrCircularDefinition.
+// A symbol may consist of several words, e.g. "board ft", separated by single spaces.
+// If the base has an exact factor, see ExactFactor, so has the new unit, with the factor
+// taken as the shortest decimal that represents it, e.g. 6 for "fathom" = 6 ft.
 func Define(symbol string, factor float64, base string) (float64, error) {
-	if _, found := units[symbol]; found {
-		return 0, errors.New("duplicate symbol [" + symbol + "]")
+	if registryFrozen() {
+		return 0, fmt.Errorf("%w: cannot define [%s]", ErrRegistryFrozen, symbol)
+	}
+	if symbol != normalizeSpace(symbol) {
+		return 0, &SyntaxError{symbol, "invalid white space in symbol"}
+	}
+	if u, found := units[symbol]; found {
+		return 0, fmt.Errorf("%w [%s], already defined as %s", ErrDuplicateSymbol, symbol, u.describe())
+	}
+	for _, s := range baseSymbolsOf(base) {
+		if s == symbol {
+			return 0, fmt.Errorf("%w [%s]", ErrCircularDefinition, symbol)
+		}
 	}
 	mBase, err := ParseSymbol(base)
 	if err != nil {
@@ -280,18 +563,139 @@ func Define(symbol string, factor float64, base string) (float64, error) {
 	}
 	siFactor := factor * mBase.factor
 	units[symbol] = &Unit{symbol, siFactor, mBase.exponents}
+	if r := exactFactor(mBase.symbol, mBase.factor); r != nil {
+		// the factor is taken as the decimal it was written as, e.g. 0.3048
+		f, _ := new(big.Rat).SetString(strconv.FormatFloat(factor, 'g', -1, 64))
+		exactCacheMu.Lock()
+		exactDecimals[symbol] = f.Mul(f, r).RatString()
+		exactCacheMu.Unlock()
+	}
 	return siFactor, nil
 }
 
+// ResolvedFactor returns the factor to convert a value in the given unit to SI units, e.g.
+// 1000 for "km". The unit must exist or be calculable.
+func ResolvedFactor(symbol string) (float64, error) {
+	u := UnitFor(symbol)
+	if u == &UndefinedUnit {
+		return 0, &UnknownUnitError{symbol}
+	}
+	return u.factor, nil
+}
+
+// FactorBetween returns the factor to multiply a value in the from unit with to get the
+// value in the to unit. Both units must exist or be calculable, and be compatible. All units
+// in the table are linear: degC and degF are temperature differences without an offset.
+func FactorBetween(from, to string) (float64, error) {
+	f, t := UnitFor(from), UnitFor(to)
+	switch {
+	case f == &UndefinedUnit:
+		return 0, &UnknownUnitError{from}
+	case t == &UndefinedUnit:
+		return 0, &UnknownUnitError{to}
+	case !haveSameExponents(f.exponents, t.exponents):
+		return 0, &IncompatibleUnitsError{A: from, B: to}
+	}
+	return conversionRatio(f, t), nil
+}
+
+// DefineCount adds a named count unit, e.g. "request" or "page", so quantities like
+// "120 request/s" or "40 page/min" can be expressed. All count units are compatible with
+// each other and with the base unit "count", but not with dimensionless quantities.
+func DefineCount(symbol string) error {
+	_, err := Define(symbol, 1, "count")
+	return err
+}
+
+// Definition describes a unit for DefineAll: 1 new unit = Factor * Base.
+type Definition struct {
+	Factor float64 `json:"factor"`
+	Base   string  `json:"base"`
+}
+
+// DefineAll adds a set of new units to the unit table. The base of a definition may refer
+// to other units in the same set; these are defined first. Either all units are defined, or
+// none of them are and an error is returned, e.g. for a duplicate symbol or circular
+// definitions.
+func DefineAll(defs map[string]Definition) error {
+	if registryFrozen() {
+		return fmt.Errorf("%w: cannot define %d units", ErrRegistryFrozen, len(defs))
+	}
+	symbols := make([]string, 0, len(defs))
+	for symbol := range defs {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[string]int)
+	var order []string
+	var visit func(symbol string) error
+	visit = func(symbol string) error {
+		switch state[symbol] {
+		case visiting:
+			return fmt.Errorf("%w [%s]", ErrCircularDefinition, symbol)
+		case done:
+			return nil
+		}
+		state[symbol] = visiting
+		for _, dep := range baseSymbolsOf(defs[symbol].Base) {
+			if _, found := defs[dep]; found {
+				if err := visit(dep); err != nil {
+					return err
+				}
+			}
+		}
+		state[symbol] = done
+		order = append(order, symbol)
+		return nil
+	}
+	for _, symbol := range symbols {
+		if err := visit(symbol); err != nil {
+			return err
+		}
+	}
+
+	for i, symbol := range order {
+		if _, err := Define(symbol, defs[symbol].Factor, defs[symbol].Base); err != nil {
+			for _, s := range order[:i] {
+				delete(units, s)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// baseSymbolsOf returns the unit symbols, without exponents, a compound symbol is made of.
+func baseSymbolsOf(s string) []string {
+	var symbols []string
+	s = symbolReplacer.Replace(normalizeSpace(s))
+	for _, part := range strings.Split(s, "/") {
+		for _, symbol := range strings.Split(unparen(part), ".") {
+			if match := symbolRx.FindStringSubmatch(symbol); len(match) == 3 {
+				symbols = append(symbols, match[1])
+			}
+		}
+	}
+	return symbols
+}
+
 func init() {
 	fmt.Print("")
 	symbolRx = regexp.MustCompile(`^([^\d-]+)(-?\d+)?$`)
-	muRx = regexp.MustCompile(`^\s*(-?[\d.,]+)\s*(.*)$`)
+	muRx = regexp.MustCompile(`^\s*(-?[\d.,]+(?:[eE][-+]?\d+\b)?)\s*(.*)$`)
+	feetInchRx = regexp.MustCompile(`^\s*(-?)(\d+(?:\.\d+)?)\s*['′’]\s*(\d+(?:\.\d+)?)\s*(?:"|″|”|'')?\s*$`)
+	uncertainRx = regexp.MustCompile(`^\s*(-?[\d.,]+)\s*(?:(?:±|\+/-)\s*([\d.,]+)|\((\d+)\))\s*(.*)$`)
 
 	data := setup()
 	for _, value := range data {
-		if units[value.symbol] != nil {
-			panic("duplicate unit symbol")
+		if u := units[value.symbol]; u != nil {
+			panic(fmt.Sprintf("duplicate unit symbol [%s]: %s, already defined as %s",
+				value.symbol, value.describe(), u.describe()))
 		}
 		units[value.symbol] = value
 	}
@@ -0,0 +1,60 @@
+// Package unittest provides test assertions for quantities, so test suites need not compare
+// values with an epsilon by hand. Failures are reported with t.Errorf and describe the
+// difference, see quantity.DiffString.
+package unittest
+
+import (
+	"math"
+	"testing"
+
+	us "github.com/imhotep-nb/units/quantity"
+)
+
+// AssertEqual reports an error if got and want are not compatible or differ by more than
+// tol, e.g. AssertEqual(t, d, us.Q(1, "km"), us.Q(1, "m")). A tol of 0 requires equal values.
+func AssertEqual(t testing.TB, got, want, tol us.Quantity) bool {
+	t.Helper()
+	if !us.AreCompatible(got, want) || !us.AreCompatible(got, tol) {
+		t.Errorf("got %v, want %v with tolerance %v: units not compatible", got, want, tol)
+		return false
+	}
+	if math.Abs(got.ToSI().Value()-want.ToSI().Value()) > math.Abs(tol.ToSI().Value()) {
+		t.Errorf("got %v, want %v ± %v: %s", got, want, tol, us.DiffString(got, want))
+		return false
+	}
+	return true
+}
+
+// AssertDimension reports an error if q is not compatible with the unit of the symbol,
+// e.g. AssertDimension(t, v, "m/s") for a speed.
+func AssertDimension(t testing.TB, q us.Quantity, symbol string) bool {
+	t.Helper()
+	if err := us.ExpectDimension(q, symbol); err != nil {
+		t.Errorf("%v: %v", q, err)
+		return false
+	}
+	return true
+}
+
+// AssertConverts parses from and want, see quantity.Parse, and reports an error if from
+// converted to the unit of want differs from it by more than tol, e.g.
+// AssertConverts(t, "1 psi", "6894.757 Pa", us.Q(0.001, "Pa")).
+func AssertConverts(t testing.TB, from, want string, tol us.Quantity) bool {
+	t.Helper()
+	f, err := us.Parse(from)
+	if err != nil {
+		t.Errorf("invalid quantity %q: %v", from, err)
+		return false
+	}
+	w, err := us.Parse(want)
+	if err != nil {
+		t.Errorf("invalid quantity %q: %v", want, err)
+		return false
+	}
+	c, ok := f.ConvertTo(w.Symbol())
+	if !ok {
+		t.Errorf("%s does not convert to %s", from, w.Symbol())
+		return false
+	}
+	return AssertEqual(t, c, w, tol)
+}
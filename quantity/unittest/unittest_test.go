@@ -0,0 +1,48 @@
+package unittest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	us "github.com/imhotep-nb/units/quantity"
+)
+
+// recorder records the failures instead of failing the test.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestAssertions(t *testing.T) {
+	r := &recorder{TB: t}
+	ok := AssertEqual(r, us.Q(1000.4, "m"), us.Q(1, "km"), us.Q(0.5, "m")) &&
+		AssertDimension(r, us.Q(3, "kn"), "m/s") &&
+		AssertConverts(r, "1 psi", "6894.757 Pa", us.Q(0.001, "Pa")) &&
+		AssertConverts(r, "1 ft", "12 in", us.Q(0, "in"))
+	if !ok || len(r.errors) != 0 {
+		t.Error("expected no failures, actual:", r.errors)
+	}
+	data := []struct {
+		ok       bool
+		expected string
+	}{
+		{AssertEqual(r, us.Q(5, "cm"), us.Q(3, "cm"), us.Q(1, "mm")), "Δ = 0.02 m (2 cm)"},
+		{AssertEqual(r, us.Q(5, "cm"), us.Q(3, "s"), us.Q(1, "mm")), "units not compatible"},
+		{AssertDimension(r, us.Q(3, "kn"), "m"), "got speed"},
+		{AssertConverts(r, "1 psi", "6000 Pa", us.Q(1, "Pa")), "(894.757 Pa)"},
+		{AssertConverts(r, "1 psi", "1 m", us.Q(1, "m")), "does not convert"},
+		{AssertConverts(r, "1 furlong", "1 m", us.Q(1, "m")), "invalid quantity"},
+	}
+	for i, d := range data {
+		if d.ok || len(r.errors) <= i || !strings.Contains(r.errors[i], d.expected) {
+			t.Error("expected failure:", d.expected, "actual:", r.errors)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package quantity
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// nistFactors is the reference table of VerifyFactors: lines "symbol,factor" with the factor
+// to SI units; lines starting with # are comments.
+//
+//go:embed nist_factors.csv
+var nistFactors string
+
+// referenceConversions is the reference table of VerifyConversions, in the format of
+// VerifyAgainst.
+//
+//go:embed conversions.csv
+var referenceConversions string
+
+// FactorTolerance is the relative difference VerifyFactors allows between a registered
+// factor and the reference, for reference values that are rounded.
+const FactorTolerance = 1e-8
+
+// FactorMismatch reports a registered unit whose factor differs from the reference value.
+// Registered is 0 if the unit is not registered.
+type FactorMismatch struct {
+	Symbol     string
+	Registered float64
+	Reference  float64
+}
+
+// VerifyFactors compares the factors of the registered units with an embedded reference
+// table from NIST Special Publication 811 and returns the units that differ by more than
+// FactorTolerance, e.g. for a typo or an inverted factor. The result is empty if all
+// factors match.
+func VerifyFactors() []FactorMismatch {
+	var mismatches []FactorMismatch
+	for _, line := range strings.Split(nistFactors, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.LastIndex(line, ",")
+		symbol := line[:i]
+		reference, err := strconv.ParseFloat(line[i+1:], 64)
+		if err != nil {
+			panic("invalid reference factor: " + line)
+		}
+		var registered float64
+		if u, found := units[symbol]; found {
+			registered = u.factor
+		}
+		if math.Abs(registered-reference) > FactorTolerance*reference {
+			mismatches = append(mismatches, FactorMismatch{symbol, registered, reference})
+		}
+	}
+	return mismatches
+}
+
+// Mismatch reports a conversion that differs from the expected value, or a line of reference
+// data that cannot be checked, see VerifyAgainst.
+type Mismatch struct {
+	Line     int     // line number in the reference data
+	Value    float64 // value to convert
+	From, To string  // unit symbols
+	Expected float64 // expected result
+	Actual   float64 // result of the conversion, NaN if it failed
+	Err      error   // nil, or why the line cannot be checked
+}
+
+// VerifyConversions converts the values of an embedded table of reference conversions, from
+// the exact definitions in NIST Special Publication 811, and returns those whose results
+// differ by more than FactorTolerance, see VerifyAgainst.
+func VerifyConversions() []Mismatch {
+	return VerifyAgainst(strings.NewReader(referenceConversions))
+}
+
+// VerifyAgainst converts the values of reference data and returns the conversions whose
+// results differ from the expected values by more than FactorTolerance, e.g. to catch
+// regressions in factors with golden data from another library. The data is CSV with the
+// fields value, from, to and expected, e.g. "100,km/h,mph,62.137119223733397"; lines
+// starting with # are comments, and a first line "value,from,to,expected" is skipped. Lines
+// that cannot be parsed, and unknown or incompatible units, are returned with an error.
+// The result is empty if all conversions match.
+func VerifyAgainst(r io.Reader) []Mismatch {
+	var mismatches []Mismatch
+	cr := csv.NewReader(r)
+	cr.Comment = '#'
+	cr.FieldsPerRecord = 4
+	cr.TrimLeadingSpace = true
+	for first := true; ; first = false {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var pe *csv.ParseError
+			if !errors.As(err, &pe) {
+				return append(mismatches, Mismatch{Actual: math.NaN(), Err: err})
+			}
+			mismatches = append(mismatches, Mismatch{Line: pe.Line, Actual: math.NaN(), Err: err})
+			continue
+		}
+		if first && record[0] == "value" {
+			continue
+		}
+		line, _ := cr.FieldPos(0)
+		if m, ok := verifyConversion(line, record); !ok {
+			mismatches = append(mismatches, m)
+		}
+	}
+	return mismatches
+}
+
+// verifyConversion checks a record of VerifyAgainst, and returns false and the mismatch if
+// it does not match.
+func verifyConversion(line int, record []string) (Mismatch, bool) {
+	m := Mismatch{Line: line, From: record[1], To: record[2], Actual: math.NaN()}
+	var err1, err2 error
+	m.Value, err1 = strconv.ParseFloat(strings.TrimSpace(record[0]), 64)
+	m.Expected, err2 = strconv.ParseFloat(strings.TrimSpace(record[3]), 64)
+	if err1 != nil || err2 != nil {
+		m.Err = &SyntaxError{strings.Join(record, ","), "invalid number in"}
+		return m, false
+	}
+	from := UnitFor(m.From)
+	if from == &UndefinedUnit {
+		m.Err = &UnknownUnitError{m.From}
+		return m, false
+	}
+	q := Quantity{m.Value, from}
+	if m.Err = ExpectDimension(q, m.To); m.Err != nil {
+		return m, false
+	}
+	m.Actual = q.In(m.To).value
+	return m, math.Abs(m.Actual-m.Expected) <= FactorTolerance*math.Abs(m.Expected)
+}
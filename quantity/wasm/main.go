@@ -0,0 +1,99 @@
+//go:build js && wasm
+
+// Command wasm exports the quantity package to JavaScript, so browsers use the same unit
+// table as the Go services. Build it with
+//
+//	GOOS=js GOARCH=wasm go build -o units.wasm ./wasm
+//
+// and run it with wasm_exec.js from the Go distribution. It defines these functions on the
+// global object; errors are returned as {error: "..."} instead of being thrown:
+//
+//	unitsParse("3 ft")                 {value: 3, unit: "ft"}
+//	unitsConvert("3 ft", "m")          {value: 0.9144, unit: "m"}
+//	unitsFormat("3 ft", "%.1f %s")     "3.0 ft"
+//	unitsRegistry()                    JSON of the unit table, see RegistrySnapshot
+//	unitsLoadRegistry(json)            replaces the unit table, e.g. with custom units
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"syscall/js"
+
+	us "github.com/imhotep-nb/units/quantity"
+)
+
+func main() {
+	export("unitsParse", 1, func(args []js.Value) interface{} {
+		q, err := us.Parse(args[0].String())
+		if err != nil {
+			return errorValue(err)
+		}
+		return quantityValue(q)
+	})
+	export("unitsConvert", 2, func(args []js.Value) interface{} {
+		q, err := us.Parse(args[0].String())
+		if err != nil {
+			return errorValue(err)
+		}
+		to := args[1].String()
+		c, ok := q.ConvertTo(to)
+		if !ok {
+			return errorValue(&us.IncompatibleUnitsError{A: q.Symbol(), B: to})
+		}
+		return quantityValue(c)
+	})
+	export("unitsFormat", 2, func(args []js.Value) interface{} {
+		q, err := us.Parse(args[0].String())
+		if err != nil {
+			return errorValue(err)
+		}
+		return q.Format(args[1].String())
+	})
+	export("unitsRegistry", 0, func(args []js.Value) interface{} {
+		data, err := json.Marshal(us.SnapshotRegistry())
+		if err != nil {
+			return errorValue(err)
+		}
+		return string(data)
+	})
+	export("unitsLoadRegistry", 1, func(args []js.Value) interface{} {
+		var s us.RegistrySnapshot
+		if err := json.Unmarshal([]byte(args[0].String()), &s); err != nil {
+			return errorValue(err)
+		}
+		us.RestoreRegistry(s)
+		return js.Null()
+	})
+	select {} // keep the functions available
+}
+
+// export defines a function on the global object that checks the number and type of the
+// arguments and turns panics into error values.
+func export(name string, nargs int, fn func(args []js.Value) interface{}) {
+	js.Global().Set(name, js.FuncOf(func(this js.Value, args []js.Value) (result interface{}) {
+		defer func() {
+			if r := recover(); r != nil {
+				result = errorValue(fmt.Errorf("%s: %v", name, r))
+			}
+		}()
+		if len(args) != nargs {
+			return errorValue(fmt.Errorf("%s: %d arguments expected, got %d", name, nargs, len(args)))
+		}
+		for _, a := range args {
+			if a.Type() != js.TypeString {
+				return errorValue(fmt.Errorf("%s: string arguments expected", name))
+			}
+		}
+		return fn(args)
+	}))
+}
+
+func quantityValue(q us.Quantity) interface{} {
+	value, unit := q.Split()
+	return map[string]interface{}{"value": value, "unit": unit}
+}
+
+func errorValue(err error) interface{} {
+	return map[string]interface{}{"error": err.Error()}
+}
@@ -0,0 +1,202 @@
+package quantity
+
+import (
+	"strings"
+)
+
+// unitNames maps the singular names of units, in lower case, to their symbols.
+var unitNames = map[string]string{
+	"acre":              "acre",
+	"ampere":            "A",
+	"amp":               "A",
+	"bar":               "bar",
+	"becquerel":         "Bq",
+	"bit":               "bit",
+	"byte":              "byte",
+	"candela":           "cd",
+	"carat":             "ct",
+	"coulomb":           "C",
+	"day":               "d",
+	"degree":            "deg",
+	"degree celsius":    "degC",
+	"degree fahrenheit": "degF",
+	"dyne":              "dyn",
+	"erg":               "erg",
+	"farad":             "F",
+	"foot":              "ft",
+	"gallon":            "us gal",
+	"gauss":             "gauss",
+	"grain":             "grain",
+	"gram":              "g",
+	"gray":              "Gy",
+	"hectare":           "ha",
+	"henry":             "H",
+	"hertz":             "Hz",
+	"horsepower":        "hp",
+	"hour":              "h",
+	"imperial gallon":   "imp gal",
+	"inch":              "in",
+	"joule":             "J",
+	"katal":             "kat",
+	"kelvin":            "K",
+	"knot":              "kn",
+	"liter":             "L",
+	"litre":             "L",
+	"long ton":          "long ton",
+	"lumen":             "lm",
+	"lux":               "lx",
+	"meter":             "m",
+	"metre":             "m",
+	"mile":              "mi",
+	"minute":            "min",
+	"mole":              "mol",
+	"nautical mile":     "M",
+	"newton":            "N",
+	"ohm":               "Ω",
+	"ounce":             "oz",
+	"pascal":            "Pa",
+	"poise":             "P",
+	"pound":             "lb",
+	"pound force":       "lbf",
+	"radian":            "rad",
+	"second":            "s",
+	"short ton":         "short ton",
+	"siemens":           "S",
+	"slug":              "slug",
+	"sievert":           "Sv",
+	"steradian":         "sr",
+	"stokes":            "St",
+	"stone":             "st",
+	"tesla":             "T",
+	"tonne":             "t",
+	"troy ounce":        "oz t",
+	"volt":              "V",
+	"watt":              "W",
+	"weber":             "Wb",
+	"yard":              "yd",
+}
+
+// irregularPlurals maps plural unit names that do not end in "s" or "es" to the singular.
+var irregularPlurals = map[string]string{
+	"feet": "foot",
+}
+
+// prefixNames maps the names of the SI prefixes to their symbols, see prefix.
+var prefixNames = []struct{ name, symbol string }{
+	{"yocto", "y"}, {"zepto", "z"}, {"atto", "a"}, {"femto", "f"}, {"pico", "p"},
+	{"nano", "n"}, {"micro", "u"}, {"milli", "m"}, {"centi", "c"}, {"deci", "d"},
+	{"deca", "da"}, {"deka", "da"}, {"hecto", "h"}, {"kilo", "k"}, {"mega", "M"},
+	{"giga", "G"}, {"tera", "T"}, {"peta", "P"}, {"exa", "E"}, {"zetta", "Z"}, {"yotta", "Y"},
+}
+
+// ParseWords parses a quantity written out in words, e.g. "3 meters per second",
+// "10 kilometers per hour" or "5 pounds per square inch", for voice and natural language
+// input. The number is followed by unit names, singular or plural and optionally with an
+// SI prefix, e.g. "millilitres". "square" and "cubic" before, or "squared" and "cubed"
+// after a name raise it to the power 2 or 3. Names after "per" divide. Case is ignored.
+func ParseWords(s string) (Quantity, error) {
+	undef := Quantity{0, &UndefinedUnit}
+	if len(s) > MaxInputLength {
+		return undef, &SyntaxError{abbreviate(s), "input too long"}
+	}
+	words := strings.Fields(strings.ToLower(s))
+	if len(words) < 2 {
+		return undef, &SyntaxError{s, "missing unit in"}
+	}
+	value, err := parseNumber(words[0], s, PointDecimal)
+	if err != nil {
+		return undef, err
+	}
+	var parts [2][]string // symbols before and after "per"
+	side, power := 0, ""
+	for i := 1; i < len(words); i++ {
+		switch words[i] {
+		case "per":
+			side = 1
+			continue
+		case "square":
+			power = "2"
+			continue
+		case "cubic":
+			power = "3"
+			continue
+		case "squared", "cubed":
+			n := len(parts[side])
+			if n == 0 {
+				return undef, &SyntaxError{s, "'" + words[i] + "' without unit in"}
+			}
+			parts[side][n-1] += map[string]string{"squared": "2", "cubed": "3"}[words[i]]
+			continue
+		}
+		symbol, n := lookupName(words[i:])
+		if n == 0 {
+			return undef, &UnknownUnitError{words[i]}
+		}
+		i += n - 1
+		parts[side] = append(parts[side], symbol+power)
+		power = ""
+	}
+	if len(parts[0]) == 0 || side == 1 && len(parts[1]) == 0 || power != "" {
+		return undef, &SyntaxError{s, "missing unit in"}
+	}
+	unit := strings.Join(parts[0], ".")
+	if len(parts[1]) > 0 {
+		unit += "/" + strings.Join(parts[1], ".")
+	}
+	u, err := ParseSymbol(unit)
+	if err != nil {
+		return undef, err
+	}
+	return Quantity{value, u.Unit}, nil
+}
+
+// lookupName returns the symbol of the unit named by the longest match of up to 3 of the
+// given words, and the number of words used, 0 if there is no match. Leading words may be
+// plural, e.g. "degrees celsius".
+func lookupName(words []string) (string, int) {
+	for n := 3; n > 0; n-- {
+		if n > len(words) {
+			continue
+		}
+		var name string
+		for _, w := range words[:n-1] {
+			name += strings.TrimSuffix(w, "s") + " "
+		}
+		if symbol, found := lookupWord(name, words[n-1]); found {
+			return symbol, n
+		}
+	}
+	return "", 0
+}
+
+// lookupWord looks up the name formed by the given leading words and a last word, which
+// may be plural and have an SI prefix.
+func lookupWord(leading, last string) (string, bool) {
+	if symbol, found := lookupSingular(leading, last); found {
+		return symbol, true
+	}
+	if leading != "" {
+		return "", false
+	}
+	for _, p := range prefixNames {
+		if strings.HasPrefix(last, p.name) {
+			if symbol, found := lookupSingular("", last[len(p.name):]); found {
+				return p.symbol + symbol, true
+			}
+		}
+	}
+	return "", false
+}
+
+func lookupSingular(leading, last string) (string, bool) {
+	candidates := []string{last, strings.TrimSuffix(last, "s"), strings.TrimSuffix(last, "es")}
+	if singular, found := irregularPlurals[last]; found {
+		candidates = append(candidates, singular)
+	}
+	for _, c := range candidates {
+		if symbol, found := unitNames[leading+c]; found {
+			return symbol, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,39 @@
+package resource
+
+import (
+	"errors"
+	"fmt"
+
+	us "github.com/zn8nz/units/quantity"
+)
+
+// Exchange withdraws amount from one Resource and deposits its value at the given rate to
+// another Resource of a different dimension, e.g. 10 $ from a prepaid balance as kWh to an
+// energy allowance at a price of 0.25 $/kWh. The deposit is amount×rate, or amount÷rate if
+// only that is compatible with the target, so a price can be used in both directions. The
+// exchange is atomic: either both balances change or neither does. The deposited quantity
+// is returned in the unit of the Context of the target.
+func Exchange(from, to *Resource, amount, rate us.Quantity) (us.Quantity, error) {
+	if from == to {
+		return us.Quantity{}, errors.New("exchange within one resource")
+	}
+	deposit := us.Mult(amount, rate)
+	if !us.AreCompatible(deposit, to.balance) {
+		deposit = us.Div(amount, rate)
+		if !us.AreCompatible(deposit, to.balance) {
+			return us.Quantity{}, fmt.Errorf("rate %v does not convert %v to %s", rate, amount, to.balance.Symbol())
+		}
+	}
+	fromBalance, ok := from.withdrawn(amount)
+	if !ok {
+		return us.Quantity{}, fmt.Errorf("cannot withdraw %v from %v", amount, from)
+	}
+	toBalance, ok := to.deposited(deposit)
+	if !ok {
+		return us.Quantity{}, fmt.Errorf("cannot deposit %v to %v", to.Convert(deposit), to)
+	}
+	from.balance, to.balance = fromBalance, toBalance
+	from.emit()
+	to.emit()
+	return to.Convert(deposit), nil
+}
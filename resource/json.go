@@ -0,0 +1,74 @@
+package resource
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+
+	"github.com/zn8nz/units/context"
+	us "github.com/zn8nz/units/quantity"
+)
+
+// jsonResource is the JSON representation of a Resource. Unbounded limits and an unset
+// overdraft are left out.
+type jsonResource struct {
+	Balance   us.Quantity  `json:"balance"`
+	Min       *us.Quantity `json:"min,omitempty"`
+	Max       *us.Quantity `json:"max,omitempty"`
+	Overdraft *us.Quantity `json:"overdraft,omitempty"`
+	Context   string       `json:"context,omitempty"`
+}
+
+// MarshalJSON implements the json.Marshaler interface. The balance, min, max and overdraft
+// limit are in the unit of the Context, e.g.
+// {"balance":{"value":15,"unit":"L"},"min":{"value":1,"unit":"L"},"max":{"value":50,"unit":"L"},"context":"tank"}.
+func (h *Resource) MarshalJSON() ([]byte, error) {
+	j := jsonResource{Balance: h.Balance(), Context: h.Name}
+	if min := h.Convert(h.min); !math.IsInf(min.Value(), 0) {
+		j.Min = &min
+	}
+	if max := h.Convert(h.max); !math.IsInf(max.Value(), 0) {
+		j.Max = &max
+	}
+	if !h.overdraft.Invalid() {
+		o := h.Convert(h.overdraft)
+		j.Overdraft = &o
+	}
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface for the format written by
+// MarshalJSON. A missing min or max is unbounded. The balance is required and must be within
+// the limits, and the context, if any, must be registered.
+func (h *Resource) UnmarshalJSON(data []byte) error {
+	var j jsonResource
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	if j.Context != "" && context.Ctx(j.Context) == nil {
+		return errors.New("unknown context: " + j.Context)
+	}
+	if j.Balance.Invalid() {
+		return errors.New("missing resource balance: " + string(data))
+	}
+	min, max := NoMin(j.Balance.Symbol()), NoMax(j.Balance.Symbol())
+	if j.Min != nil {
+		min = *j.Min
+	}
+	if j.Max != nil {
+		max = *j.Max
+	}
+	r := New(min, max, j.Context)
+	if r == nil || !us.AreCompatible(min, j.Balance) {
+		return errors.New("invalid resource limits: " + string(data))
+	}
+	if j.Overdraft != nil && !r.AllowOverdraft(*j.Overdraft) {
+		return errors.New("invalid overdraft limit: " + j.Overdraft.String())
+	}
+	if r.outOfOverdraftBounds(j.Balance) {
+		return errors.New("balance out of bounds: " + j.Balance.String())
+	}
+	r.balance = r.Convert(j.Balance)
+	*h = *r
+	return nil
+}
@@ -0,0 +1,53 @@
+package resource
+
+import "math"
+
+// MetricsSink receives gauge metrics of a Resource, e.g. to publish them with expvar or a
+// metrics library.
+type MetricsSink interface {
+	Gauge(name string, value float64)
+}
+
+// MetricsFunc adapts a function to a MetricsSink, e.g. for expvar:
+//
+//	vars := expvar.NewMap("quota")
+//	rsc.SetMetrics("disk", MetricsFunc(func(name string, value float64) {
+//		f := new(expvar.Float)
+//		f.Set(value)
+//		vars.Set(name, f)
+//	}))
+type MetricsFunc func(name string, value float64)
+
+// Gauge calls f(name, value).
+func (f MetricsFunc) Gauge(name string, value float64) {
+	f(name, value)
+}
+
+// SetMetrics makes the Resource report its gauges to sink after each change: prefix +
+// ".balance", ".min" and ".max" in the unit of the Context, and ".utilization", see
+// Utilization. The gauges are reported once immediately. Pass nil to stop reporting.
+func (h *Resource) SetMetrics(prefix string, sink MetricsSink) {
+	h.metrics, h.metricsPrefix = sink, prefix
+	h.emit()
+}
+
+// Utilization returns the balance as a percentage of the range from min to max, e.g. 25
+// for a balance of 30 L with a min of 10 L and a max of 90 L. It is negative if the
+// Resource is overdrawn, and NaN if min or max is unbounded.
+func (h *Resource) Utilization() float64 {
+	min, max, balance := h.min.ToSI().Value(), h.max.ToSI().Value(), h.balance.ToSI().Value()
+	if math.IsInf(min, 0) || math.IsInf(max, 0) {
+		return math.NaN()
+	}
+	return (balance - min) / (max - min) * 100
+}
+
+func (h *Resource) emit() {
+	if h.metrics == nil {
+		return
+	}
+	h.metrics.Gauge(h.metricsPrefix+".balance", h.Balance().Value())
+	h.metrics.Gauge(h.metricsPrefix+".min", h.Convert(h.min).Value())
+	h.metrics.Gauge(h.metricsPrefix+".max", h.Convert(h.max).Value())
+	h.metrics.Gauge(h.metricsPrefix+".utilization", h.Utilization())
+}
@@ -3,6 +3,7 @@ package resource
 import (
 	"errors"
 	"fmt"
+	"math"
 	us "github.com/zn8nz/units/quantity"
 	"github.com/zn8nz/units/context"
 )
@@ -11,15 +12,34 @@ import (
 // For example use for inventory, limited resources. A Resource has a min
 // and max value and guarantees the balance is between these two at all times.
 // Initially a Resource has a balance equal to the min value.
+// With AllowOverdraft, withdrawals may take the balance below min, like an account.
 type Resource struct {
 	min, max, balance us.Quantity
+	overdraft         us.Quantity // how far the balance may go below min, invalid if not allowed
+	metrics           MetricsSink // nil or receiver of gauges after each change
+	metricsPrefix     string
 	*context.Context
 }
 
+// Heap is the behaviour of a Resource that code storing quantities depends on. Resource
+// implements it; accept a Heap to allow other implementations, e.g. in tests.
+type Heap interface {
+	Set(q us.Quantity) bool
+	Deposit(q us.Quantity) bool
+	Withdraw(q us.Quantity) bool
+	WithdrawPct(percentage float64) (us.Quantity, error)
+	Balance() us.Quantity
+	Limits() (min us.Quantity, max us.Quantity)
+}
+
+var _ Heap = (*Resource)(nil)
+
 // New creates a new Resource with the given minimum and maximum values.
-// min should be less than max and the units should be compatible.
-// The initial balance value is set to min. A Context name can be provided, or ""
-// if no Context is required.
+// min should be less than max and the units should be compatible. Either limit can be
+// unbounded, see NoMin and NoMax, e.g. for the energy produced to date.
+// The initial balance value is set to min, or to 0 if min is unbounded and max is not
+// negative, in the unit of min if both limits are unbounded. A Context name can be
+// provided, or "" if no Context is required.
 func New(min us.Quantity, max us.Quantity, c string) *Resource {
 	var ctx *context.Context
 	if c != "" {
@@ -28,11 +48,31 @@ func New(min us.Quantity, max us.Quantity, c string) *Resource {
 		ctx, _ = context.DefineContext("", min.Symbol(), us.DefaultFormat)
 	}
 	if us.AreCompatible(min, max) && us.Less(min, max) {
-		return &Resource{ctx.Convert(min), ctx.Convert(max), min, ctx}
+		balance := min
+		switch {
+		case !math.IsInf(min.Value(), -1):
+		case math.IsInf(max.Value(), 1):
+			balance = us.Q(0, min.Symbol()) // 0 times an infinite limit is NaN
+		case max.Value() < 0:
+			balance = max
+		default:
+			balance = us.MultFac(max, 0)
+		}
+		return &Resource{min: ctx.Convert(min), max: ctx.Convert(max), balance: balance, Context: ctx}
 	}
 	return nil
 }
 
+// NoMin returns an unbounded minimum for New in the unit of the given symbol.
+func NoMin(symbol string) us.Quantity {
+	return us.Q(math.Inf(-1), symbol)
+}
+
+// NoMax returns an unbounded maximum for New in the unit of the given symbol.
+func NoMax(symbol string) us.Quantity {
+	return us.Q(math.Inf(1), symbol)
+}
+
 // Set the Resource to the given value. The value should be between the min
 // and max of the Resource. Return true for success, false for incompatible unit
 // or out of bounds.
@@ -41,47 +81,76 @@ func (h *Resource) Set(q us.Quantity) bool {
 		return false
 	}
 	h.balance = q
+	h.emit()
 	return true
 }
 
 // Deposit adds the Measurement to the Resource. Return true for success, false for
-// incompatible unit or out of bounds.
+// incompatible unit or out of bounds. A deposit that raises an overdrawn balance is
+// allowed even if the balance stays below min.
 func (h *Resource) Deposit(q us.Quantity) bool {
-	if !us.AreCompatible(h.balance, q) {
-		return false
-	}
-	n := us.Add(h.balance, q)
-	if h.outOfBounds(n) {
+	n, ok := h.deposited(q)
+	if !ok {
 		return false
 	}
 	h.balance = n
+	h.emit()
 	return true
 }
 
+// deposited returns the balance after depositing q, and false if that is not allowed.
+func (h *Resource) deposited(q us.Quantity) (us.Quantity, bool) {
+	if !us.AreCompatible(h.balance, q) {
+		return h.balance, false
+	}
+	n := us.Add(h.balance, q)
+	if us.More(n, h.max) || us.Less(n, h.balance) && h.outOfOverdraftBounds(n) {
+		return h.balance, false
+	}
+	return n, true
+}
+
 // Withdraw subtracts the given amount from the Resource.
 // Return true for success, false for incompatible unit or out of bounds
 func (h *Resource) Withdraw(q us.Quantity) bool {
-	if !us.AreCompatible(h.balance, q) {
-		return false
-	}
-	n := us.Subtract(h.balance, q)
-	if h.outOfBounds(n) {
+	n, ok := h.withdrawn(q)
+	if !ok {
 		return false
 	}
 	h.balance = n
+	h.emit()
 	return true
 }
 
-// WithdrawPct subtracts a percentage of the balance. It returns the
-// quantity that has been deducted and an error or nil if the percentage
-// is not in the range 0..100.
+// withdrawn returns the balance after withdrawing q, and false if that is not allowed.
+func (h *Resource) withdrawn(q us.Quantity) (us.Quantity, bool) {
+	if !us.AreCompatible(h.balance, q) {
+		return h.balance, false
+	}
+	n := us.Subtract(h.balance, q)
+	if h.outOfOverdraftBounds(n) {
+		return h.balance, false
+	}
+	return n, true
+}
+
+// WithdrawPct subtracts a percentage of the balance. The amount is calculated from the
+// balance in SI units, subtracted from it exactly as calculated and returned in the unit
+// of the Context. An error is returned, and the balance is not changed, if the percentage
+// is not in the range 0..100 or the new balance would be out of bounds, e.g. below a min
+// greater than 0.
 func (h *Resource) WithdrawPct(percentage float64) (us.Quantity, error) {
-	if percentage < 0 || percentage > 100 {
-		msg := fmt.Sprintf("percentage not in range 0..1")
-		return us.Quantity{}, errors.New(msg)
+	if !(percentage >= 0 && percentage <= 100) {
+		return us.Quantity{}, fmt.Errorf("percentage not in range 0..100: %g", percentage)
+	}
+	balance := h.balance.ToSI()
+	taken := us.Scale(balance, percentage)
+	n := us.Subtract(balance, taken)
+	if h.outOfOverdraftBounds(n) {
+		return us.Quantity{}, errors.New("balance out of bounds after withdrawing " + taken.String())
 	}
-	taken := us.MultFac(h.balance, percentage/100.0)
-	h.balance = us.Subtract(h.balance, taken)
+	h.balance = n
+	h.emit()
 	return h.Convert(taken), nil
 }
 
@@ -89,6 +158,34 @@ func (h *Resource) outOfBounds(q us.Quantity) bool {
 	return us.Less(q, h.min) || us.More(q, h.max)
 }
 
+// outOfOverdraftBounds is outOfBounds with the min lowered by the overdraft limit, if any.
+func (h *Resource) outOfOverdraftBounds(q us.Quantity) bool {
+	if h.overdraft.Invalid() {
+		return h.outOfBounds(q)
+	}
+	return us.Less(q, us.Subtract(h.min, h.overdraft)) || us.More(q, h.max)
+}
+
+// AllowOverdraft lets Withdraw and Deposit take the balance below min, up to the given
+// limit below it, e.g. an account with a min of 0 and an overdraft limit of 500 USD.
+// A limit of 0 disables the overdraft again; the balance may then stay below min until it
+// is brought back. Returns true for success, false for incompatible unit or a negative limit.
+func (h *Resource) AllowOverdraft(limit us.Quantity) bool {
+	if !us.AreCompatible(h.min, limit) || limit.Value() < 0 {
+		return false
+	}
+	h.overdraft = limit
+	return true
+}
+
+// Overdrawn returns the amount the balance is below min, 0 if it is not.
+func (h *Resource) Overdrawn() us.Quantity {
+	if us.Less(h.balance, h.min) {
+		return h.Convert(us.Subtract(h.min, h.balance))
+	}
+	return h.Convert(us.MultFac(h.min, 0))
+}
+
 // Balance returns the current balance.
 func (h *Resource) Balance() us.Quantity {
 	return h.Convert(h.balance)
@@ -101,6 +198,7 @@ func (h *Resource) Min(min us.Quantity) bool {
 		return false
 	}
 	h.min = min
+	h.emit()
 	return true
 }
 
@@ -111,6 +209,7 @@ func (h *Resource) Max(max us.Quantity) bool {
 		return false
 	}
 	h.max = max
+	h.emit()
 	return true
 }
 
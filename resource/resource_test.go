@@ -1,7 +1,10 @@
 package resource
 
 import (
+	"encoding/json"
+	"math"
 	"testing"
+	"testing/quick"
 	. "github.com/zn8nz/units/quantity"
 	. "github.com/zn8nz/units/context"
 )
@@ -49,6 +52,192 @@ func TestWithdraw(t *testing.T) {
 	}
 }
 
+func TestOverdraft(t *testing.T) {
+	rsc := New(Q(0, "USD"), Q(1000, "USD"), "")
+	rsc.Set(Q(100, "USD"))
+	if rsc.AllowOverdraft(Q(-5, "USD")) || rsc.AllowOverdraft(Q(5, "m")) {
+		t.Error("invalid overdraft limit accepted")
+	}
+	if !rsc.AllowOverdraft(Q(500, "USD")) {
+		t.Fatal("overdraft limit rejected")
+	}
+	if !rsc.Withdraw(Q(400, "USD")) || rsc.Balance().Value() != -300 {
+		t.Error("expected: -300 USD, actual:", rsc.Balance())
+	}
+	if o := rsc.Overdrawn(); o.Value() != 300 {
+		t.Error("expected: 300 USD overdrawn, actual:", o)
+	}
+	if rsc.Withdraw(Q(201, "USD")) {
+		t.Error("overdraft limit ignored")
+	}
+	rsc.AllowOverdraft(Q(0, "USD"))
+	if rsc.Withdraw(Q(1, "USD")) {
+		t.Error("withdrawal accepted after disabling overdraft")
+	}
+	if !rsc.Deposit(Q(100, "USD")) || rsc.Overdrawn().Value() != 200 {
+		t.Error("expected: 200 USD overdrawn, actual:", rsc.Overdrawn())
+	}
+	if !rsc.Deposit(Q(250, "USD")) || rsc.Overdrawn().Value() != 0 {
+		t.Error("expected: 0 USD overdrawn, actual:", rsc.Overdrawn())
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	rsc := New(Q(10, "L"), Q(90, "L"), "")
+	gauges := make(map[string]float64)
+	rsc.SetMetrics("tank", MetricsFunc(func(name string, value float64) {
+		gauges[name] = value
+	}))
+	if gauges["tank.balance"] != 10 || gauges["tank.utilization"] != 0 {
+		t.Error("expected: initial gauges, actual:", gauges)
+	}
+	rsc.Deposit(Q(20, "L"))
+	if gauges["tank.balance"] != 30 || gauges["tank.min"] != 10 || gauges["tank.max"] != 90 ||
+		math.Abs(gauges["tank.utilization"]-25) > 1e-9 {
+		t.Error("expected: balance 30, min 10, max 90, utilization 25, actual:", gauges)
+	}
+	rsc.Deposit(Q(100, "L"))
+	if gauges["tank.balance"] != 30 {
+		t.Error("gauge changed by failed deposit:", gauges)
+	}
+	rsc.SetMetrics("", nil)
+	rsc.Withdraw(Q(10, "L"))
+	if gauges["tank.balance"] != 30 {
+		t.Error("gauge reported after SetMetrics(nil):", gauges)
+	}
+}
+
+func TestWithdrawPctExact(t *testing.T) {
+	DefineContext("fuel", "us gal", "%.2f %s")
+	f := func(balance, percentage float64) bool {
+		balance = math.Mod(math.Abs(balance), 1000)
+		percentage = math.Mod(math.Abs(percentage), 100)
+		rsc := New(Q(0, "L"), Q(4000, "L"), "fuel")
+		rsc.Set(Q(balance, "L"))
+		before := rsc.Balance()
+		taken, err := rsc.WithdrawPct(percentage)
+		if err != nil || taken.Symbol() != "us gal" {
+			return false
+		}
+		sum := Add(rsc.Balance(), taken)
+		return Equal(sum, before, Q(1e-9, "L")) &&
+			Equal(taken, MultFac(before, percentage/100), Q(1e-9, "L"))
+	}
+	if err := quick.Check(f, nil); err != nil {
+		t.Error(err)
+	}
+	rsc := New(Q(10, "L"), Q(100, "L"), "")
+	rsc.Set(Q(20, "L"))
+	for _, pct := range []float64{-1, 101, math.NaN(), 60} {
+		if _, err := rsc.WithdrawPct(pct); err == nil {
+			t.Error("accepted withdrawal of", pct, "percent")
+		}
+		if rsc.Balance().Value() != 20 {
+			t.Error("balance changed by invalid withdrawal:", rsc.Balance())
+		}
+	}
+}
+
+func TestUnbounded(t *testing.T) {
+	produced := New(Q(0, "kWh"), NoMax("kWh"), "")
+	if !produced.Deposit(Q(1e9, "kWh")) || produced.Deposit(Q(-2e9, "kWh")) {
+		t.Error("expected: deposits up to infinity, min 0, actual:", produced)
+	}
+	if !math.IsNaN(produced.Utilization()) {
+		t.Error("expected: NaN utilization, actual:", produced.Utilization())
+	}
+	budget := New(NoMin("L"), Q(100, "L"), "")
+	if b := budget.Balance().Value(); b != 0 {
+		t.Error("expected: initial balance 0 L, actual:", b)
+	}
+	if !budget.Withdraw(Q(500, "L")) || budget.Deposit(Q(601, "L")) {
+		t.Error("expected: withdrawals down to -infinity, max 100 L, actual:", budget)
+	}
+	if debt := New(NoMin("$"), Q(-10, "$"), ""); debt.Balance().Value() != -10 {
+		t.Error("expected: initial balance -10 $, actual:", debt.Balance())
+	}
+	free := New(NoMin("kWh"), NoMax("kWh"), "")
+	if free.Balance().String() != "0.0000 kWh" {
+		t.Error("expected: initial balance 0 kWh, actual:", free.Balance())
+	}
+	if !free.Withdraw(Q(5, "kWh")) || !free.Deposit(Q(1e9, "kWh")) || free.Balance().String() != "999999995.0000 kWh" {
+		t.Error("expected: no limits, actual:", free.Balance())
+	}
+}
+
+func TestJSON(t *testing.T) {
+	DefineContext("bucket", "L", "%.1f %s")
+	rsc := New(Q(1, "L"), Q(50, "L"), "bucket")
+	rsc.Set(Q(15, "L"))
+	data, err := json.Marshal(rsc)
+	expected := `{"balance":{"value":15,"unit":"L"},"min":{"value":1,"unit":"L"},"max":{"value":50,"unit":"L"},"context":"bucket"}`
+	if err != nil || string(data) != expected {
+		t.Error("expected:", expected, "actual:", string(data), err)
+	}
+	var out Resource
+	if err := json.Unmarshal(data, &out); err != nil || out.String() != "15.0 L" || out.Name != "bucket" {
+		t.Error("expected: 15.0 L in bucket context, actual:", out, err)
+	}
+	// the balance is kept in the unit of the context
+	data = []byte(`{"balance":{"value":2,"unit":"us gal"},"max":{"value":50,"unit":"L"},"context":"bucket"}`)
+	if err := json.Unmarshal(data, &out); err != nil || out.Balance().Symbol() != "L" {
+		t.Error("expected: balance in L, actual:", out.Balance(), err)
+	}
+	account := New(Q(0, "USD"), NoMax("USD"), "")
+	account.AllowOverdraft(Q(100, "USD"))
+	account.Withdraw(Q(40, "USD"))
+	data, err = json.Marshal(account)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &out); err != nil || out.Balance().Value() != -40 || out.Overdrawn().Value() != 40 {
+		t.Error("expected: -40 USD, actual:", out.Balance(), string(data), err)
+	}
+	for _, s := range []string{
+		`{"balance":{"value":60,"unit":"L"},"min":{"value":1,"unit":"L"},"max":{"value":50,"unit":"L"}}`,
+		`{"balance":{"value":6,"unit":"L"},"min":{"value":1,"unit":"m"}}`,
+		`{"balance":{"value":6,"unit":"L"},"context":"no such context"}`,
+		`{}`,
+		`{"balance":null,"max":{"value":50,"unit":"L"}}`,
+	} {
+		if err := json.Unmarshal([]byte(s), &out); err == nil {
+			t.Error("invalid resource accepted:", s)
+		}
+	}
+}
+
+func TestExchange(t *testing.T) {
+	wallet := New(Q(0, "$"), Q(1000, "$"), "")
+	energy := New(Q(0, "kWh"), Q(50, "kWh"), "")
+	wallet.Set(Q(100, "$"))
+	price := Q(0.25, "$/kWh")
+	got, err := Exchange(wallet, energy, Q(10, "$"), price)
+	if err != nil || got.Symbol() != "kWh" || math.Abs(got.Value()-40) > 1e-9 {
+		t.Error("expected: 40 kWh, actual:", got, err)
+	}
+	// back again, the price divides
+	if got, err = Exchange(energy, wallet, Q(4, "kWh"), price); err != nil || math.Abs(got.Value()-1) > 1e-9 {
+		t.Error("expected: 1 $, actual:", got, err)
+	}
+	// too much for the energy allowance: nothing changes
+	if _, err = Exchange(wallet, energy, Q(10, "$"), price); err == nil {
+		t.Error("max of target ignored")
+	}
+	// more than the balance
+	if _, err = Exchange(energy, wallet, Q(100, "kWh"), price); err == nil {
+		t.Error("min of source ignored")
+	}
+	if _, err = Exchange(wallet, energy, Q(1, "$"), Q(2, "m")); err == nil {
+		t.Error("incompatible rate ignored")
+	}
+	if _, err = Exchange(wallet, wallet, Q(1, "$"), Q(1, "")); err == nil {
+		t.Error("exchange within one resource ignored")
+	}
+	if !Equal(wallet.Balance(), Q(91, "$"), Q(1e-9, "$")) || !Equal(energy.Balance(), Q(36, "kWh"), Q(1e-9, "kWh")) {
+		t.Error("expected: 91 $ and 36 kWh, actual:", wallet.Balance(), energy.Balance())
+	}
+}
+
 func TestMinMax(t *testing.T) {
 	rsc := New(Q(0, "m"), Q(100, "m"), "")
 	rsc.Set(Q(30, "m"))
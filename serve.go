@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	us "github.com/imhotep-nb/units/quantity"
+)
+
+// newServeMux returns the handler of the HTTP API of "serve":
+//
+//	GET /convert?value=3&from=ft&to=m  {"value":0.9144,"unit":"m"}
+//	GET /parse?q=3 ft                  {"value":3,"unit":"ft"}
+//	GET /units[?compatible=m]          ["A","acre",...]
+//
+// Errors are returned with status 400 as {"error":"..."}. The unit table is frozen, see
+// quantity.FreezeRegistry, so that the handlers can parse and convert concurrently; units
+// must be defined before.
+func newServeMux() *http.ServeMux {
+	us.FreezeRegistry()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/convert", func(w http.ResponseWriter, r *http.Request) {
+		value, err := strconv.ParseFloat(r.FormValue("value"), 64)
+		if err != nil {
+			writeError(w, "invalid value: "+r.FormValue("value"))
+			return
+		}
+		from, to := r.FormValue("from"), r.FormValue("to")
+		q, err := us.Parse(strconv.FormatFloat(value, 'g', -1, 64) + " " + from)
+		if err != nil {
+			writeError(w, err.Error())
+			return
+		}
+		if us.UnitFor(to) == &us.UndefinedUnit {
+			writeError(w, (&us.UnknownUnitError{Symbol: to}).Error())
+			return
+		}
+		c, ok := q.ConvertTo(to)
+		if !ok {
+			writeError(w, (&us.IncompatibleUnitsError{A: from, B: to}).Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, c)
+	})
+	mux.HandleFunc("/parse", func(w http.ResponseWriter, r *http.Request) {
+		q, err := us.Parse(r.FormValue("q"))
+		if err != nil {
+			writeError(w, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, q)
+	})
+	mux.HandleFunc("/units", func(w http.ResponseWriter, r *http.Request) {
+		symbols := us.Symbols()
+		if c := r.FormValue("compatible"); c != "" {
+			if symbols = us.CompatibleUnits(c); symbols == nil {
+				writeError(w, (&us.UnknownUnitError{Symbol: c}).Error())
+				return
+			}
+		}
+		writeJSON(w, http.StatusOK, symbols)
+	})
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, msg string) {
+	writeJSON(w, http.StatusBadRequest, map[string]string{"error": msg})
+}
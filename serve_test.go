@@ -0,0 +1,64 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestServe(t *testing.T) {
+	srv := httptest.NewServer(newServeMux())
+	defer srv.Close()
+	data := []struct {
+		path     string
+		status   int
+		expected string
+	}{
+		{"/convert?value=1&from=in&to=cm", 200, `{"value":2.54,"unit":"cm"}`},
+		{"/convert?value=100&from=km/h&to=m/s", 200, `"unit":"m/s"`},
+		{"/convert?value=x&from=ft&to=m", 400, `{"error":"invalid value: x"}`},
+		{"/convert?value=3&from=ft&to=kg", 400, `"error":"units not compatible`},
+		{"/convert?value=3&from=ft&to=furlong", 400, `{"error":"unknown unit [furlong]"}`},
+		{"/parse?q=2.5+psi", 200, `{"value":2.5,"unit":"psi"}`},
+		{"/parse?q=2.5+furlong", 400, `"error"`},
+		{"/units", 200, `"acre"`},
+		{"/units?compatible=Pa", 200, `"psi"`},
+		{"/units?compatible=furlong", 400, `"error"`},
+	}
+	for _, d := range data {
+		resp, err := http.Get(srv.URL + d.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil || resp.StatusCode != d.status || !strings.Contains(string(body), d.expected) {
+			t.Error(d.path, "expected:", d.status, d.expected, "actual:", resp.StatusCode, string(body), err)
+		}
+	}
+}
+
+func TestServeConcurrent(t *testing.T) {
+	mux := newServeMux()
+	paths := []string{"/convert?value=1&from=km/h&to=ft/s", "/convert?value=2&from=N.m/s&to=hp",
+		"/convert?value=3&from=lbf/sq+in&to=kPa", "/parse?q=4+kg.m2/s3", "/units?compatible=mi/h"}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := range paths {
+				path := paths[(i+j)%len(paths)]
+				w := httptest.NewRecorder()
+				mux.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+				if w.Code != 200 {
+					t.Error(path, "expected: 200, actual:", w.Code, w.Body)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}